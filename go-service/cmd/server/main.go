@@ -2,20 +2,37 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"anomaly-detection-platform/go-service/internal/alerting"
 	"anomaly-detection-platform/go-service/internal/api"
-	"anomaly-detection-platform/go-service/internal/metrics"
+	"anomaly-detection-platform/go-service/internal/client"
+	"anomaly-detection-platform/go-service/internal/detection"
 	"anomaly-detection-platform/go-service/internal/elastic"
+	"anomaly-detection-platform/go-service/internal/feedback"
+	"anomaly-detection-platform/go-service/internal/incident"
+	"anomaly-detection-platform/go-service/internal/ingest"
+	"anomaly-detection-platform/go-service/internal/input"
+	"anomaly-detection-platform/go-service/internal/kafka"
+	"anomaly-detection-platform/go-service/internal/metrics"
+	"anomaly-detection-platform/go-service/internal/parsing"
+	"anomaly-detection-platform/go-service/internal/preprocessing"
+	"anomaly-detection-platform/go-service/internal/redaction"
+	"anomaly-detection-platform/go-service/internal/storage"
+	"anomaly-detection-platform/go-service/internal/suppression"
+	"anomaly-detection-platform/go-service/internal/volume"
+	"anomaly-detection-platform/go-service/internal/wal"
 	"anomaly-detection-platform/go-service/pkg/config"
 )
 
@@ -26,29 +43,400 @@ func main() {
 		gin.SetMode(mode)
 	}
 
-	// Initialize Elasticsearch client
-	esAddresses := strings.Split(config.GetEnv("ELASTICSEARCH_URLS", "http://localhost:9200"), ",")
-	esClient, err := elastic.NewClient(esAddresses)
-	if err != nil {
-		log.Printf("Warning: Failed to connect to Elasticsearch: %v", err)
-		log.Println("Continuing without Elasticsearch - logs will not be stored")
-	} else {
-		log.Println("Connected to Elasticsearch successfully")
-
-		// Create index if it doesn't exist
-		if err := esClient.CreateIndex(context.Background()); err != nil {
-			log.Printf("Warning: Failed to create Elasticsearch index: %v", err)
-		} else {
-			log.Println("Elasticsearch index created/verified successfully")
+	// Initialize the log store: Elasticsearch, or an embedded store for single-node deployments
+	var embeddedStore *storage.EmbeddedStore
+	switch backend := config.GetEnv("STORAGE_BACKEND", storage.BackendElasticsearch); backend {
+	case storage.BackendElasticsearch:
+		esAddresses := strings.Split(config.GetEnv("ELASTICSEARCH_URLS", "http://localhost:9200"), ",")
+		esClient, err := elastic.New(esAddresses)
+		if err != nil {
+			log.Fatalf("elasticsearch: %v", err)
+		}
+		esClient.SetIndexPrefix(config.GetEnv("ES_INDEX_PREFIX", ""))
+		// Without Elasticsearch at startup, the health watch below connects once it is up
+		if !esClient.Check(context.Background(), connectElasticsearch(esClient)) {
+			log.Println("Warning: Elasticsearch is not reachable yet - connecting in the background")
 		}
 
 		// Set global client
 		api.ESClient = esClient
+		api.Logs = esClient
+
+		if config.GetEnvBool("ES_BULK_ENABLED", true) {
+			bulkCfg := elastic.BulkConfig{
+				FlushDocs:     config.GetEnvInt("ES_BULK_FLUSH_DOCS", 500),
+				FlushBytes:    config.GetEnvInt("ES_BULK_FLUSH_BYTES", 5<<20),
+				FlushInterval: config.GetEnvDuration("ES_BULK_FLUSH_INTERVAL", time.Second),
+				QueueSize:     config.GetEnvInt("ES_BULK_QUEUE_SIZE", 10000),
+				MaxAttempts:   config.GetEnvInt("ES_BULK_MAX_ATTEMPTS", 5),
+			}
+			if config.GetEnvBool("ES_DEAD_LETTERS", true) {
+				bulkCfg.DeadLetters = esClient
+			}
+			if config.GetEnvBool("WAL_ENABLED", true) {
+				w, err := wal.Open(wal.Config{
+					Dir:          config.GetEnv("WAL_DIR", "data/wal"),
+					MaxBytes:     int64(config.GetEnvInt("WAL_MAX_BYTES", 1<<30)),
+					SegmentBytes: int64(config.GetEnvInt("WAL_SEGMENT_BYTES", 64<<20)),
+					DropPolicy:   config.GetEnv("WAL_DROP_POLICY", wal.DropOldest),
+				})
+				if err != nil {
+					log.Fatalf("write-ahead log: %v", err)
+				}
+				if records, _ := w.Backlog(); records > 0 {
+					log.Printf("Write-ahead log holds %d logs from a previous run", records)
+				}
+				api.WAL = w
+				bulkCfg.Spill = w
+			}
+			api.Indexer = elastic.NewBulkIndexer(esClient, bulkCfg)
+		}
+	case storage.BackendEmbedded:
+		path := config.GetEnv("EMBEDDED_STORE_PATH", "data/logs.db")
+		store, err := storage.NewEmbeddedStore(path)
+		if err != nil {
+			log.Fatalf("embedded store: %v", err)
+		}
+		log.Printf("Storing logs in embedded store %s", path)
+		log.Println("Suppression rules, incidents and templates are kept in memory only, and volume anomalies are not stored")
+		embeddedStore = store
+		api.Logs = store
+	default:
+		log.Fatalf("invalid STORAGE_BACKEND %q, use %s or %s", backend, storage.BackendElasticsearch, storage.BackendEmbedded)
 	}
 
 	// Initialize Prometheus metrics
 	metrics.Init()
 
+	// Background jobs run until bgCtx is cancelled during shutdown
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	var background sync.WaitGroup
+
+	if api.ESClient != nil {
+		esClient := api.ESClient
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			esClient.Watch(bgCtx, config.GetEnvDuration("ES_HEALTH_INTERVAL", 5*time.Second), connectElasticsearch(esClient))
+		}()
+
+		// Roll the log indices over and expire old generations
+		retention := elastic.NewRetentionManager(esClient, elastic.RetentionConfig{
+			RolloverMaxAge:     config.GetEnvDuration("ES_ROLLOVER_MAX_AGE", 24*time.Hour),
+			RolloverMaxSize:    config.GetEnv("ES_ROLLOVER_MAX_SIZE", ""),
+			ForceMergeAfter:    config.GetEnvDuration("ES_FORCEMERGE_AFTER", 24*time.Hour),
+			LogsRetention:      config.GetEnvDuration("ES_RETENTION_LOGS", 7*24*time.Hour),
+			AnomaliesRetention: config.GetEnvDuration("ES_RETENTION_ANOMALIES", 90*24*time.Hour),
+		})
+		runEvery(bgCtx, &background, config.GetEnvDuration("ES_RETENTION_INTERVAL", 10*time.Minute), "apply index retention", retention.Apply)
+	}
+	if api.WAL != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			api.WAL.Replay(bgCtx, time.Second, api.ESClient.Healthy, api.ReplayDocuments)
+		}()
+	}
+
+	// Coalesce predictions into /predict/batch requests
+	batcher := client.NewBatcher(
+		config.GetEnvInt("PREDICT_BATCH_SIZE", 32),
+		config.GetEnvDuration("PREDICT_BATCH_WAIT", 10*time.Millisecond),
+	)
+
+	// Select the anomaly detectors
+	detectorCfg, err := detection.ParseConfig(
+		config.GetEnv("DETECTORS", "python"),
+		config.GetEnv("DETECTION_MODE", "any"),
+		config.GetEnv("DETECTOR_WEIGHTS", ""),
+		config.GetEnv("DETECTION_THRESHOLD", ""),
+	)
+	if err != nil {
+		log.Fatalf("invalid detection config: %v", err)
+	}
+	keywordDetector, err := detection.NewKeywordDetector(splitList(config.GetEnv("DETECTION_KEYWORDS", "")))
+	if err != nil {
+		log.Fatalf("invalid detection keywords: %v", err)
+	}
+	detector, err := detection.Build(detectorCfg,
+		detection.NewPythonDetector(batcher.Predict, 0.5),
+		keywordDetector,
+		detection.NewTemplateDetector(
+			config.GetEnvDuration("TEMPLATE_LEARNING_WINDOW", 24*time.Hour),
+			config.GetEnvDuration("TEMPLATE_RARITY_WINDOW", 30*24*time.Hour),
+			config.GetEnvInt("TEMPLATE_RARITY_THRESHOLD", 3),
+		),
+	)
+	if err != nil {
+		log.Fatalf("invalid detection config: %v", err)
+	}
+	api.Detector = detector
+	log.Printf("Anomaly detection using %s (%s)", detector.Name(), strings.Join(detectorCfg.Detectors, ","))
+
+	// Parse the event time, level and fields of the common log formats
+	loc, err := time.LoadLocation(config.GetEnv("PARSE_TIMEZONE", "UTC"))
+	if err != nil {
+		log.Fatalf("invalid PARSE_TIMEZONE: %v", err)
+	}
+	if parseFormats := config.GetEnv("PARSE_FORMATS", ""); parseFormats != "none" {
+		parser, err := parsing.NewParser(parsing.Config{Formats: splitList(parseFormats), Location: loc})
+		if err != nil {
+			log.Fatalf("invalid parsing config: %v", err)
+		}
+		api.LogParser = parser
+	}
+
+	// Redact personal data and secrets before logs are processed or stored
+	if redactTypes := config.GetEnv("REDACT_TYPES", ""); redactTypes != "none" {
+		redactor, err := redaction.New(redaction.Config{
+			Types:   splitList(redactTypes),
+			HashKey: config.GetEnv("REDACT_HASH_KEY", ""),
+		})
+		if err != nil {
+			log.Fatalf("invalid redaction config: %v", err)
+		}
+		api.Redactor = redactor
+	}
+
+	// Load the preprocessing pipelines and pick up changes to their config file
+	preprocessPath := config.GetEnv("PREPROCESS_CONFIG", "")
+	preprocessor, err := preprocessing.NewPreprocessor(preprocessPath)
+	if err != nil {
+		log.Fatalf("invalid preprocessing config: %v", err)
+	}
+	api.Preprocessor = preprocessor
+	if preprocessPath != "" {
+		runEvery(bgCtx, &background, config.GetEnvDuration("PREPROCESS_RELOAD_INTERVAL", 10*time.Second), "reload preprocessing config", func(context.Context) error {
+			reloaded, err := preprocessor.Reload()
+			if reloaded {
+				log.Printf("Reloaded preprocessing config from %s", preprocessPath)
+			}
+			return err
+		})
+	}
+
+	// Mine log templates, restoring the ones persisted by previous runs
+	api.TemplateMiner = preprocessing.NewMiner(
+		config.GetEnvInt("DRAIN_DEPTH", 4),
+		config.GetEnvFloat("DRAIN_SIM_THRESHOLD", 0.4),
+		config.GetEnvInt("DRAIN_MAX_CHILDREN", 100),
+	)
+	if err := api.LoadTemplates(context.Background()); err != nil {
+		log.Printf("Warning: Failed to load log templates: %v", err)
+	}
+	runEvery(bgCtx, &background, config.GetEnvDuration("TEMPLATE_FLUSH_INTERVAL", 30*time.Second), "persist log templates", api.FlushTemplates)
+
+	// Watch the log volume of every source
+	volumeMonitor, err := volume.NewMonitor(volume.Config{
+		SourceKeys: splitList(config.GetEnv("VOLUME_SOURCE_KEYS", "service")),
+		Window:     config.GetEnvDuration("VOLUME_WINDOW", time.Minute),
+		Method:     config.GetEnv("VOLUME_METHOD", volume.MethodEWMA),
+		Threshold:  config.GetEnvFloat("VOLUME_THRESHOLD", 4),
+		Alpha:      config.GetEnvFloat("VOLUME_EWMA_ALPHA", 0.1),
+		Season:     config.GetEnvDuration("VOLUME_SEASON", 24*time.Hour),
+		History:    config.GetEnvInt("VOLUME_HISTORY", 60),
+		MinHistory: config.GetEnvInt("VOLUME_MIN_HISTORY", 10),
+		MinCount:   config.GetEnvFloat("VOLUME_MIN_COUNT", 10),
+		SourceTTL:  config.GetEnvDuration("VOLUME_SOURCE_TTL", 24*time.Hour),
+	}, api.RecordVolumeAnomaly)
+	if err != nil {
+		log.Fatalf("invalid volume config: %v", err)
+	}
+	api.VolumeMonitor = volumeMonitor
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		volumeMonitor.Run(bgCtx)
+	}()
+
+	// Silence known noisy anomalies
+	var suppressionStore suppression.Store
+	if api.ESClient != nil {
+		suppressionStore = api.ESClient
+	}
+	api.Suppressions = suppression.NewSet(suppressionStore)
+	if err := api.Suppressions.Load(context.Background()); err != nil {
+		log.Printf("Warning: Failed to load suppression rules: %v", err)
+	}
+
+	// Group anomalies into incidents, resuming the ones still collecting anomalies
+	var incidentStore incident.Store
+	if api.ESClient != nil {
+		incidentStore = api.ESClient
+	}
+	incidents, err := incident.NewManager(incident.Config{
+		SourceKeys: splitList(config.GetEnv("INCIDENT_SOURCE_KEYS", "service")),
+		Gap:        config.GetEnvDuration("INCIDENT_GAP", 15*time.Minute),
+	}, incidentStore)
+	if err != nil {
+		log.Fatalf("invalid incident config: %v", err)
+	}
+	api.Incidents = incidents
+	if err := incidents.Load(context.Background()); err != nil {
+		log.Printf("Warning: Failed to load incidents: %v", err)
+	}
+	runEvery(bgCtx, &background, config.GetEnvDuration("INCIDENT_FLUSH_INTERVAL", 10*time.Second), "persist incidents", api.FlushIncidents)
+
+	// Rebuild detector precision/recall from analyst feedback
+	api.FeedbackTracker = feedback.NewTracker()
+	if err := api.LoadFeedback(context.Background()); err != nil {
+		log.Printf("Warning: Failed to load analyst feedback: %v", err)
+	}
+
+	// Evaluate alert rules on the ingestion stream
+	alertPath := config.GetEnv("ALERT_CONFIG", "")
+	alertCfg, err := alerting.LoadConfig(alertPath)
+	if err != nil {
+		log.Fatalf("invalid alerting config: %v", err)
+	}
+	alertEngine, err := alerting.NewEngine(alertCfg, alertPath)
+	if err != nil {
+		log.Fatalf("invalid alerting config: %v", err)
+	}
+	api.AlertEngine = alertEngine
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		alertEngine.Run(bgCtx)
+	}()
+
+	// Start the ingestion queue workers
+	workers := config.GetEnvInt("INGEST_WORKERS", 16)
+	queue := ingest.NewQueue(config.GetEnvInt("INGEST_QUEUE_SIZE", 10000), workers, api.ProcessEvent)
+	api.IngestQueue = queue
+	api.SyncConcurrency = workers
+	api.RetryAfter = config.GetEnvDuration("INGEST_RETRY_AFTER", time.Second)
+
+	// Join stack traces and other multiline messages, per source stream
+	if multilinePath := config.GetEnv("MULTILINE_CONFIG", ""); multilinePath != "" {
+		multilineCfg, err := ingest.LoadMultilineConfig(multilinePath)
+		if err != nil {
+			log.Fatalf("invalid multiline config: %v", err)
+		}
+		assembler, err := ingest.NewAssembler(*multilineCfg)
+		if err != nil {
+			log.Fatalf("invalid multiline config: %v", err)
+		}
+		api.Multiline = assembler
+		runEvery(bgCtx, &background, max(assembler.Timeout()/4, 100*time.Millisecond), "flush multiline events", func(ctx context.Context) error {
+			return api.FlushMultiline(ctx, false)
+		})
+	}
+
+	// Network inputs stop before the ingestion queue is closed
+	inputCtx, stopInputs := context.WithCancel(context.Background())
+	var inputs sync.WaitGroup
+
+	syslogCfg := input.SyslogConfig{
+		UDPAddr:        config.GetEnv("SYSLOG_UDP_ADDR", ""),
+		TCPAddr:        config.GetEnv("SYSLOG_TCP_ADDR", ""),
+		TLSAddr:        config.GetEnv("SYSLOG_TLS_ADDR", ""),
+		TLSCert:        config.GetEnv("SYSLOG_TLS_CERT", ""),
+		TLSKey:         config.GetEnv("SYSLOG_TLS_KEY", ""),
+		MaxMessageSize: config.GetEnvInt("SYSLOG_MAX_MESSAGE_SIZE", 64<<10),
+		IdleTimeout:    config.GetEnvDuration("SYSLOG_IDLE_TIMEOUT", 5*time.Minute),
+		Location:       loc,
+	}
+	if syslogCfg.UDPAddr != "" || syslogCfg.TCPAddr != "" || syslogCfg.TLSAddr != "" {
+		syslogServer, err := input.NewSyslogServer(syslogCfg, api.IngestEvents)
+		if err != nil {
+			log.Fatalf("invalid syslog config: %v", err)
+		}
+		if err := syslogServer.Listen(); err != nil {
+			log.Fatalf("syslog: %v", err)
+		}
+		inputs.Add(1)
+		go func() {
+			defer inputs.Done()
+			syslogServer.Serve(inputCtx)
+		}()
+	}
+
+	if fluentAddr := config.GetEnv("FLUENT_ADDR", ""); fluentAddr != "" {
+		fluentServer, err := input.NewFluentServer(input.FluentConfig{
+			Addr:         fluentAddr,
+			MessageKeys:  splitList(config.GetEnv("FLUENT_MESSAGE_KEYS", "")),
+			MetadataKeys: splitList(config.GetEnv("FLUENT_METADATA_KEYS", "")),
+			LevelKey:     config.GetEnv("FLUENT_LEVEL_KEY", ""),
+			TagKey:       config.GetEnv("FLUENT_TAG_KEY", ""),
+			TagFields:    splitList(config.GetEnv("FLUENT_TAG_FIELDS", "")),
+			MaxChunkSize: config.GetEnvInt("FLUENT_MAX_CHUNK_SIZE", 8<<20),
+		}, api.ProcessEvents)
+		if err != nil {
+			log.Fatalf("invalid fluent config: %v", err)
+		}
+		if err := fluentServer.Listen(); err != nil {
+			log.Fatalf("fluent: %v", err)
+		}
+		inputs.Add(1)
+		go func() {
+			defer inputs.Done()
+			fluentServer.Serve(inputCtx)
+		}()
+	}
+
+	api.OTLPMaxBodySize = int64(config.GetEnvInt("OTLP_MAX_BODY_SIZE", 16<<20))
+	if otlpAddr := config.GetEnv("OTLP_GRPC_ADDR", ""); otlpAddr != "" {
+		otlpServer := input.NewOTLPServer(otlpAddr, api.ProcessEvents)
+		if err := otlpServer.Listen(); err != nil {
+			log.Fatalf("otlp: %v", err)
+		}
+		inputs.Add(1)
+		go func() {
+			defer inputs.Done()
+			otlpServer.Serve(inputCtx)
+		}()
+	}
+
+	kafkaCfg := kafka.Config{
+		Brokers:  splitList(config.GetEnv("KAFKA_BROKERS", "")),
+		ClientID: config.GetEnv("KAFKA_CLIENT_ID", "go-service"),
+		TLS:      config.GetEnvBool("KAFKA_TLS", false),
+	}
+	if topic := config.GetEnv("KAFKA_RESULTS_TOPIC", ""); topic != "" {
+		producer, err := kafka.NewProducer(kafka.ProducerConfig{Config: kafkaCfg, Topic: topic})
+		if err != nil {
+			log.Fatalf("kafka producer: %v", err)
+		}
+		api.ResultProducer = producer
+		api.PublishAnomaliesOnly = config.GetEnvBool("KAFKA_RESULTS_ANOMALIES_ONLY", false)
+	}
+	if topics := splitList(config.GetEnv("KAFKA_TOPICS", "")); len(topics) > 0 {
+		consumer, err := kafka.NewConsumer(kafka.ConsumerConfig{
+			Config:         kafkaCfg,
+			Topics:         topics,
+			Group:          config.GetEnv("KAFKA_GROUP", "go-service"),
+			ValueFormat:    config.GetEnv("KAFKA_VALUE_FORMAT", kafka.FormatJSON),
+			MaxPollRecords: config.GetEnvInt("KAFKA_MAX_POLL_RECORDS", 500),
+		}, api.ProcessBatch)
+		if err != nil {
+			log.Fatalf("kafka consumer: %v", err)
+		}
+		inputs.Add(1)
+		go func() {
+			defer inputs.Done()
+			consumer.Serve(inputCtx)
+		}()
+	}
+
+	if paths := splitList(config.GetEnv("TAIL_PATHS", "")); len(paths) > 0 {
+		tailer, err := input.NewFileTailer(input.TailConfig{
+			Paths:          paths,
+			CheckpointFile: config.GetEnv("TAIL_CHECKPOINT_FILE", ""),
+			ReadFromHead:   config.GetEnvBool("TAIL_READ_FROM_HEAD", false),
+			PollInterval:   config.GetEnvDuration("TAIL_POLL_INTERVAL", time.Second),
+			MaxLineSize:    config.GetEnvInt("TAIL_MAX_LINE_SIZE", 64<<10),
+		}, api.ProcessEvents)
+		if err != nil {
+			log.Fatalf("file tailing: %v", err)
+		}
+		inputs.Add(1)
+		go func() {
+			defer inputs.Done()
+			tailer.Serve(inputCtx)
+		}()
+	}
+
 	r := gin.New()
 	r.Use(gin.Logger(), gin.Recovery(), api.LoggingMiddleware())
 
@@ -83,5 +471,92 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("graceful shutdown failed: %v", err)
 	}
+	stopInputs()
+	inputs.Wait()
+	if err := api.FlushMultiline(ctx, true); err != nil {
+		log.Printf("Failed to flush multiline events: %v", err)
+	}
+	if err := queue.Close(ctx); err != nil {
+		log.Printf("ingestion queue did not drain: %v", err)
+	}
+	batcher.Close()
+	if api.ResultProducer != nil {
+		if err := api.ResultProducer.Close(ctx); err != nil {
+			log.Printf("Failed to publish pending detection results: %v", err)
+		}
+	}
+	stopBackground()
+	background.Wait()
+	// The write-ahead log replay has stopped, and takes what the indexer cannot flush
+	if api.Indexer != nil {
+		if err := api.Indexer.Close(ctx); err != nil {
+			log.Printf("Failed to index pending logs: %v", err)
+		}
+	}
+	if err := api.FlushTemplates(ctx); err != nil {
+		log.Printf("Failed to persist log templates: %v", err)
+	}
+	if err := api.FlushIncidents(ctx); err != nil {
+		log.Printf("Failed to persist incidents: %v", err)
+	}
+	if api.WAL != nil {
+		if err := api.WAL.Close(); err != nil {
+			log.Printf("Failed to close write-ahead log: %v", err)
+		}
+	}
+	if embeddedStore != nil {
+		if err := embeddedStore.Close(); err != nil {
+			log.Printf("Failed to close embedded store: %v", err)
+		}
+	}
 	log.Println("server stopped")
 }
+
+// connectElasticsearch prepares Elasticsearch whenever the client (re)connects: the indices
+// are created if needed and the suppression rules, which could not be loaded while it was
+// down, are loaded again
+func connectElasticsearch(esClient *elastic.Client) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if err := esClient.CreateIndex(ctx); err != nil {
+			return fmt.Errorf("failed to create Elasticsearch index: %w", err)
+		}
+		log.Println("Elasticsearch index created/verified successfully")
+		if api.Suppressions != nil {
+			if err := api.Suppressions.Load(ctx); err != nil {
+				log.Printf("Warning: Failed to load suppression rules: %v", err)
+			}
+		}
+		return nil
+	}
+}
+
+// runEvery calls fn every interval until ctx is cancelled, logging failures
+func runEvery(ctx context.Context, wg *sync.WaitGroup, interval time.Duration, what string, fn func(context.Context) error) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := fn(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("Failed to %s: %v", what, err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// splitList splits a comma-separated env value, dropping empty items
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,456 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// ErrRuleNotFound is returned when a rule ID does not exist
+var ErrRuleNotFound = errors.New("alert rule not found")
+
+// ErrRuleExists is returned when creating a rule with an ID that is already used
+var ErrRuleExists = errors.New("alert rule already exists")
+
+// defaultResendInterval applies to rules that do not set resend_interval
+const defaultResendInterval = time.Hour
+
+// Event is a processed log as seen by the alerting engine
+type Event struct {
+	LogID       string                 `json:"log_id"`
+	Text        string                 `json:"text"`
+	IsAnomaly   bool                   `json:"is_anomaly"`
+	Score       float64                `json:"score"`
+	Detector    string                 `json:"detector,omitempty"`
+	Reason      string                 `json:"reason,omitempty"`
+	TemplateID  string                 `json:"template_id,omitempty"`
+	NewTemplate bool                   `json:"new_template,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	TraceID     string                 `json:"trace_id,omitempty"`
+	Timestamp   time.Time              `json:"timestamp"`
+}
+
+// Alert is one notification: every firing of a rule for the same dedup key since
+// the previous notification is folded into it
+type Alert struct {
+	RuleID    string            `json:"rule_id"`
+	RuleName  string            `json:"rule_name"`
+	Severity  string            `json:"severity,omitempty"`
+	DedupKey  string            `json:"dedup_key"`
+	Group     map[string]string `json:"group,omitempty"`
+	Summary   string            `json:"summary"`
+	Count     int               `json:"count"` // firings since the previous notification
+	Total     int               `json:"total"` // firings since the alert was first raised
+	FirstSeen time.Time         `json:"first_seen"`
+	LastSeen  time.Time         `json:"last_seen"`
+	LastSent  time.Time         `json:"last_sent,omitempty"`
+	Sample    Event             `json:"sample"`
+}
+
+// Title is a one-line description of the alert
+func (a Alert) Title() string {
+	name := a.RuleName
+	if name == "" {
+		name = a.RuleID
+	}
+	if a.Severity != "" {
+		return fmt.Sprintf("[%s] %s", strings.ToUpper(a.Severity), name)
+	}
+	return name
+}
+
+type notification struct {
+	alert    Alert
+	channels []string
+}
+
+// Engine evaluates rules against the ingestion stream and sends notifications.
+// Firings are grouped per dedup key: the first one is sent immediately, later ones
+// are only counted until the rule's resend interval has passed.
+type Engine struct {
+	mu       sync.Mutex
+	cfg      *Config
+	path     string
+	channels map[string]Notifier
+	alerts   map[string]*Alert
+	windows  map[string][]time.Time
+	pruned   time.Time
+
+	outbox chan notification
+}
+
+// NewEngine validates cfg and creates an engine. When path is set, rule changes made
+// through the API are written back to that file.
+func NewEngine(cfg *Config, path string) (*Engine, error) {
+	channels := make(map[string]Notifier, len(cfg.Channels))
+	for _, ch := range cfg.Channels {
+		if _, ok := channels[ch.Name]; ok {
+			return nil, fmt.Errorf("duplicate channel %q", ch.Name)
+		}
+		n, err := NewNotifier(ch)
+		if err != nil {
+			return nil, err
+		}
+		channels[ch.Name] = n
+	}
+
+	seen := make(map[string]bool, len(cfg.Rules))
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		if err := r.Validate(channels); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.ID, err)
+		}
+		if seen[r.ID] {
+			return nil, fmt.Errorf("duplicate rule %q", r.ID)
+		}
+		seen[r.ID] = true
+	}
+
+	return &Engine{
+		cfg:      cfg,
+		path:     path,
+		channels: channels,
+		alerts:   make(map[string]*Alert),
+		windows:  make(map[string][]time.Time),
+		outbox:   make(chan notification, 1000),
+	}, nil
+}
+
+// Process evaluates every enabled rule against ev. It never blocks on notifications.
+func (e *Engine) Process(ev Event) {
+	e.mu.Lock()
+	e.prune(ev.Timestamp)
+
+	var pending []notification
+	for i := range e.cfg.Rules {
+		r := &e.cfg.Rules[i]
+		if r.Disabled || !matches(r.Match, ev.Metadata) {
+			continue
+		}
+
+		groupKey, group := groupOf(r, ev.Metadata)
+		var summary string
+		switch r.Type {
+		case RuleCount:
+			if !ev.IsAnomaly {
+				continue
+			}
+			n := e.countInWindow(r, groupKey, ev.Timestamp)
+			if n < r.Threshold {
+				continue
+			}
+			summary = fmt.Sprintf("%d anomalies within %s", n, time.Duration(r.Window))
+		case RuleScore:
+			if !ev.IsAnomaly || ev.Score < r.MinScore {
+				continue
+			}
+			summary = fmt.Sprintf("anomaly scored %.2f (threshold %.2f)", ev.Score, r.MinScore)
+		case RuleNewTemplate:
+			if !ev.NewTemplate {
+				continue
+			}
+			summary = fmt.Sprintf("new log template %s", ev.TemplateID)
+		}
+		if len(group) > 0 {
+			summary += " for " + formatGroup(group)
+		}
+
+		if n, ok := e.fire(r, groupKey, group, summary, ev); ok {
+			pending = append(pending, n)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, n := range pending {
+		select {
+		case e.outbox <- n:
+		default:
+			metrics.AlertNotificationsTotal.WithLabelValues("", "dropped").Inc()
+			log.Printf("Alert outbox full, dropping notification for %s", n.alert.DedupKey)
+		}
+	}
+}
+
+// fire records a firing and returns the notification to send, if it is due
+func (e *Engine) fire(r *Rule, groupKey string, group map[string]string, summary string, ev Event) (notification, bool) {
+	metrics.AlertsFiredTotal.WithLabelValues(r.ID).Inc()
+
+	a, ok := e.alerts[groupKey]
+	if !ok {
+		a = &Alert{RuleID: r.ID, DedupKey: groupKey, Group: group, FirstSeen: ev.Timestamp}
+		e.alerts[groupKey] = a
+	}
+	a.RuleName = r.Name
+	a.Severity = r.Severity
+	a.Summary = summary
+	a.Count++
+	a.Total++
+	a.LastSeen = ev.Timestamp
+	a.Sample = ev
+
+	resend := time.Duration(r.ResendInterval)
+	if resend <= 0 {
+		resend = defaultResendInterval
+	}
+	if !a.LastSent.IsZero() && ev.Timestamp.Sub(a.LastSent) < resend {
+		return notification{}, false
+	}
+
+	a.LastSent = ev.Timestamp
+	n := notification{alert: *a, channels: append([]string(nil), r.Channels...)}
+	a.Count = 0
+	a.FirstSeen = ev.Timestamp
+	return n, true
+}
+
+// prune forgets idle alert groups and count windows, at most once a minute
+func (e *Engine) prune(now time.Time) {
+	if now.Sub(e.pruned) < time.Minute {
+		return
+	}
+	e.pruned = now
+
+	windows := make(map[string]time.Duration, len(e.cfg.Rules))
+	resends := make(map[string]time.Duration, len(e.cfg.Rules))
+	for _, r := range e.cfg.Rules {
+		windows[r.ID] = time.Duration(r.Window)
+		resends[r.ID] = max(time.Duration(r.ResendInterval), defaultResendInterval)
+	}
+
+	for k, times := range e.windows {
+		ruleID, _, _ := strings.Cut(k, "|")
+		if len(times) == 0 || now.Sub(times[len(times)-1]) > windows[ruleID] {
+			delete(e.windows, k)
+		}
+	}
+	for k, a := range e.alerts {
+		if now.Sub(a.LastSeen) > resends[a.RuleID] {
+			delete(e.alerts, k)
+		}
+	}
+}
+
+// countInWindow records an anomaly for a count rule group and returns how many fell within the window
+func (e *Engine) countInWindow(r *Rule, groupKey string, at time.Time) int {
+	cutoff := at.Add(-time.Duration(r.Window))
+	times := e.windows[groupKey]
+
+	kept := times[:0]
+	for _, t := range times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	kept = append(kept, at)
+	// Only the most recent Threshold timestamps are needed to know whether it is reached
+	if len(kept) > r.Threshold {
+		kept = kept[len(kept)-r.Threshold:]
+	}
+	e.windows[groupKey] = kept
+	return len(kept)
+}
+
+// Run delivers queued notifications until ctx is cancelled
+func (e *Engine) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-e.outbox:
+			for _, name := range n.channels {
+				e.mu.Lock()
+				ch, ok := e.channels[name]
+				e.mu.Unlock()
+				if !ok {
+					continue
+				}
+
+				sctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+				err := ch.Notify(sctx, n.alert)
+				cancel()
+				if err != nil {
+					metrics.AlertNotificationsTotal.WithLabelValues(name, "failed").Inc()
+					log.Printf("Failed to send alert %s to %s: %v", n.alert.DedupKey, name, err)
+					continue
+				}
+				metrics.AlertNotificationsTotal.WithLabelValues(name, "sent").Inc()
+			}
+		}
+	}
+}
+
+// Alerts returns the alert groups that have fired, most recent first
+func (e *Engine) Alerts() []Alert {
+	e.mu.Lock()
+	out := make([]Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		out = append(out, *a)
+	}
+	e.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
+
+// Rules returns a copy of the configured rules
+func (e *Engine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Rule(nil), e.cfg.Rules...)
+}
+
+// Rule returns the rule with the given ID
+func (e *Engine) Rule(id string) (Rule, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if i := e.indexOf(id); i >= 0 {
+		return e.cfg.Rules[i], nil
+	}
+	return Rule{}, ErrRuleNotFound
+}
+
+// AddRule validates and adds a new rule
+func (e *Engine) AddRule(r Rule) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := r.Validate(e.channels); err != nil {
+		return err
+	}
+	if e.indexOf(r.ID) >= 0 {
+		return ErrRuleExists
+	}
+
+	rules := append(append(make([]Rule, 0, len(e.cfg.Rules)+1), e.cfg.Rules...), r)
+	return e.setRules(rules)
+}
+
+// UpdateRule replaces the rule with the same ID
+func (e *Engine) UpdateRule(r Rule) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := r.Validate(e.channels); err != nil {
+		return err
+	}
+	i := e.indexOf(r.ID)
+	if i < 0 {
+		return ErrRuleNotFound
+	}
+
+	rules := append([]Rule(nil), e.cfg.Rules...)
+	rules[i] = r
+	if err := e.setRules(rules); err != nil {
+		return err
+	}
+	e.resetState(r.ID)
+	return nil
+}
+
+// DeleteRule removes a rule and forgets its alert state
+func (e *Engine) DeleteRule(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	i := e.indexOf(id)
+	if i < 0 {
+		return ErrRuleNotFound
+	}
+
+	rules := append(make([]Rule, 0, len(e.cfg.Rules)-1), e.cfg.Rules[:i]...)
+	rules = append(rules, e.cfg.Rules[i+1:]...)
+	if err := e.setRules(rules); err != nil {
+		return err
+	}
+	e.resetState(id)
+	return nil
+}
+
+func (e *Engine) indexOf(id string) int {
+	for i, r := range e.cfg.Rules {
+		if r.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *Engine) resetState(ruleID string) {
+	prefix := ruleID + "|"
+	for k := range e.alerts {
+		if strings.HasPrefix(k, prefix) {
+			delete(e.alerts, k)
+		}
+	}
+	for k := range e.windows {
+		if strings.HasPrefix(k, prefix) {
+			delete(e.windows, k)
+		}
+	}
+}
+
+// setRules writes the configuration with rules to the file of the engine and, once that
+// succeeded, makes them the live rules; a failed write leaves the rules unchanged
+func (e *Engine) setRules(rules []Rule) error {
+	if e.path != "" {
+		cfg := *e.cfg
+		cfg.Rules = rules
+		if err := SaveConfig(e.path, &cfg); err != nil {
+			return err
+		}
+	}
+	e.cfg.Rules = rules
+	return nil
+}
+
+func matches(match map[string]string, metadata map[string]interface{}) bool {
+	for k, want := range match {
+		got, ok := metadata[k]
+		if !ok || fmt.Sprint(got) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// groupOf returns the dedup key of the alert ev belongs to under rule r
+func groupOf(r *Rule, metadata map[string]interface{}) (string, map[string]string) {
+	key := r.ID + "|"
+	if len(r.GroupBy) == 0 {
+		return key, nil
+	}
+
+	group := make(map[string]string, len(r.GroupBy))
+	parts := make([]string, len(r.GroupBy))
+	for i, k := range r.GroupBy {
+		v := ""
+		if raw, ok := metadata[k]; ok && raw != nil {
+			v = fmt.Sprint(raw)
+		}
+		group[k] = v
+		parts[i] = k + "=" + v
+	}
+	return key + strings.Join(parts, ","), group
+}
+
+func formatGroup(group map[string]string) string {
+	keys := make([]string, 0, len(group))
+	for k := range group {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + group[k]
+	}
+	return strings.Join(parts, ",")
+}
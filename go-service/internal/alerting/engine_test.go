@@ -0,0 +1,158 @@
+package alerting
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestEngine(t *testing.T, path string, rules ...Rule) *Engine {
+	t.Helper()
+	e, err := NewEngine(&Config{
+		Channels: []ChannelConfig{{Name: "hook", Type: "webhook", URL: "http://localhost/hook"}},
+		Rules:    rules,
+	}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// sent returns the notifications queued so far
+func sent(e *Engine) []Alert {
+	var out []Alert
+	for {
+		select {
+		case n := <-e.outbox:
+			out = append(out, n.alert)
+		default:
+			return out
+		}
+	}
+}
+
+func anomaly(service string, at time.Time) Event {
+	return Event{IsAnomaly: true, Score: 0.9, Metadata: map[string]interface{}{"service": service}, Timestamp: at}
+}
+
+func TestEngineGroupsAndResends(t *testing.T) {
+	e := newTestEngine(t, "", Rule{
+		ID: "high", Name: "High score", Type: RuleScore, MinScore: 0.5,
+		GroupBy: []string{"service"}, ResendInterval: Duration(10 * time.Minute), Channels: []string{"hook"},
+	})
+
+	steps := []struct {
+		ev        Event
+		wantKey   string // empty when nothing is sent
+		wantCount int
+		wantTotal int
+	}{
+		{ev: anomaly("api", t0), wantKey: "high|service=api", wantCount: 1, wantTotal: 1},
+		// Within the resend interval, firings are only counted
+		{ev: anomaly("api", t0.Add(time.Minute))},
+		{ev: anomaly("api", t0.Add(2*time.Minute))},
+		// Another group is an alert of its own
+		{ev: anomaly("db", t0.Add(3*time.Minute)), wantKey: "high|service=db", wantCount: 1, wantTotal: 1},
+		// Low scores and normal logs do not fire
+		{ev: Event{IsAnomaly: true, Score: 0.1, Metadata: map[string]interface{}{"service": "api"}, Timestamp: t0.Add(4 * time.Minute)}},
+		{ev: Event{Score: 0.9, Metadata: map[string]interface{}{"service": "api"}, Timestamp: t0.Add(4 * time.Minute)}},
+		// Once the interval passed, the firings since the last notification are sent together
+		{ev: anomaly("api", t0.Add(11*time.Minute)), wantKey: "high|service=api", wantCount: 3, wantTotal: 4},
+	}
+	for i, s := range steps {
+		e.Process(s.ev)
+		got := sent(e)
+		if s.wantKey == "" {
+			if len(got) != 0 {
+				t.Errorf("step %d: sent %+v, want nothing", i, got)
+			}
+			continue
+		}
+		if len(got) != 1 {
+			t.Fatalf("step %d: sent %d notifications, want 1", i, len(got))
+		}
+		a := got[0]
+		if a.DedupKey != s.wantKey || a.Count != s.wantCount || a.Total != s.wantTotal {
+			t.Errorf("step %d: sent %s with count %d and total %d, want %s, %d and %d",
+				i, a.DedupKey, a.Count, a.Total, s.wantKey, s.wantCount, s.wantTotal)
+		}
+	}
+}
+
+func TestEngineCountRule(t *testing.T) {
+	e := newTestEngine(t, "", Rule{
+		ID: "burst", Type: RuleCount, Threshold: 3, Window: Duration(time.Minute), Channels: []string{"hook"},
+	})
+	for i, at := range []time.Duration{0, 30 * time.Second, 2 * time.Minute, 2*time.Minute + 10*time.Second} {
+		e.Process(anomaly("api", t0.Add(at)))
+		if got := sent(e); len(got) != 0 {
+			t.Fatalf("anomaly %d: sent %+v before the threshold was reached in a window", i, got)
+		}
+	}
+	e.Process(anomaly("api", t0.Add(2*time.Minute+20*time.Second)))
+	got := sent(e)
+	if len(got) != 1 || !strings.HasPrefix(got[0].Summary, "3 anomalies within 1m0s") {
+		t.Errorf("sent %+v, want one alert for 3 anomalies", got)
+	}
+}
+
+func TestEngineRuleChangesNeedSave(t *testing.T) {
+	rules := []Rule{
+		{ID: "a", Type: RuleNewTemplate, Channels: []string{"hook"}},
+		{ID: "b", Type: RuleNewTemplate, Channels: []string{"hook"}},
+	}
+	// The directory of the file does not exist, so every save fails
+	e := newTestEngine(t, filepath.Join(t.TempDir(), "missing", "alerting.yaml"), rules...)
+
+	if err := e.AddRule(Rule{ID: "c", Type: RuleNewTemplate, Channels: []string{"hook"}}); err == nil {
+		t.Error("AddRule succeeded without saving")
+	}
+	if err := e.UpdateRule(Rule{ID: "a", Type: RuleNewTemplate, Disabled: true, Channels: []string{"hook"}}); err == nil {
+		t.Error("UpdateRule succeeded without saving")
+	}
+	if err := e.DeleteRule("a"); err == nil {
+		t.Error("DeleteRule succeeded without saving")
+	}
+	if got := e.Rules(); !reflect.DeepEqual(got, rules) {
+		t.Fatalf("rules after failed saves = %+v, want %+v", got, rules)
+	}
+
+	path := filepath.Join(t.TempDir(), "alerting.yaml")
+	e = newTestEngine(t, path, rules...)
+	before := e.Rules()
+	if err := e.DeleteRule("a"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []Rule{rules[1]}; !reflect.DeepEqual(e.Rules(), want) {
+		t.Errorf("rules = %+v, want %+v", e.Rules(), want)
+	}
+	if !reflect.DeepEqual(before, rules) {
+		t.Errorf("an earlier copy of the rules changed to %+v", before)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Rules) != 1 || cfg.Rules[0].ID != "b" {
+		t.Errorf("saved rules = %+v, want only b", cfg.Rules)
+	}
+}
+
+func TestHeaderValue(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"[CRITICAL] Disk full", "[CRITICAL] Disk full"},
+		{"x\r\nBcc: victim@example.com", "x  Bcc: victim@example.com"},
+		{"Überlast", "=?utf-8?q?=C3=9Cberlast?="},
+	}
+	for _, tt := range tests {
+		if got := headerValue(tt.in); got != tt.want {
+			t.Errorf("headerValue(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notifier delivers an alert to one channel
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewNotifier builds the notifier for a channel configuration
+func NewNotifier(cfg ChannelConfig) (Notifier, error) {
+	switch cfg.Type {
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("channel %q: url is required", cfg.Name)
+		}
+		return &WebhookNotifier{URL: cfg.URL, Headers: cfg.Headers}, nil
+	case "slack":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("channel %q: url is required", cfg.Name)
+		}
+		return &SlackNotifier{URL: cfg.URL}, nil
+	case "email":
+		if cfg.SMTPHost == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return nil, fmt.Errorf("channel %q: smtp_host, from and to are required", cfg.Name)
+		}
+		port := cfg.SMTPPort
+		if port == 0 {
+			port = 587
+		}
+		return &EmailNotifier{
+			Addr:     net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(port)),
+			Host:     cfg.SMTPHost,
+			Username: cfg.Username,
+			Password: cfg.Password,
+			From:     cfg.From,
+			To:       cfg.To,
+		}, nil
+	default:
+		return nil, fmt.Errorf("channel %q: unknown type %q, use webhook, slack or email", cfg.Name, cfg.Type)
+	}
+}
+
+// WebhookNotifier POSTs the alert as JSON
+type WebhookNotifier struct {
+	URL     string
+	Headers map[string]string
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, a Alert) error {
+	return postJSON(ctx, n.URL, n.Headers, a)
+}
+
+// SlackNotifier POSTs a Slack-compatible incoming webhook payload
+type SlackNotifier struct {
+	URL string
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, a Alert) error {
+	payload := map[string]interface{}{
+		"text": fmt.Sprintf("*%s*\n%s", a.Title(), a.Summary),
+		"attachments": []map[string]interface{}{
+			{
+				"color": severityColor(a.Severity),
+				"text":  a.Sample.Text,
+				"fields": []map[string]interface{}{
+					{"title": "Occurrences", "value": strconv.Itoa(a.Count), "short": true},
+					{"title": "Dedup key", "value": a.DedupKey, "short": true},
+				},
+				"ts": a.LastSeen.Unix(),
+			},
+		},
+	}
+	return postJSON(ctx, n.URL, nil, payload)
+}
+
+// EmailNotifier sends a plain text email through an SMTP server
+type EmailNotifier struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, a Alert) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", n.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\n", headerValue(a.Title()))
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&body, "%s\r\n\r\n", a.Summary)
+	fmt.Fprintf(&body, "Occurrences: %d\r\nFirst seen: %s\r\nLast seen: %s\r\nDedup key: %s\r\n\r\n",
+		a.Count, a.FirstSeen.Format(time.RFC3339), a.LastSeen.Format(time.RFC3339), a.DedupKey)
+	fmt.Fprintf(&body, "Latest log:\r\n%s\r\n", a.Sample.Text)
+
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+
+	// smtp.SendMail has no context support; run it so ctx can still bound the wait
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(n.Addr, auth, n.From, n.To, []byte(body.String())) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// headerValue makes s safe for a mail header: line breaks, which would let rule names
+// from the API add headers, become spaces, and non-ASCII text is Q-encoded
+func headerValue(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' {
+			return ' '
+		}
+		return r
+	}, s)
+	return mime.QEncoding.Encode("utf-8", s)
+}
+
+func postJSON(ctx context.Context, url string, headers map[string]string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New("unexpected status " + resp.Status)
+	}
+	return nil
+}
+
+func severityColor(severity string) string {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return "danger"
+	case "warning":
+		return "warning"
+	default:
+		return "#439FE0"
+	}
+}
@@ -0,0 +1,176 @@
+package alerting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule types
+const (
+	// RuleCount fires when at least Threshold anomalies of a group arrive within Window
+	RuleCount = "count"
+	// RuleScore fires for every anomaly scored at least MinScore
+	RuleScore = "score"
+	// RuleNewTemplate fires when a log introduces a template never seen before
+	RuleNewTemplate = "new_template"
+)
+
+// Duration is a time.Duration written as "10m" in YAML and JSON
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"10m\": %w", err)
+	}
+	return d.parse(s)
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.parse(value.Value)
+}
+
+func (d *Duration) parse(s string) error {
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Rule describes when an alert fires and where it is sent
+type Rule struct {
+	ID       string            `yaml:"id" json:"id"`
+	Name     string            `yaml:"name" json:"name"`
+	Type     string            `yaml:"type" json:"type"`
+	Disabled bool              `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	Severity string            `yaml:"severity,omitempty" json:"severity,omitempty"`
+	Match    map[string]string `yaml:"match,omitempty" json:"match,omitempty"` // metadata key/value pairs the log must carry
+
+	Threshold int      `yaml:"threshold,omitempty" json:"threshold,omitempty"` // RuleCount
+	Window    Duration `yaml:"window,omitempty" json:"window,omitempty"`       // RuleCount
+	MinScore  float64  `yaml:"min_score,omitempty" json:"min_score,omitempty"` // RuleScore
+
+	// GroupBy lists the metadata keys that split a rule into independent alerts;
+	// together with the rule ID their values form the alert's dedup key
+	GroupBy        []string `yaml:"group_by,omitempty" json:"group_by,omitempty"`
+	ResendInterval Duration `yaml:"resend_interval,omitempty" json:"resend_interval,omitempty"`
+	Channels       []string `yaml:"channels" json:"channels"`
+}
+
+// ChannelConfig describes a notification channel
+type ChannelConfig struct {
+	Name    string            `yaml:"name" json:"name"`
+	Type    string            `yaml:"type" json:"type"` // webhook, slack or email
+	URL     string            `yaml:"url,omitempty" json:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	SMTPHost string   `yaml:"smtp_host,omitempty" json:"smtp_host,omitempty"`
+	SMTPPort int      `yaml:"smtp_port,omitempty" json:"smtp_port,omitempty"`
+	Username string   `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string   `yaml:"password,omitempty" json:"-"`
+	From     string   `yaml:"from,omitempty" json:"from,omitempty"`
+	To       []string `yaml:"to,omitempty" json:"to,omitempty"`
+}
+
+// Config is the content of the alerting YAML file
+type Config struct {
+	Channels []ChannelConfig `yaml:"channels"`
+	Rules    []Rule          `yaml:"rules"`
+}
+
+// LoadConfig reads the alerting configuration; a missing file yields an empty configuration
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alerting config: %w", err)
+	}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse alerting config: %w", err)
+	}
+	return cfg, nil
+}
+
+// SaveConfig atomically writes cfg to path
+func SaveConfig(path string, cfg *Config) error {
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to encode alerting config: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".alerting-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to write alerting config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write alerting config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write alerting config: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Validate checks that the rule is complete and only references known channels
+func (r *Rule) Validate(channels map[string]Notifier) error {
+	if r.ID == "" {
+		return errors.New("rule id is required")
+	}
+
+	switch r.Type {
+	case RuleCount:
+		if r.Threshold < 1 {
+			return errors.New("count rules need a threshold of at least 1")
+		}
+		if r.Window <= 0 {
+			return errors.New("count rules need a positive window")
+		}
+	case RuleScore:
+		if r.MinScore <= 0 {
+			return errors.New("score rules need a positive min_score")
+		}
+	case RuleNewTemplate:
+	default:
+		return fmt.Errorf("unknown rule type %q, use count, score or new_template", r.Type)
+	}
+
+	if len(r.Channels) == 0 {
+		return errors.New("at least one channel is required")
+	}
+	for _, name := range r.Channels {
+		if _, ok := channels[name]; !ok {
+			return fmt.Errorf("unknown channel %q", name)
+		}
+	}
+	return nil
+}
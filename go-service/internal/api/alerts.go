@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"anomaly-detection-platform/go-service/internal/alerting"
+)
+
+// AlertEngine evaluates alert rules on every processed log; when nil, alerting is disabled
+var AlertEngine *alerting.Engine
+
+// GetAlertsHandler lists the alert groups that have fired recently
+func GetAlertsHandler(c *gin.Context) {
+	if AlertEngine == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "alerting not enabled"})
+		return
+	}
+
+	alerts := AlertEngine.Alerts()
+	c.JSON(http.StatusOK, gin.H{
+		"alerts": alerts,
+		"total":  len(alerts),
+	})
+}
+
+// GetAlertRulesHandler lists the alert rules
+func GetAlertRulesHandler(c *gin.Context) {
+	if AlertEngine == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "alerting not enabled"})
+		return
+	}
+
+	rules := AlertEngine.Rules()
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+		"total": len(rules),
+	})
+}
+
+// GetAlertRuleHandler returns a single alert rule
+func GetAlertRuleHandler(c *gin.Context) {
+	if AlertEngine == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "alerting not enabled"})
+		return
+	}
+
+	rule, err := AlertEngine.Rule(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// CreateAlertRuleHandler adds an alert rule
+func CreateAlertRuleHandler(c *gin.Context) {
+	if AlertEngine == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "alerting not enabled"})
+		return
+	}
+
+	var rule alerting.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := AlertEngine.AddRule(rule); err != nil {
+		respondRuleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateAlertRuleHandler replaces an alert rule
+func UpdateAlertRuleHandler(c *gin.Context) {
+	if AlertEngine == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "alerting not enabled"})
+		return
+	}
+
+	var rule alerting.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule.ID = c.Param("id")
+
+	if err := AlertEngine.UpdateRule(rule); err != nil {
+		respondRuleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteAlertRuleHandler removes an alert rule
+func DeleteAlertRuleHandler(c *gin.Context) {
+	if AlertEngine == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "alerting not enabled"})
+		return
+	}
+
+	if err := AlertEngine.DeleteRule(c.Param("id")); err != nil {
+		respondRuleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondRuleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, alerting.ErrRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, alerting.ErrRuleExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
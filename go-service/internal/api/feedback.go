@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+	"anomaly-detection-platform/go-service/internal/feedback"
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// FeedbackTracker turns analyst verdicts into per-detector precision and recall; when nil, they are not tracked
+var FeedbackTracker *feedback.Tracker
+
+// feedbackMu serializes verdicts so replacing one is accounted for exactly once
+var feedbackMu sync.Mutex
+
+// FeedbackRequest is the body of POST /v1/logs/:id/feedback
+type FeedbackRequest struct {
+	Verdict string `json:"verdict" binding:"required"`
+	Comment string `json:"comment,omitempty"`
+	User    string `json:"user,omitempty"`
+}
+
+// TrainingExample is one line of the feedback export
+type TrainingExample struct {
+	LogID      string  `json:"log_id"`
+	Text       string  `json:"text"`
+	ModelLabel string  `json:"model_label"`
+	Score      float64 `json:"score"`
+	HumanLabel string  `json:"human_label"`
+}
+
+// LoadFeedback rebuilds the precision and recall metrics from the stored verdicts
+func LoadFeedback(ctx context.Context) error {
+	if Logs == nil || FeedbackTracker == nil {
+		return nil
+	}
+	return Logs.ScanFeedback(ctx, func(doc elastic.FeedbackDocument) error {
+		FeedbackTracker.Apply(nil, &doc)
+		return nil
+	})
+}
+
+// PostFeedbackHandler records an analyst verdict on a stored log
+func PostFeedbackHandler(c *gin.Context) {
+	if Logs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log store not available"})
+		return
+	}
+
+	var req FeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	verdict, err := feedback.ParseVerdict(req.Verdict)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	logID := c.Param("id")
+
+	feedbackMu.Lock()
+	defer feedbackMu.Unlock()
+
+	stored, err := Logs.GetLog(ctx, logID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to retrieve log: %v", err)})
+		return
+	}
+	if stored == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "log not found"})
+		return
+	}
+
+	prev, err := Logs.GetFeedback(ctx, logID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to retrieve feedback: %v", err)})
+		return
+	}
+
+	doc := &elastic.FeedbackDocument{
+		LogID:      logID,
+		Verdict:    verdict,
+		Comment:    req.Comment,
+		User:       req.User,
+		Timestamp:  time.Now().UTC(),
+		LogText:    stored.LogText,
+		ModelLabel: stored.Label,
+		Score:      stored.Score,
+		IsAnomaly:  stored.IsAnomaly,
+		Detector:   stored.Detector,
+		Detections: stored.Detections,
+	}
+	// The label goes first: a verdict whose feedback could not be stored afterwards is
+	// reported and simply sent again, since setting the same label twice changes nothing
+	if err := Logs.SetHumanLabel(ctx, logID, verdict, doc.Timestamp); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update log label: %v", err)})
+		return
+	}
+	if err := Logs.SaveFeedback(ctx, doc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":         fmt.Sprintf("Failed to store feedback: %v", err),
+			"label_updated": true,
+		})
+		return
+	}
+
+	metrics.FeedbackTotal.WithLabelValues(verdict).Inc()
+	if FeedbackTracker != nil {
+		FeedbackTracker.Apply(prev, doc)
+	}
+
+	c.JSON(http.StatusCreated, doc)
+}
+
+// ExportFeedbackHandler streams the verdicts as a JSONL training set
+func ExportFeedbackHandler(c *gin.Context) {
+	if Logs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log store not available"})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="feedback.jsonl"`)
+	c.Status(http.StatusOK)
+
+	enc := json.NewEncoder(c.Writer)
+	err := Logs.ScanFeedback(c.Request.Context(), func(doc elastic.FeedbackDocument) error {
+		modelLabel := doc.ModelLabel
+		if modelLabel == "" {
+			modelLabel = feedback.VerdictNormal
+			if doc.IsAnomaly {
+				modelLabel = feedback.VerdictAnomaly
+			}
+		}
+		return enc.Encode(TrainingExample{
+			LogID:      doc.LogID,
+			Text:       doc.LogText,
+			ModelLabel: modelLabel,
+			Score:      doc.Score,
+			HumanLabel: doc.Verdict,
+		})
+	})
+	if err != nil {
+		// The status line is already sent, so the export just ends early
+		log.Printf("Failed to export feedback: %v", err)
+	}
+}
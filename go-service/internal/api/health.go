@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler reports that the service is up, along with the state of Elasticsearch and
+// the backlog of the write-ahead log. Elasticsearch being down does not make the service
+// unhealthy: logs are still accepted and kept in the write-ahead log.
+func HealthHandler(c *gin.Context) {
+	resp := gin.H{"status": "ok"}
+	if ESClient != nil {
+		resp["elasticsearch"] = "down"
+		if ESClient.Healthy() {
+			resp["elasticsearch"] = "up"
+		}
+	}
+	if WAL != nil {
+		records, size := WAL.Backlog()
+		resp["wal"] = gin.H{
+			"backlog_records": records,
+			"backlog_bytes":   size,
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
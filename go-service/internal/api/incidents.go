@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+	"anomaly-detection-platform/go-service/internal/incident"
+)
+
+// Incidents groups anomalies into incidents; when nil, anomalies are not grouped
+var Incidents *incident.Manager
+
+var errCommentRequired = errors.New("'comment' is required")
+
+// IncidentActionRequest is the body of the acknowledge, resolve and comment endpoints
+type IncidentActionRequest struct {
+	User     string `json:"user"`
+	Assignee string `json:"assignee,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// FlushIncidents stores the incidents updated by new anomalies
+func FlushIncidents(ctx context.Context) error {
+	if Incidents == nil {
+		return nil
+	}
+	return Incidents.Flush(ctx)
+}
+
+// GetIncidentsHandler lists incidents, optionally filtered by status
+func GetIncidentsHandler(c *gin.Context) {
+	if Incidents == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "incidents not enabled"})
+		return
+	}
+
+	from, size, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", incident.StatusOpen, incident.StatusAcknowledged, incident.StatusResolved:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'status' parameter, use open, acknowledged or resolved"})
+		return
+	}
+
+	page, err := Incidents.List(c.Request.Context(), elastic.IncidentQuery{Status: status, From: from, Size: size, Cursor: c.Query("cursor")})
+	if err != nil {
+		respondPageError(c, "Failed to retrieve incidents", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"incidents":   page.Incidents,
+		"total":       page.Total,
+		"from":        from,
+		"size":        size,
+		"next_cursor": page.NextCursor,
+	})
+}
+
+// GetIncidentHandler returns a single incident with its timeline
+func GetIncidentHandler(c *gin.Context) {
+	if Incidents == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "incidents not enabled"})
+		return
+	}
+
+	inc, err := Incidents.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondIncidentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, inc)
+}
+
+// GetIncidentLogsHandler lists the anomalies grouped into an incident
+func GetIncidentLogsHandler(c *gin.Context) {
+	if Logs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log store not available"})
+		return
+	}
+
+	from, size, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	page, err := Logs.PageLogs(c.Request.Context(), elastic.LogQuery{IncidentID: c.Param("id"), From: from, Size: size, Cursor: c.Query("cursor")})
+	if err != nil {
+		respondPageError(c, "Failed to retrieve logs", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logs":        page.Logs,
+		"total":       page.Total,
+		"from":        from,
+		"size":        size,
+		"next_cursor": page.NextCursor,
+	})
+}
+
+// AcknowledgeIncidentHandler marks an incident as acknowledged and optionally assigns it
+func AcknowledgeIncidentHandler(c *gin.Context) {
+	incidentAction(c, false, func(ctx context.Context, id string, req IncidentActionRequest) (incident.Incident, error) {
+		return Incidents.Acknowledge(ctx, id, req.User, req.Assignee, req.Comment)
+	})
+}
+
+// ResolveIncidentHandler resolves an incident
+func ResolveIncidentHandler(c *gin.Context) {
+	incidentAction(c, false, func(ctx context.Context, id string, req IncidentActionRequest) (incident.Incident, error) {
+		return Incidents.Resolve(ctx, id, req.User, req.Comment)
+	})
+}
+
+// CommentIncidentHandler adds a comment to an incident's timeline
+func CommentIncidentHandler(c *gin.Context) {
+	incidentAction(c, true, func(ctx context.Context, id string, req IncidentActionRequest) (incident.Incident, error) {
+		if req.Comment == "" {
+			return incident.Incident{}, errCommentRequired
+		}
+		return Incidents.Comment(ctx, id, req.User, req.Comment)
+	})
+}
+
+func incidentAction(c *gin.Context, bodyRequired bool, action func(ctx context.Context, id string, req IncidentActionRequest) (incident.Incident, error)) {
+	if Incidents == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "incidents not enabled"})
+		return
+	}
+
+	var req IncidentActionRequest
+	if bodyRequired || c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	inc, err := action(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		respondIncidentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, inc)
+}
+
+func respondIncidentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, incident.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, incident.ErrResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errCommentRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update incident: %v", err)})
+	}
+}
@@ -1,26 +1,74 @@
 package api
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"anomaly-detection-platform/go-service/internal/alerting"
 	"anomaly-detection-platform/go-service/internal/client"
+	"anomaly-detection-platform/go-service/internal/detection"
 	"anomaly-detection-platform/go-service/internal/elastic"
+	"anomaly-detection-platform/go-service/internal/feedback"
+	"anomaly-detection-platform/go-service/internal/incident"
+	"anomaly-detection-platform/go-service/internal/ingest"
+	"anomaly-detection-platform/go-service/internal/kafka"
 	"anomaly-detection-platform/go-service/internal/metrics"
+	"anomaly-detection-platform/go-service/internal/parsing"
 	"anomaly-detection-platform/go-service/internal/preprocessing"
+	"anomaly-detection-platform/go-service/internal/storage"
+	"anomaly-detection-platform/go-service/internal/wal"
 	"anomaly-detection-platform/go-service/pkg/config"
 )
 
 // Global Elasticsearch client - should be initialized in main.go
 var ESClient *elastic.Client
 
+// Logs stores processed logs and serves the log, search and stats endpoints; it is ESClient
+// or an embedded store, depending on STORAGE_BACKEND
+var Logs storage.LogStore
+
+// Indexer batches the writes of processed logs to Elasticsearch; when nil, every log is written by Logs.IndexLog
+var Indexer *elastic.BulkIndexer
+
+// WAL keeps processed logs on disk while Elasticsearch is unreachable, until ReplayDocuments indexes them; when nil, such logs are lost
+var WAL *wal.WAL
+
+// IngestQueue buffers logs accepted by LogsHandler; when nil, logs are processed inline
+var IngestQueue *ingest.Queue
+
+// Multiline joins stack traces and other multiline messages before they are processed; when nil, every log stands alone
+var Multiline *ingest.Assembler
+
+// SyncConcurrency bounds how many logs of a ?sync=true request are processed at once
+var SyncConcurrency = 16
+
+// Detector classifies every ingested log; when nil, the Python classifier is called directly
+var Detector detection.Detector
+
+// LogParser extracts the event time, level, logger and fields of every log; when nil, logs are not parsed
+var LogParser *parsing.Parser
+
+// ResultProducer publishes the detection result of every processed log to Kafka; when nil, results are not published
+var ResultProducer *kafka.Producer
+
+// PublishAnomaliesOnly limits the published results to logs flagged as anomalies
+var PublishAnomaliesOnly bool
+
+// RetryAfter is advertised to callers when the ingestion queue is full
+var RetryAfter = time.Second
+
 // Request/response types can stay here or move to pkg/models
 type LogRequest struct {
 	Text     string                 `json:"text" binding:"required"`
@@ -33,8 +81,29 @@ type LogResponse struct {
 	ContentType   string                 `json:"content_type"`
 	Metadata      map[string]interface{} `json:"metadata,omitempty"`
 	ReceivedAtUTC time.Time              `json:"received_at_utc"`
+	Timestamp     time.Time              `json:"timestamp"` // event time, or ReceivedAtUTC when the log carries none
+	Format        string                 `json:"log_format,omitempty"`
+	Level         string                 `json:"level,omitempty"`
+	Logger        string                 `json:"logger,omitempty"`
+	Fields        map[string]interface{} `json:"fields,omitempty"`
+	TraceID       string                 `json:"trace_id,omitempty"`
+	SpanID        string                 `json:"span_id,omitempty"`
 	Label         string                 `json:"label,omitempty"`
 	Score         float64                `json:"score,omitempty"`
+	Detector      string                 `json:"detector,omitempty"`
+	Reason        string                 `json:"reason,omitempty"`
+	TemplateID    string                 `json:"template_id,omitempty"`
+	IncidentID    string                 `json:"incident_id,omitempty"`
+	Suppressed    bool                   `json:"suppressed,omitempty"`
+	SuppressionID string                 `json:"suppression_id,omitempty"`
+	Dropped       bool                   `json:"dropped,omitempty"`
+}
+
+// DetectionResult is the message published to Kafka for a processed log
+type DetectionResult struct {
+	ID        string `json:"id"`
+	IsAnomaly bool   `json:"is_anomaly"`
+	LogResponse
 }
 
 func LogsHandler(c *gin.Context) {
@@ -92,93 +161,466 @@ func LogsHandler(c *gin.Context) {
 }
 
 func respondBatch(c *gin.Context, contentType string, logs []LogRequest) {
-	results := make([]LogResponse, len(logs))
-	ctx := c.Request.Context()
+	batchID := newBatchID()
+	receivedAt := time.Now().UTC()
+
+	events := make([]ingest.Event, len(logs))
+	for i, lr := range logs {
+		events[i] = ingest.Event{
+			ID:          fmt.Sprintf("%s-%d", batchID, i),
+			BatchID:     batchID,
+			Text:        lr.Text,
+			ContentType: contentType,
+			Metadata:    lr.Metadata,
+			ReceivedAt:  receivedAt,
+		}
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(len(logs))
+	// Callers that need the label inline can opt out of the queue with ?sync=true
+	inline, _ := strconv.ParseBool(c.Query("sync"))
+	if inline || IngestQueue == nil {
+		if Multiline != nil {
+			events = Multiline.Join(events)
+		}
+		respondSync(c, events)
+		return
+	}
 
-	for i, logReq := range logs {
-		go func(i int, lr LogRequest) {
-			defer wg.Done()
-			start := time.Now()
+	err := enqueue(events)
+	switch {
+	case errors.Is(err, ingest.ErrQueueFull):
+		c.Header("Retry-After", strconv.Itoa(int(RetryAfter.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "ingestion queue is full, retry later"})
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{
+			"accepted": true,
+			"batch_id": batchID,
+			"count":    len(logs),
+		})
+	}
+}
 
-			cleaned := preprocessing.PreprocessLogText(lr.Text)
+// respondSync processes the events within the request
+func respondSync(c *gin.Context, events []ingest.Event) {
+	results, _ := processAll(c.Request.Context(), events)
+	if len(results) == 1 {
+		c.JSON(http.StatusOK, results[0])
+	} else {
+		c.JSON(http.StatusOK, results)
+	}
+}
 
-			resp := LogResponse{
-				Accepted:      true,
-				Text:          cleaned,
-				ContentType:   contentType,
-				Metadata:      lr.Metadata,
-				ReceivedAtUTC: time.Now().UTC(),
-			}
+// processAll processes events at most SyncConcurrency at a time, returning the first
+// storage error alongside the results
+func processAll(ctx context.Context, events []ingest.Event) ([]LogResponse, error) {
+	results := make([]LogResponse, len(events))
+	errs := make([]error, len(events))
+	forEach(events, func(i int, ev ingest.Event) {
+		results[i], errs[i] = processLog(ctx, ev)
+	})
 
-			cctx, cancel := config.WithTimeout(ctx, 4*time.Second)
-			defer cancel()
-			if label, score, err := client.CallPythonPredict(cctx, cleaned); err == nil {
-				resp.Label = label
-				resp.Score = score
+	failed := 0
+	var first error
+	for _, err := range errs {
+		if err != nil {
+			if first == nil {
+				first = err
 			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d logs not stored: %w", failed, len(events), first)
+	}
+	return results, nil
+}
 
-			// Determine anomaly from ML result regardless of Elasticsearch availability
-			isAnomaly := resp.Label == "anomaly" || resp.Score > 0.5
-
-			// Store in Elasticsearch if client is available
-			if ESClient != nil {
-				doc := &elastic.LogDocument{
-					ID:        fmt.Sprintf("%d", time.Now().UnixNano()+int64(i)), // Simple ID generation
-					Timestamp: resp.ReceivedAtUTC,
-					LogText:   cleaned,
-					IsAnomaly: isAnomaly,
-				}
-
-				if err := ESClient.IndexLog(cctx, doc); err != nil {
-					// Log error but don't fail the request
-					fmt.Printf("Failed to index log in Elasticsearch: %v\n", err)
-				}
-			}
+// forEach calls fn for every event, at most SyncConcurrency at a time
+func forEach(events []ingest.Event, fn func(i int, ev ingest.Event)) {
+	sem := make(chan struct{}, max(SyncConcurrency, 1))
+	var wg sync.WaitGroup
+	wg.Add(len(events))
+
+	for i, ev := range events {
+		sem <- struct{}{}
+		go func(i int, ev ingest.Event) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i, ev)
+		}(i, ev)
+	}
+	wg.Wait()
+}
 
-			// Prometheus metrics
-			metrics.LogsProcessedTotal.WithLabelValues(contentType).Inc()
-			if isAnomaly {
-				metrics.AnomaliesTotal.WithLabelValues(contentType).Inc()
-			}
-			metrics.ProcessingLatency.WithLabelValues(contentType).Observe(time.Since(start).Seconds())
+// IngestEvents sends events received by the network inputs through the same path as
+// POST /v1/logs. Events without an ID get one, like the logs of a request. Without a
+// queue, the events are processed within the call and the first storage error is
+// returned, so that inputs can withhold their acknowledgement.
+func IngestEvents(ctx context.Context, events []ingest.Event) error {
+	stampEvents(events)
 
-			results[i] = resp
-		}(i, logReq)
+	if IngestQueue == nil {
+		if Multiline != nil {
+			events = Multiline.Join(events)
+		}
+		_, err := processAll(ctx, events)
+		return err
 	}
+	return enqueue(events)
+}
 
-	wg.Wait()
-	if len(results) == 1 {
-		c.JSON(http.StatusOK, results[0])
+// enqueue queues events for the workers. The last event of every multiline stream waits
+// for its continuation lines, possibly in later requests; the streams only change when
+// the completed events fit in the queue.
+func enqueue(events []ingest.Event) error {
+	if Multiline != nil {
+		return Multiline.Add(events, IngestQueue.Enqueue)
+	}
+	return IngestQueue.Enqueue(events)
+}
+
+// ProcessEvents runs events through the pipeline within the call, bypassing the queue,
+// and returns once the logs are stored: a nil error means they are all in the log store,
+// or in the write-ahead log. Inputs that acknowledge their source only once logs are
+// stored, like the fluent forward acks and OTLP, use it. Multiline messages are only
+// joined within events.
+func ProcessEvents(ctx context.Context, events []ingest.Event) error {
+	return ProcessBatch(ctx, events)(ctx)
+}
+
+// ProcessBatch runs events through the pipeline like ProcessEvents, but hands back the
+// storing of the logs: the returned function stores them synchronously and may be called
+// again when it fails, without the alerts, incidents and published results of the logs
+// being repeated. The Kafka consumer uses it to retry a batch until it is stored.
+func ProcessBatch(ctx context.Context, events []ingest.Event) func(context.Context) error {
+	stampEvents(events)
+	if Multiline != nil {
+		events = Multiline.Join(events)
+	}
+
+	docs := make([]*elastic.LogDocument, len(events))
+	forEach(events, func(i int, ev ingest.Event) {
+		_, docs[i] = analyzeLog(ctx, ev)
+	})
+	stored := docs[:0]
+	for _, doc := range docs {
+		if doc != nil {
+			stored = append(stored, doc)
+		}
+	}
+	return func(ctx context.Context) error {
+		return storeLogs(ctx, stored)
+	}
+}
+
+// stampEvents gives events without an ID or receive time those of a new batch
+func stampEvents(events []ingest.Event) {
+	batchID := newBatchID()
+	receivedAt := time.Now().UTC()
+	for i := range events {
+		if events[i].ID == "" {
+			events[i].ID = fmt.Sprintf("%s-%d", batchID, i)
+			events[i].BatchID = batchID
+		}
+		if events[i].ReceivedAt.IsZero() {
+			events[i].ReceivedAt = receivedAt
+		}
+	}
+}
+
+// FlushMultiline queues the multiline events that waited for more lines longer than
+// their timeout, or all of them at shutdown. Expired events that do not fit in the queue
+// stay pending until the next flush.
+func FlushMultiline(ctx context.Context, all bool) error {
+	if Multiline == nil {
+		return nil
+	}
+	process := func(events []ingest.Event) error {
+		for _, ev := range events {
+			processLog(ctx, ev)
+		}
+		return nil
+	}
+	if IngestQueue != nil {
+		process = IngestQueue.Enqueue
+	}
+
+	if !all {
+		if err := Multiline.FlushExpired(time.Now().UTC(), process); err != nil {
+			return fmt.Errorf("multiline events not flushed: %w", err)
+		}
+		return nil
+	}
+	events := Multiline.Flush()
+	if len(events) == 0 {
+		return nil
+	}
+	if err := process(events); err != nil {
+		return fmt.Errorf("%d multiline events lost: %w", len(events), err)
+	}
+	return nil
+}
+
+// ProcessEvent runs a queued event through the pipeline; it is the worker function of IngestQueue
+func ProcessEvent(ctx context.Context, ev ingest.Event) {
+	processLog(ctx, ev)
+}
+
+// processLog preprocesses, classifies and stores a single event. The error reports a
+// failure to store it; the response is complete either way.
+func processLog(ctx context.Context, ev ingest.Event) (LogResponse, error) {
+	resp, doc := analyzeLog(ctx, ev)
+	if doc == nil {
+		return resp, nil
+	}
+
+	sctx, cancel := config.WithTimeout(ctx, 4*time.Second)
+	defer cancel()
+	if err := storeLog(sctx, doc); err != nil {
+		// Log error but don't fail the request
+		fmt.Printf("Failed to store log: %v\n", err)
+		return resp, err
+	}
+	return resp, nil
+}
+
+// analyzeLog runs an event through the pipeline short of storing it: it is preprocessed
+// and classified, and the incidents, alerts, volume counters and published results are
+// updated. It returns the document to store, nil when the log was dropped or there is no
+// log store.
+func analyzeLog(ctx context.Context, ev ingest.Event) (LogResponse, *elastic.LogDocument) {
+	start := time.Now()
+
+	// Redacted values never reach the detectors, Elasticsearch or alert notifications
+	if Redactor != nil {
+		ev.Text = Redactor.Redact(ev.Text)
+	}
+
+	// Recognized formats give the log its own event time, level, logger and fields;
+	// the ones set by the input the log came through take precedence
+	var parsed parsing.Record
+	eventTime := ev.ReceivedAt
+	if LogParser != nil {
+		if rec, ok := LogParser.Parse(ev.Text, ev.ReceivedAt); ok {
+			parsed = rec
+			if !rec.Time.IsZero() {
+				eventTime = rec.Time
+			}
+		}
+	}
+	if !ev.Time.IsZero() {
+		eventTime = ev.Time.UTC()
+	}
+	if ev.Level != "" {
+		parsed.Level = parsing.NormalizeLevel(ev.Level)
+	}
+
+	var pre preprocessing.Result
+	if Preprocessor != nil {
+		pre = Preprocessor.Process(ev.Text, ev.Metadata)
 	} else {
-		c.JSON(http.StatusOK, results)
+		pre = preprocessing.Result{Pipeline: preprocessing.DefaultPipeline, Text: preprocessing.PreprocessLogText(ev.Text)}
+	}
+	cleaned := pre.Text
+
+	resp := LogResponse{
+		Accepted:      true,
+		Text:          cleaned,
+		ContentType:   ev.ContentType,
+		Metadata:      ev.Metadata,
+		ReceivedAtUTC: ev.ReceivedAt,
+		Timestamp:     eventTime,
+		Format:        parsed.Format,
+		Level:         parsed.Level,
+		Logger:        parsed.Logger,
+		Fields:        parsed.Fields,
+		TraceID:       ev.TraceID,
+		SpanID:        ev.SpanID,
 	}
+
+	// Dropped logs are acknowledged but neither classified nor stored
+	if pre.Dropped {
+		metrics.LogsDroppedTotal.WithLabelValues(pre.Pipeline, pre.DroppedBy).Inc()
+		resp.Dropped = true
+		return resp, nil
+	}
+	if len(pre.Fields) > 0 {
+		ev.Metadata = mergeFields(ev.Metadata, pre.Fields)
+		resp.Metadata = ev.Metadata
+	}
+
+	var match preprocessing.Match
+	if TemplateMiner != nil {
+		match = TemplateMiner.Add(cleaned, ev.ReceivedAt)
+		resp.TemplateID = match.TemplateID
+	}
+
+	cctx, cancel := config.WithTimeout(ctx, 4*time.Second)
+	defer cancel()
+
+	detector := Detector
+	if detector == nil {
+		detector = detection.NewPythonDetector(client.CallPythonPredict, 0.5)
+	}
+	verdict, err := detector.Detect(cctx, detection.LogEvent{
+		ID:           ev.ID,
+		Text:         cleaned,
+		OriginalText: ev.Text,
+		ContentType:  ev.ContentType,
+		Metadata:     ev.Metadata,
+		Timestamp:    eventTime,
+		TraceID:      ev.TraceID,
+		SpanID:       ev.SpanID,
+
+		TemplateID:       match.TemplateID,
+		TemplateCount:    match.Count,
+		TemplatePrevSeen: match.PrevSeen,
+	})
+	if err == nil {
+		resp.Label = verdict.Label
+		resp.Score = verdict.Score
+		resp.Detector = verdict.Detector
+		resp.Reason = verdict.Reason
+	}
+	recordVerdict(verdict, err, detector.Name())
+
+	// A detector failure leaves the log unlabelled rather than failing the request
+	isAnomaly := err == nil && verdict.IsAnomaly
+
+	// Known noise keeps its verdict but is not reported as an anomaly
+	var suppressionID string
+	if isAnomaly && Suppressions != nil {
+		if id, ok := Suppressions.Match(cleaned, match.TemplateID, ev.Metadata, ev.ReceivedAt); ok {
+			suppressionID = id
+			resp.Suppressed = true
+			resp.SuppressionID = id
+			metrics.AnomaliesSuppressedTotal.WithLabelValues(id).Inc()
+		}
+	}
+	flagged := isAnomaly && suppressionID == ""
+
+	var incidentID string
+	if flagged && Incidents != nil {
+		incidentID = Incidents.Record(incident.Anomaly{
+			LogID:      ev.ID,
+			Text:       cleaned,
+			TemplateID: match.TemplateID,
+			Metadata:   ev.Metadata,
+			Timestamp:  ev.ReceivedAt,
+		})
+		resp.IncidentID = incidentID
+	}
+
+	// The log is stored if a log store is available
+	var doc *elastic.LogDocument
+	if Logs != nil {
+		doc = &elastic.LogDocument{
+			ID:           ev.ID,
+			BatchID:      ev.BatchID,
+			Timestamp:    eventTime,
+			ReceivedAt:   ev.ReceivedAt,
+			LogText:      cleaned,
+			OriginalText: ev.Text,
+			IsAnomaly:    isAnomaly,
+			Label:        resp.Label,
+			Score:        resp.Score,
+			Detector:     resp.Detector,
+			Reason:       resp.Reason,
+			Detections:   detectorResults(verdict),
+			ContentType:  ev.ContentType,
+			TemplateID:   match.TemplateID,
+			Params:       match.Params,
+			IncidentID:   incidentID,
+			Metadata:     ev.Metadata,
+			Format:       parsed.Format,
+			Level:        parsed.Level,
+			Logger:       parsed.Logger,
+			Fields:       parsed.Fields,
+			TraceID:      ev.TraceID,
+			SpanID:       ev.SpanID,
+
+			EffectiveLabel: effectiveLabel(isAnomaly),
+			Suppressed:     suppressionID != "",
+			SuppressionID:  suppressionID,
+		}
+	}
+
+	if VolumeMonitor != nil {
+		VolumeMonitor.Observe(ev.Metadata)
+	}
+
+	if AlertEngine != nil {
+		AlertEngine.Process(alerting.Event{
+			LogID:       ev.ID,
+			Text:        ev.Text,
+			IsAnomaly:   flagged,
+			Score:       resp.Score,
+			Detector:    resp.Detector,
+			Reason:      resp.Reason,
+			TemplateID:  match.TemplateID,
+			NewTemplate: match.New,
+			Metadata:    ev.Metadata,
+			TraceID:     ev.TraceID,
+			Timestamp:   ev.ReceivedAt,
+		})
+	}
+
+	if ResultProducer != nil && (flagged || !PublishAnomaliesOnly) {
+		ResultProducer.Publish(ev.ID, DetectionResult{ID: ev.ID, IsAnomaly: flagged, LogResponse: resp})
+	}
+
+	// Prometheus metrics
+	metrics.LogsProcessedTotal.WithLabelValues(ev.ContentType).Inc()
+	if flagged {
+		metrics.AnomaliesTotal.WithLabelValues(ev.ContentType).Inc()
+	}
+	metrics.ProcessingLatency.WithLabelValues(ev.ContentType).Observe(time.Since(start).Seconds())
+
+	return resp, doc
 }
 
-// GetAnomaliesHandler retrieves all logs flagged as anomalies
-func GetAnomaliesHandler(c *gin.Context) {
-	if ESClient == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Elasticsearch not available"})
-		return
+// mergeFields returns a copy of metadata extended with the fields extracted during
+// preprocessing; caller-supplied metadata wins over extracted fields
+func mergeFields(metadata map[string]interface{}, fields map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(metadata)+len(fields))
+	for k, v := range fields {
+		out[k] = v
+	}
+	for k, v := range metadata {
+		out[k] = v
 	}
+	return out
+}
+
+// effectiveLabel is the label a log carries until an analyst gives feedback on it
+func effectiveLabel(isAnomaly bool) string {
+	if isAnomaly {
+		return feedback.VerdictAnomaly
+	}
+	return feedback.VerdictNormal
+}
 
-	// Parse query parameters
+// parsePagination reads the from/size query parameters, capping size at 100.
+// It writes a 400 response and returns false when they are invalid.
+func parsePagination(c *gin.Context) (int, int, bool) {
 	from := 0
 	size := 20
 
 	if fromStr := c.Query("from"); fromStr != "" {
-		if f, err := fmt.Sscanf(fromStr, "%d", &from); err != nil || f != 1 {
+		if f, err := fmt.Sscanf(fromStr, "%d", &from); err != nil || f != 1 || from < 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'from' parameter"})
-			return
+			return 0, 0, false
 		}
 	}
 
 	if sizeStr := c.Query("size"); sizeStr != "" {
-		if s, err := fmt.Sscanf(sizeStr, "%d", &size); err != nil || s != 1 {
+		if s, err := fmt.Sscanf(sizeStr, "%d", &size); err != nil || s != 1 || size < 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'size' parameter"})
-			return
+			return 0, 0, false
 		}
 	}
 
@@ -186,109 +628,198 @@ func GetAnomaliesHandler(c *gin.Context) {
 	if size > 100 {
 		size = 100
 	}
+	return from, size, true
+}
+
+// respondPageError answers a failed log listing, with 400 for a cursor that cannot be continued
+func respondPageError(c *gin.Context, msg string, err error) {
+	if errors.Is(err, elastic.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("%s: %v", msg, err)})
+}
 
-	anomalies, err := ESClient.GetAnomalies(c.Request.Context(), from, size)
+// recordVerdict updates the per-detector metrics, including the individual votes of an ensemble
+func recordVerdict(v detection.Verdict, err error, name string) {
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to retrieve anomalies: %v", err)})
+		metrics.DetectorErrorsTotal.WithLabelValues(name).Inc()
 		return
 	}
+	for _, vote := range v.Votes {
+		recordVerdict(vote, nil, vote.Detector)
+	}
+	metrics.DetectorVerdictsTotal.WithLabelValues(v.Detector, strconv.FormatBool(v.IsAnomaly)).Inc()
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"anomalies": anomalies,
-		"total":     len(anomalies),
-		"from":      from,
-		"size":      size,
-	})
+// detectorResults flattens a verdict into the per-detector results stored with the log
+func detectorResults(v detection.Verdict) []elastic.DetectorResult {
+	if v.Detector == "" {
+		return nil
+	}
+	votes := v.Votes
+	if len(votes) == 0 {
+		votes = []detection.Verdict{v}
+	}
+	out := make([]elastic.DetectorResult, len(votes))
+	for i, vote := range votes {
+		out[i] = elastic.DetectorResult{
+			Detector:  vote.Detector,
+			Label:     vote.Label,
+			Score:     vote.Score,
+			IsAnomaly: vote.IsAnomaly,
+			Reason:    vote.Reason,
+		}
+	}
+	return out
 }
 
-// GetLogsHandler retrieves logs with optional time range filtering
-func GetLogsHandler(c *gin.Context) {
-	if ESClient == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Elasticsearch not available"})
-		return
+// newBatchID returns a random identifier for an ingestion request
+func newBatchID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
 	}
+	return hex.EncodeToString(b)
+}
 
-	// Parse query parameters
-	from := 0
-	size := 20
+// storeLog writes a processed log to the log store. While Elasticsearch is unreachable, or
+// older logs are still waiting in the write-ahead log, it goes to the write-ahead log, so
+// that logs reach Elasticsearch in the order they were processed.
+func storeLog(ctx context.Context, doc *elastic.LogDocument) error {
+	if WAL != nil && (WAL.Pending() || !ESClient.Healthy()) {
+		d, err := elastic.NewDocument(ESClient.LogIndex(doc), doc.ID, doc)
+		if err != nil {
+			return err
+		}
+		return WAL.Append([]elastic.Document{d})
+	}
+	if Indexer != nil {
+		return Indexer.IndexLog(ctx, doc)
+	}
+	return Logs.IndexLog(ctx, doc)
+}
 
-	if fromStr := c.Query("from"); fromStr != "" {
-		if f, err := fmt.Sscanf(fromStr, "%d", &from); err != nil || f != 1 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'from' parameter"})
-			return
+// storeLogs writes processed logs to the log store and returns once they are stored,
+// unlike storeLog, which may leave them in the queue of the bulk indexer. While
+// Elasticsearch is unreachable, or older logs are waiting in the write-ahead log, they are
+// appended to the write-ahead log instead.
+func storeLogs(ctx context.Context, docs []*elastic.LogDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	if ESClient == nil {
+		values := make([]elastic.LogDocument, len(docs))
+		for i, doc := range docs {
+			values[i] = *doc
 		}
+		return Logs.BulkIndexLogs(ctx, values)
 	}
 
-	if sizeStr := c.Query("size"); sizeStr != "" {
-		if s, err := fmt.Sscanf(sizeStr, "%d", &size); err != nil || s != 1 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'size' parameter"})
-			return
+	serialized := make([]elastic.Document, len(docs))
+	for i, doc := range docs {
+		d, err := elastic.NewDocument(ESClient.LogIndex(doc), doc.ID, doc)
+		if err != nil {
+			return err
 		}
+		serialized[i] = d
 	}
+	if WAL != nil && (WAL.Pending() || !ESClient.Healthy()) {
+		return WAL.Append(serialized)
+	}
+	return ESClient.IndexDocuments(ctx, serialized)
+}
 
-	// Limit size to prevent abuse
-	if size > 100 {
-		size = 100
+// ReplayDocuments indexes documents replayed from the write-ahead log and returns once
+// Elasticsearch has them, so that the log only moves past documents that are stored
+func ReplayDocuments(ctx context.Context, docs []elastic.Document) error {
+	return ESClient.IndexDocuments(ctx, docs)
+}
+
+// GetAnomaliesHandler retrieves all logs flagged as anomalies
+func GetAnomaliesHandler(c *gin.Context) {
+	if Logs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log store not available"})
+		return
+	}
+
+	from, size, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	includeSuppressed, ok := parseIncludeSuppressed(c)
+	if !ok {
+		return
+	}
+
+	page, err := Logs.PageLogs(c.Request.Context(), elastic.LogQuery{AnomaliesOnly: true, IncludeSuppressed: includeSuppressed, From: from, Size: size, Cursor: c.Query("cursor")})
+	if err != nil {
+		respondPageError(c, "Failed to retrieve anomalies", err)
+		return
 	}
 
-	var logs []elastic.LogDocument
-	var err error
+	c.JSON(http.StatusOK, gin.H{
+		"anomalies":   page.Logs,
+		"total":       page.Total,
+		"from":        from,
+		"size":        size,
+		"next_cursor": page.NextCursor,
+	})
+}
+
+// GetLogsHandler retrieves logs with optional time range filtering
+func GetLogsHandler(c *gin.Context) {
+	if Logs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log store not available"})
+		return
+	}
+
+	from, size, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	// Without a time range, all logs are listed
+	query := elastic.LogQuery{From: from, Size: size, Cursor: c.Query("cursor")}
 
-	// Check for time range parameters
 	startTimeStr := c.Query("start_time")
 	endTimeStr := c.Query("end_time")
 
 	if startTimeStr != "" && endTimeStr != "" {
-		startTime, err := time.Parse(time.RFC3339, startTimeStr)
+		var err error
+		query.Start, err = time.Parse(time.RFC3339, startTimeStr)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'start_time' format, use RFC3339"})
 			return
 		}
 
-		endTime, err := time.Parse(time.RFC3339, endTimeStr)
+		query.End, err = time.Parse(time.RFC3339, endTimeStr)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'end_time' format, use RFC3339"})
 			return
 		}
-
-		logs, err = ESClient.GetLogsByTimeRange(c.Request.Context(), startTime, endTime, from, size)
-	} else {
-		// Get all logs
-		query := map[string]interface{}{
-			"query": map[string]interface{}{
-				"match_all": map[string]interface{}{},
-			},
-			"sort": []map[string]interface{}{
-				{
-					"received_at_utc": map[string]interface{}{
-						"order": "desc",
-					},
-				},
-			},
-			"from": from,
-			"size": size,
-		}
-
-		logs, err = ESClient.SearchLogs(c.Request.Context(), query)
 	}
 
+	page, err := Logs.PageLogs(c.Request.Context(), query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to retrieve logs: %v", err)})
+		respondPageError(c, "Failed to retrieve logs", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"logs":  logs,
-		"total": len(logs),
-		"from":  from,
-		"size":  size,
+		"logs":        page.Logs,
+		"total":       page.Total,
+		"from":        from,
+		"size":        size,
+		"next_cursor": page.NextCursor,
 	})
 }
 
 // SearchAnomaliesHandler searches for anomalies containing specific text
 func SearchAnomaliesHandler(c *gin.Context) {
-	if ESClient == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Elasticsearch not available"})
+	if Logs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log store not available"})
 		return
 	}
 
@@ -298,47 +829,36 @@ func SearchAnomaliesHandler(c *gin.Context) {
 		return
 	}
 
-	// Parse pagination parameters
-	from := 0
-	size := 20
-
-	if fromStr := c.Query("from"); fromStr != "" {
-		if f, err := fmt.Sscanf(fromStr, "%d", &from); err != nil || f != 1 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'from' parameter"})
-			return
-		}
-	}
-
-	if sizeStr := c.Query("size"); sizeStr != "" {
-		if s, err := fmt.Sscanf(sizeStr, "%d", &size); err != nil || s != 1 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'size' parameter"})
-			return
-		}
+	from, size, ok := parsePagination(c)
+	if !ok {
+		return
 	}
 
-	if size > 100 {
-		size = 100
+	includeSuppressed, ok := parseIncludeSuppressed(c)
+	if !ok {
+		return
 	}
 
-	anomalies, err := ESClient.SearchAnomaliesByText(c.Request.Context(), searchText, from, size)
+	page, err := Logs.PageLogs(c.Request.Context(), elastic.LogQuery{Text: searchText, AnomaliesOnly: true, IncludeSuppressed: includeSuppressed, From: from, Size: size, Cursor: c.Query("cursor")})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to search anomalies: %v", err)})
+		respondPageError(c, "Failed to search anomalies", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"anomalies": anomalies,
-		"total":     len(anomalies),
-		"from":      from,
-		"size":      size,
-		"query":     searchText,
+		"anomalies":   page.Logs,
+		"total":       page.Total,
+		"from":        from,
+		"size":        size,
+		"query":       searchText,
+		"next_cursor": page.NextCursor,
 	})
 }
 
 // SearchLogsHandler searches for logs containing specific text
 func SearchLogsHandler(c *gin.Context) {
-	if ESClient == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Elasticsearch not available"})
+	if Logs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log store not available"})
 		return
 	}
 
@@ -348,47 +868,31 @@ func SearchLogsHandler(c *gin.Context) {
 		return
 	}
 
-	// Parse pagination parameters
-	from := 0
-	size := 20
-
-	if fromStr := c.Query("from"); fromStr != "" {
-		if f, err := fmt.Sscanf(fromStr, "%d", &from); err != nil || f != 1 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'from' parameter"})
-			return
-		}
-	}
-
-	if sizeStr := c.Query("size"); sizeStr != "" {
-		if s, err := fmt.Sscanf(sizeStr, "%d", &size); err != nil || s != 1 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'size' parameter"})
-			return
-		}
-	}
-
-	if size > 100 {
-		size = 100
+	from, size, ok := parsePagination(c)
+	if !ok {
+		return
 	}
 
-	logs, err := ESClient.SearchLogsByText(c.Request.Context(), searchText, from, size)
+	page, err := Logs.PageLogs(c.Request.Context(), elastic.LogQuery{Text: searchText, From: from, Size: size, Cursor: c.Query("cursor")})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to search logs: %v", err)})
+		respondPageError(c, "Failed to search logs", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"logs":  logs,
-		"total": len(logs),
-		"from":  from,
-		"size":  size,
-		"query": searchText,
+		"logs":        page.Logs,
+		"total":       page.Total,
+		"from":        from,
+		"size":        size,
+		"query":       searchText,
+		"next_cursor": page.NextCursor,
 	})
 }
 
 // GetAnomalyStatsHandler retrieves anomaly statistics
 func GetAnomalyStatsHandler(c *gin.Context) {
-	if ESClient == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Elasticsearch not available"})
+	if Logs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log store not available"})
 		return
 	}
 
@@ -413,7 +917,12 @@ func GetAnomalyStatsHandler(c *gin.Context) {
 		return
 	}
 
-	stats, err := ESClient.GetAnomalyStats(c.Request.Context(), startTime, endTime)
+	includeSuppressed, ok := parseIncludeSuppressed(c)
+	if !ok {
+		return
+	}
+
+	stats, err := Logs.GetAnomalyStats(c.Request.Context(), startTime, endTime, includeSuppressed)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get anomaly stats: %v", err)})
 		return
@@ -424,8 +933,8 @@ func GetAnomalyStatsHandler(c *gin.Context) {
 
 // GetLogStatsHandler retrieves general log statistics
 func GetLogStatsHandler(c *gin.Context) {
-	if ESClient == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Elasticsearch not available"})
+	if Logs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log store not available"})
 		return
 	}
 
@@ -450,7 +959,7 @@ func GetLogStatsHandler(c *gin.Context) {
 		return
 	}
 
-	stats, err := ESClient.GetLogStats(c.Request.Context(), startTime, endTime)
+	stats, err := Logs.GetLogStats(c.Request.Context(), startTime, endTime)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get log stats: %v", err)})
 		return
@@ -459,10 +968,10 @@ func GetLogStatsHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
-// PushDetectionResultHandler pushes a detection result directly to Elasticsearch
+// PushDetectionResultHandler stores a detection result made by an external detector
 func PushDetectionResultHandler(c *gin.Context) {
-	if ESClient == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Elasticsearch not available"})
+	if Logs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log store not available"})
 		return
 	}
 
@@ -477,7 +986,15 @@ func PushDetectionResultHandler(c *gin.Context) {
 		return
 	}
 
-	err := ESClient.PushDetectionResult(c.Request.Context(), request.LogText, request.IsAnomaly, request.Metadata)
+	now := time.Now().UTC()
+	err := Logs.IndexLog(c.Request.Context(), &elastic.LogDocument{
+		ID:         fmt.Sprintf("%d", now.UnixNano()),
+		Timestamp:  now,
+		ReceivedAt: now,
+		LogText:    request.LogText,
+		IsAnomaly:  request.IsAnomaly,
+		Metadata:   request.Metadata,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to push detection result: %v", err)})
 		return
@@ -492,8 +1009,8 @@ func PushDetectionResultHandler(c *gin.Context) {
 
 // BulkPushDetectionResultsHandler pushes multiple detection results
 func BulkPushDetectionResultsHandler(c *gin.Context) {
-	if ESClient == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Elasticsearch not available"})
+	if Logs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log store not available"})
 		return
 	}
 
@@ -516,7 +1033,7 @@ func BulkPushDetectionResultsHandler(c *gin.Context) {
 		return
 	}
 
-	err := ESClient.BulkPushDetectionResults(c.Request.Context(), request.Results)
+	err := Logs.BulkIndexLogs(c.Request.Context(), elastic.DetectionResultDocuments(request.Results))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to bulk push detection results: %v", err)})
 		return
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"anomaly-detection-platform/go-service/internal/input"
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// OTLPMaxBodySize bounds the decompressed size of an OTLP/HTTP request
+var OTLPMaxBodySize int64 = 16 << 20
+
+// OTLPLogsHandler receives OTLP/HTTP log exports, encoded as protobuf or JSON and
+// optionally gzip-compressed, and answers in the encoding of the request
+func OTLPLogsHandler(c *gin.Context) {
+	ct, _, _ := strings.Cut(c.GetHeader("Content-Type"), ";")
+	ct = strings.TrimSpace(ct)
+	if ct != "application/x-protobuf" && ct != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "content type must be application/x-protobuf or application/json"})
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	switch c.GetHeader("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			otlpError(c, ct, http.StatusBadRequest, codes.InvalidArgument, "invalid gzip body")
+			return
+		}
+		defer zr.Close()
+		body = zr
+	default:
+		otlpError(c, ct, http.StatusUnsupportedMediaType, codes.InvalidArgument, "unsupported content encoding")
+		return
+	}
+
+	b, err := io.ReadAll(io.LimitReader(body, OTLPMaxBodySize+1))
+	if err != nil {
+		otlpError(c, ct, http.StatusBadRequest, codes.InvalidArgument, "could not read body")
+		return
+	}
+	if int64(len(b)) > OTLPMaxBodySize {
+		metrics.InputDroppedTotal.WithLabelValues("otlp_http", "too_large").Inc()
+		otlpError(c, ct, http.StatusRequestEntityTooLarge, codes.InvalidArgument, "request too large")
+		return
+	}
+
+	req := &collogs.ExportLogsServiceRequest{}
+	if ct == "application/json" {
+		err = input.UnmarshalOTLPJSON(b, req)
+	} else {
+		err = proto.Unmarshal(b, req)
+	}
+	if err != nil {
+		metrics.InputDroppedTotal.WithLabelValues("otlp_http", "invalid").Inc()
+		otlpError(c, ct, http.StatusBadRequest, codes.InvalidArgument, "invalid OTLP logs request: "+err.Error())
+		return
+	}
+
+	// The export succeeds once the logs are stored; exporters retry a 503
+	events := input.OTLPEvents(req)
+	if len(events) > 0 {
+		if err := ProcessEvents(c.Request.Context(), events); err != nil {
+			metrics.InputDroppedTotal.WithLabelValues("otlp_http", "store_failed").Add(float64(len(events)))
+			c.Header("Retry-After", strconv.Itoa(int(RetryAfter.Seconds())))
+			otlpError(c, ct, http.StatusServiceUnavailable, codes.Unavailable, err.Error())
+			return
+		}
+		metrics.InputEventsTotal.WithLabelValues("otlp_http").Add(float64(len(events)))
+	}
+
+	resp := &collogs.ExportLogsServiceResponse{}
+	if ct == "application/json" {
+		out, _ := protojson.Marshal(resp)
+		c.Data(http.StatusOK, ct, out)
+		return
+	}
+	out, _ := proto.Marshal(resp)
+	c.Data(http.StatusOK, ct, out)
+}
+
+// otlpError answers with a google.rpc.Status message, as the OTLP/HTTP spec asks
+func otlpError(c *gin.Context, ct string, httpCode int, code codes.Code, msg string) {
+	st := status.New(code, msg).Proto()
+	if ct == "application/json" {
+		out, _ := protojson.Marshal(st)
+		c.Data(httpCode, ct, out)
+		return
+	}
+	out, _ := proto.Marshal(st)
+	c.Data(httpCode, ct, out)
+}
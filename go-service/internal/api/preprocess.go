@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"anomaly-detection-platform/go-service/internal/preprocessing"
+	"anomaly-detection-platform/go-service/internal/redaction"
+)
+
+// Preprocessor selects and runs the preprocessing pipeline of every log; when nil, the default pipeline is used
+var Preprocessor *preprocessing.Preprocessor
+
+// Redactor removes personal data and secrets from every log before it is preprocessed; when nil, logs are not redacted
+var Redactor *redaction.Redactor
+
+// PreprocessTestRequest is the body of POST /v1/preprocess/test
+type PreprocessTestRequest struct {
+	Text     string                 `json:"text" binding:"required"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Pipeline string                 `json:"pipeline,omitempty"` // overrides the selection by metadata
+}
+
+// TestPreprocessHandler runs a sample line through a pipeline and returns the output of every stage
+func TestPreprocessHandler(c *gin.Context) {
+	var req PreprocessTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pre := Preprocessor
+	if pre == nil {
+		pre, _ = preprocessing.NewPreprocessor("")
+	}
+
+	var pipeline *preprocessing.Pipeline
+	if req.Pipeline != "" {
+		p, ok := pre.Pipeline(req.Pipeline)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "pipeline not found"})
+			return
+		}
+		pipeline = p
+	} else {
+		pipeline = pre.Select(req.Metadata)
+	}
+
+	text := req.Text
+	var stages []preprocessing.StageTrace
+	if Redactor != nil {
+		text = Redactor.Redact(text)
+		stages = append(stages, preprocessing.StageTrace{Stage: "redact", Type: "redact", Output: text})
+	}
+
+	result, traced := pipeline.Trace(text)
+	stages = append(stages, traced...)
+	c.JSON(http.StatusOK, gin.H{
+		"pipeline":   result.Pipeline,
+		"input":      req.Text,
+		"stages":     stages,
+		"output":     result.Text,
+		"dropped":    result.Dropped,
+		"dropped_by": result.DroppedBy,
+		"fields":     result.Fields,
+	})
+}
@@ -1,23 +1,22 @@
 package api
 
 import (
-	"net/http"
-
 	"github.com/gin-gonic/gin"
 )
 
 // Register all routes
 func RegisterRoutes(r *gin.Engine) {
-	r.GET("/healthz", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+	r.GET("/healthz", HealthHandler)
 
 	v1 := r.Group("/v1")
 	{
 		// Log ingestion and retrieval
 		v1.POST("/logs", LogsHandler)
+		v1.POST("/otlp/logs", OTLPLogsHandler)
 		v1.GET("/logs", GetLogsHandler)
+		v1.POST("/logs/:id/feedback", PostFeedbackHandler)
 		v1.GET("/anomalies", GetAnomaliesHandler)
+		v1.GET("/anomalies/volume", GetVolumeAnomaliesHandler)
 
 		// Search endpoints
 		v1.GET("/search/anomalies", SearchAnomaliesHandler)
@@ -27,6 +26,40 @@ func RegisterRoutes(r *gin.Engine) {
 		v1.GET("/stats/anomalies", GetAnomalyStatsHandler)
 		v1.GET("/stats/logs", GetLogStatsHandler)
 
+		// Preprocessing endpoints
+		v1.POST("/preprocess/test", TestPreprocessHandler)
+
+		// Log template endpoints
+		v1.GET("/templates", GetTemplatesHandler)
+		v1.GET("/templates/:id", GetTemplateHandler)
+		v1.GET("/templates/:id/logs", GetTemplateLogsHandler)
+
+		// Incident endpoints
+		v1.GET("/incidents", GetIncidentsHandler)
+		v1.GET("/incidents/:id", GetIncidentHandler)
+		v1.GET("/incidents/:id/logs", GetIncidentLogsHandler)
+		v1.POST("/incidents/:id/acknowledge", AcknowledgeIncidentHandler)
+		v1.POST("/incidents/:id/resolve", ResolveIncidentHandler)
+		v1.POST("/incidents/:id/comments", CommentIncidentHandler)
+
+		// Suppression rule endpoints
+		v1.GET("/suppressions", GetSuppressionsHandler)
+		v1.POST("/suppressions", CreateSuppressionHandler)
+		v1.GET("/suppressions/:id", GetSuppressionHandler)
+		v1.PUT("/suppressions/:id", UpdateSuppressionHandler)
+		v1.DELETE("/suppressions/:id", DeleteSuppressionHandler)
+
+		// Alerting endpoints
+		v1.GET("/alerts", GetAlertsHandler)
+		v1.GET("/alerts/rules", GetAlertRulesHandler)
+		v1.POST("/alerts/rules", CreateAlertRuleHandler)
+		v1.GET("/alerts/rules/:id", GetAlertRuleHandler)
+		v1.PUT("/alerts/rules/:id", UpdateAlertRuleHandler)
+		v1.DELETE("/alerts/rules/:id", DeleteAlertRuleHandler)
+
+		// Analyst feedback endpoints
+		v1.GET("/feedback/export", ExportFeedbackHandler)
+
 		// Detection result endpoints
 		v1.POST("/detection", PushDetectionResultHandler)
 		v1.POST("/detection/bulk", BulkPushDetectionResultsHandler)
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"anomaly-detection-platform/go-service/internal/suppression"
+)
+
+// Suppressions silences anomalies known to be noise; when nil, nothing is suppressed
+var Suppressions *suppression.Set
+
+// GetSuppressionsHandler lists the suppression rules
+func GetSuppressionsHandler(c *gin.Context) {
+	if Suppressions == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "suppressions not enabled"})
+		return
+	}
+
+	rules := Suppressions.Rules()
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+		"total": len(rules),
+	})
+}
+
+// GetSuppressionHandler returns a single suppression rule
+func GetSuppressionHandler(c *gin.Context) {
+	if Suppressions == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "suppressions not enabled"})
+		return
+	}
+
+	rule, err := Suppressions.Rule(c.Param("id"))
+	if err != nil {
+		respondSuppressionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// CreateSuppressionHandler adds a suppression rule
+func CreateSuppressionHandler(c *gin.Context) {
+	if Suppressions == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "suppressions not enabled"})
+		return
+	}
+
+	var rule suppression.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := Suppressions.Add(c.Request.Context(), rule)
+	if err != nil {
+		respondSuppressionError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateSuppressionHandler replaces a suppression rule
+func UpdateSuppressionHandler(c *gin.Context) {
+	if Suppressions == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "suppressions not enabled"})
+		return
+	}
+
+	var rule suppression.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule.ID = c.Param("id")
+
+	updated, err := Suppressions.Update(c.Request.Context(), rule)
+	if err != nil {
+		respondSuppressionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteSuppressionHandler removes a suppression rule
+func DeleteSuppressionHandler(c *gin.Context) {
+	if Suppressions == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "suppressions not enabled"})
+		return
+	}
+
+	if err := Suppressions.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		respondSuppressionError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// parseIncludeSuppressed reads the include_suppressed query parameter.
+// It writes a 400 response and returns false when it is invalid.
+func parseIncludeSuppressed(c *gin.Context) (bool, bool) {
+	v := c.Query("include_suppressed")
+	if v == "" {
+		return false, true
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'include_suppressed' parameter"})
+		return false, false
+	}
+	return b, true
+}
+
+func respondSuppressionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, suppression.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, suppression.ErrExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, suppression.ErrInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update suppression rules: %v", err)})
+	}
+}
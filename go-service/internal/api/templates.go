@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+	"anomaly-detection-platform/go-service/internal/preprocessing"
+)
+
+// TemplateMiner assigns every ingested log to a template; when nil, templates are not mined
+var TemplateMiner *preprocessing.Miner
+
+// LoadTemplates restores the templates persisted in Elasticsearch into TemplateMiner
+func LoadTemplates(ctx context.Context) error {
+	if TemplateMiner == nil || ESClient == nil {
+		return nil
+	}
+
+	docs, err := ESClient.GetTemplates(ctx, 10000)
+	if err != nil {
+		return err
+	}
+
+	templates := make([]preprocessing.Template, len(docs))
+	for i, d := range docs {
+		templates[i] = preprocessing.Template{
+			ID:        d.ID,
+			Template:  d.Template,
+			Tokens:    d.Tokens,
+			Count:     d.Count,
+			FirstSeen: d.FirstSeen,
+			LastSeen:  d.LastSeen,
+		}
+	}
+	TemplateMiner.Load(templates)
+	return nil
+}
+
+// FlushTemplates writes the templates changed since the last flush to Elasticsearch
+func FlushTemplates(ctx context.Context) error {
+	if TemplateMiner == nil || ESClient == nil {
+		return nil
+	}
+
+	dirty := TemplateMiner.Dirty()
+	if len(dirty) == 0 {
+		return nil
+	}
+
+	docs := make([]elastic.TemplateDocument, len(dirty))
+	for i, t := range dirty {
+		docs[i] = elastic.TemplateDocument{
+			ID:        t.ID,
+			Template:  t.Template,
+			Tokens:    t.Tokens,
+			Count:     t.Count,
+			FirstSeen: t.FirstSeen,
+			LastSeen:  t.LastSeen,
+		}
+	}
+	if err := ESClient.SaveTemplates(ctx, docs); err != nil {
+		return err
+	}
+
+	TemplateMiner.MarkPersisted(dirty)
+	return nil
+}
+
+// GetTemplatesHandler lists the mined log templates
+func GetTemplatesHandler(c *gin.Context) {
+	if TemplateMiner == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "template mining not enabled"})
+		return
+	}
+
+	from, size, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	templates := TemplateMiner.Templates()
+	switch sortBy := c.DefaultQuery("sort", "count"); sortBy {
+	case "count":
+		// Templates() is already ordered by count
+	case "last_seen":
+		sort.SliceStable(templates, func(i, j int) bool { return templates[i].LastSeen.After(templates[j].LastSeen) })
+	case "first_seen":
+		sort.SliceStable(templates, func(i, j int) bool { return templates[i].FirstSeen.After(templates[j].FirstSeen) })
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'sort' parameter, use count, last_seen or first_seen"})
+		return
+	}
+
+	total := len(templates)
+	page := templates[min(from, total):min(from+size, total)]
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": page,
+		"total":     total,
+		"from":      from,
+		"size":      size,
+	})
+}
+
+// GetTemplateHandler returns a single template
+func GetTemplateHandler(c *gin.Context) {
+	if TemplateMiner == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "template mining not enabled"})
+		return
+	}
+
+	t, ok := TemplateMiner.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// GetTemplateLogsHandler retrieves the logs assigned to a template
+func GetTemplateLogsHandler(c *gin.Context) {
+	if Logs == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log store not available"})
+		return
+	}
+
+	from, size, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	anomaliesOnly := false
+	if v := c.Query("anomalies"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'anomalies' parameter"})
+			return
+		}
+		anomaliesOnly = b
+	}
+
+	includeSuppressed, ok := parseIncludeSuppressed(c)
+	if !ok {
+		return
+	}
+
+	templateID := c.Param("id")
+	page, err := Logs.PageLogs(c.Request.Context(), elastic.LogQuery{
+		TemplateID:        templateID,
+		AnomaliesOnly:     anomaliesOnly,
+		IncludeSuppressed: includeSuppressed,
+		From:              from,
+		Size:              size,
+		Cursor:            c.Query("cursor"),
+	})
+	if err != nil {
+		respondPageError(c, "Failed to retrieve template logs", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"template_id": templateID,
+		"logs":        page.Logs,
+		"total":       page.Total,
+		"from":        from,
+		"size":        size,
+		"next_cursor": page.NextCursor,
+	})
+}
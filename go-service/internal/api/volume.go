@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+	"anomaly-detection-platform/go-service/internal/metrics"
+	"anomaly-detection-platform/go-service/internal/volume"
+	"anomaly-detection-platform/go-service/pkg/config"
+)
+
+// VolumeMonitor counts ingested logs per source; when nil, volume anomalies are not detected
+var VolumeMonitor *volume.Monitor
+
+// RecordVolumeAnomaly stores a volume anomaly reported by VolumeMonitor
+func RecordVolumeAnomaly(ev volume.Event) {
+	metrics.VolumeAnomaliesTotal.WithLabelValues(ev.Kind).Inc()
+	log.Printf("Volume %s for %s: %d logs, expected %.1f", ev.Kind, ev.Source, ev.Count, ev.Expected)
+
+	if ESClient == nil {
+		return
+	}
+
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%d", ev.Source, ev.WindowStart.UnixNano())))
+	doc := &elastic.VolumeAnomalyDocument{
+		ID:          hex.EncodeToString(sum[:10]),
+		Timestamp:   ev.WindowEnd,
+		Source:      ev.Source,
+		SourceKeys:  ev.SourceKeys,
+		Kind:        ev.Kind,
+		Method:      ev.Method,
+		WindowStart: ev.WindowStart,
+		WindowEnd:   ev.WindowEnd,
+		Count:       ev.Count,
+		Expected:    ev.Expected,
+		Score:       ev.Score,
+	}
+
+	ctx, cancel := config.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ESClient.IndexVolumeAnomaly(ctx, doc); err != nil {
+		fmt.Printf("Failed to index volume anomaly in Elasticsearch: %v\n", err)
+	}
+}
+
+// GetVolumeAnomaliesHandler retrieves detected log volume spikes and drops
+func GetVolumeAnomaliesHandler(c *gin.Context) {
+	// Only the embedded log store runs without a client; it does not keep volume anomalies
+	if ESClient == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "volume anomalies are only stored with the elasticsearch storage backend"})
+		return
+	}
+
+	from, size, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	kind := c.Query("kind")
+	if kind != "" && kind != volume.KindSpike && kind != volume.KindDrop {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'kind' parameter, use spike or drop"})
+		return
+	}
+
+	var start, end time.Time
+	if v := c.Query("start_time"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'start_time' format, use RFC3339"})
+			return
+		}
+		start = t
+	}
+	if v := c.Query("end_time"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid 'end_time' format, use RFC3339"})
+			return
+		}
+		end = t
+	}
+
+	page, err := ESClient.PageVolumeAnomalies(c.Request.Context(), elastic.VolumeAnomalyQuery{
+		Source: c.Query("source"),
+		Kind:   kind,
+		Start:  start,
+		End:    end,
+		From:   from,
+		Size:   size,
+		Cursor: c.Query("cursor"),
+	})
+	if err != nil {
+		respondPageError(c, "Failed to retrieve volume anomalies", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"anomalies":   page.Anomalies,
+		"total":       page.Total,
+		"from":        from,
+		"size":        size,
+		"next_cursor": page.NextCursor,
+	})
+}
@@ -0,0 +1,245 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"anomaly-detection-platform/go-service/pkg/config"
+)
+
+// ErrBatcherClosed is returned by Batcher.Predict after Close has been called
+var ErrBatcherClosed = errors.New("prediction batcher is closed")
+
+// Prediction is the model output for one text of a batch
+type Prediction struct {
+	Label string             `json:"label"`
+	Score float64            `json:"score"`
+	Probs map[string]float64 `json:"probs,omitempty"`
+	Err   error              `json:"-"`
+}
+
+type pyBatchRequest struct {
+	Texts []string `json:"texts"`
+}
+
+type pyBatchResponse struct {
+	Results []struct {
+		Label string             `json:"label"`
+		Score float64            `json:"score"`
+		Probs map[string]float64 `json:"probs"`
+		Error string             `json:"error"`
+	} `json:"results"`
+}
+
+func pythonBatchURL() string {
+	return config.GetEnv("PYTHON_BATCH_URL", strings.TrimSuffix(pythonURL(), "/")+"/batch")
+}
+
+// CallPythonPredictBatch classifies several texts with a single request to /predict/batch.
+// The returned slice is aligned with texts; items the model could not score carry Err.
+func CallPythonPredictBatch(ctx context.Context, texts []string) ([]Prediction, error) {
+	b, err := json.Marshal(pyBatchRequest{Texts: texts})
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	// Retry with backoff for transient errors and 5xx responses
+	for attempt := 0; attempt < 3; attempt++ {
+		out, err := postBatch(ctx, b)
+		if err == nil {
+			if len(out.Results) != len(texts) {
+				return nil, fmt.Errorf("python service returned %d results for %d texts", len(out.Results), len(texts))
+			}
+			preds := make([]Prediction, len(texts))
+			for i, r := range out.Results {
+				if r.Error != "" {
+					preds[i].Err = errors.New(r.Error)
+					continue
+				}
+				preds[i] = Prediction{Label: r.Label, Score: r.Score, Probs: r.Probs}
+			}
+			return preds, nil
+		}
+		lastErr = err
+
+		var se *statusError
+		if errors.As(err, &se) && se.code < 500 {
+			return nil, err
+		}
+
+		// Backoff before next attempt if context not done
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(200*(1<<attempt)) * time.Millisecond):
+		}
+	}
+	return nil, lastErr
+}
+
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	if e.code >= 500 {
+		return fmt.Sprintf("python service 5xx: %d", e.code)
+	}
+	return fmt.Sprintf("unexpected status %d", e.code)
+}
+
+func postBatch(ctx context.Context, body []byte) (*pyBatchResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pythonBatchURL(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &statusError{code: resp.StatusCode}
+	}
+
+	var out pyBatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode batch response: %w", err)
+	}
+	return &out, nil
+}
+
+// Batcher coalesces concurrent Predict calls into /predict/batch requests.
+// A batch is sent once it holds maxSize texts or maxWait has passed since its first text.
+type Batcher struct {
+	maxSize int
+	maxWait time.Duration
+	timeout time.Duration
+
+	requests chan *pendingPrediction
+	inflight chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+}
+
+type pendingPrediction struct {
+	text   string
+	result chan Prediction
+}
+
+// NewBatcher starts a batcher that sends at most maxSize texts per request and
+// holds a partial batch for at most maxWait before sending it.
+func NewBatcher(maxSize int, maxWait time.Duration) *Batcher {
+	if maxSize < 1 {
+		maxSize = 1
+	}
+
+	b := &Batcher{
+		maxSize:  maxSize,
+		maxWait:  maxWait,
+		timeout:  10 * time.Second,
+		requests: make(chan *pendingPrediction),
+		inflight: make(chan struct{}, 4),
+		done:     make(chan struct{}),
+	}
+
+	b.wg.Add(1)
+	go b.loop()
+	return b
+}
+
+// Predict has the same contract as CallPythonPredict but shares the HTTP request with other callers
+func (b *Batcher) Predict(ctx context.Context, text string) (string, float64, error) {
+	p := &pendingPrediction{text: text, result: make(chan Prediction, 1)}
+
+	select {
+	case b.requests <- p:
+	case <-b.done:
+		return "", 0, ErrBatcherClosed
+	case <-ctx.Done():
+		return "", 0, ctx.Err()
+	}
+
+	select {
+	case r := <-p.result:
+		return r.Label, r.Score, r.Err
+	case <-ctx.Done():
+		return "", 0, ctx.Err()
+	}
+}
+
+// Close stops accepting predictions and waits for in-flight batches to finish
+func (b *Batcher) Close() {
+	b.once.Do(func() { close(b.done) })
+	b.wg.Wait()
+}
+
+func (b *Batcher) loop() {
+	defer b.wg.Done()
+
+	for {
+		var batch []*pendingPrediction
+		select {
+		case p := <-b.requests:
+			batch = append(batch, p)
+		case <-b.done:
+			return
+		}
+
+		timer := time.NewTimer(b.maxWait)
+	collect:
+		for len(batch) < b.maxSize {
+			select {
+			case p := <-b.requests:
+				batch = append(batch, p)
+			case <-timer.C:
+				break collect
+			case <-b.done:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		// Bound the number of concurrent requests to the Python service
+		b.inflight <- struct{}{}
+		b.wg.Add(1)
+		go func(batch []*pendingPrediction) {
+			defer func() {
+				<-b.inflight
+				b.wg.Done()
+			}()
+			b.flush(batch)
+		}(batch)
+	}
+}
+
+func (b *Batcher) flush(batch []*pendingPrediction) {
+	texts := make([]string, len(batch))
+	for i, p := range batch {
+		texts[i] = p.text
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+
+	preds, err := CallPythonPredictBatch(ctx, texts)
+	for i, p := range batch {
+		if err != nil {
+			p.result <- Prediction{Err: err}
+			continue
+		}
+		p.result <- preds[i]
+	}
+}
@@ -0,0 +1,81 @@
+package detection
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Config selects which detectors run and how their verdicts are combined
+type Config struct {
+	Detectors []string           // detector names, e.g. ["python", "keyword"]
+	Mode      Mode               // used when more than one detector is selected
+	Weights   map[string]float64 // ModeWeighted only
+	Threshold float64            // ModeWeighted only
+}
+
+// ParseConfig reads the DETECTORS, DETECTION_MODE, DETECTOR_WEIGHTS and DETECTION_THRESHOLD values
+func ParseConfig(detectors, mode, weights, threshold string) (Config, error) {
+	cfg := Config{Mode: Mode(strings.ToLower(strings.TrimSpace(mode))), Threshold: 0.5}
+
+	for _, name := range strings.Split(detectors, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.Detectors = append(cfg.Detectors, name)
+		}
+	}
+
+	if weights != "" {
+		cfg.Weights = make(map[string]float64)
+		for _, pair := range strings.Split(weights, ",") {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return cfg, fmt.Errorf("invalid detector weight %q, expected name=value", pair)
+			}
+			w, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid weight for detector %q: %w", name, err)
+			}
+			cfg.Weights[strings.TrimSpace(name)] = w
+		}
+	}
+
+	if threshold != "" {
+		t, err := strconv.ParseFloat(threshold, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid detection threshold: %w", err)
+		}
+		cfg.Threshold = t
+	}
+	return cfg, nil
+}
+
+// Build picks the configured detectors out of available (keyed by Name) and,
+// when more than one is selected, wraps them in an Ensemble.
+func Build(cfg Config, available ...Detector) (Detector, error) {
+	byName := make(map[string]Detector, len(available))
+	for _, d := range available {
+		byName[d.Name()] = d
+	}
+
+	var selected []Detector
+	for _, name := range cfg.Detectors {
+		d, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q", name)
+		}
+		selected = append(selected, d)
+	}
+
+	switch len(selected) {
+	case 0:
+		return nil, fmt.Errorf("no detectors configured")
+	case 1:
+		return selected[0], nil
+	}
+
+	mode := cfg.Mode
+	if mode == "" {
+		mode = ModeAny
+	}
+	return NewEnsemble(mode, selected, cfg.Weights, cfg.Threshold)
+}
@@ -0,0 +1,45 @@
+package detection
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	LabelAnomaly = "anomaly"
+	LabelNormal  = "normal"
+)
+
+// LogEvent is the input handed to every detector
+type LogEvent struct {
+	ID           string
+	Text         string // cleaned text, as sent to the model
+	OriginalText string
+	ContentType  string
+	Metadata     map[string]interface{}
+	Timestamp    time.Time
+	TraceID      string // hex trace context, set for OTLP logs
+	SpanID       string
+
+	// Template assigned by the miner, if any
+	TemplateID       string
+	TemplateCount    int64     // occurrences including this one
+	TemplatePrevSeen time.Time // previous occurrence, zero for a new template
+}
+
+// Verdict is the outcome of running one detector on one log
+type Verdict struct {
+	Detector  string    `json:"detector"`
+	Label     string    `json:"label"`
+	Score     float64   `json:"score"`
+	IsAnomaly bool      `json:"is_anomaly"`
+	Reason    string    `json:"reason,omitempty"`
+	Votes     []Verdict `json:"votes,omitempty"`
+}
+
+// Detector decides whether a single log is anomalous.
+// An error means the detector could not give a verdict (e.g. its backend is down).
+type Detector interface {
+	Name() string
+	Detect(ctx context.Context, ev LogEvent) (Verdict, error)
+}
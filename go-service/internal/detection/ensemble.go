@@ -0,0 +1,128 @@
+package detection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Mode selects how an Ensemble combines the verdicts of its detectors
+type Mode string
+
+const (
+	// ModeAny flags a log when at least one detector flags it
+	ModeAny Mode = "any"
+	// ModeAll flags a log only when every detector that answered flags it
+	ModeAll Mode = "all"
+	// ModeWeighted flags a log when the weighted share of anomaly votes reaches the threshold
+	ModeWeighted Mode = "weighted"
+)
+
+// Ensemble runs several detectors in parallel and combines their verdicts.
+// Detectors that fail are left out of the vote, so the ensemble still answers
+// as long as one of them does.
+type Ensemble struct {
+	mode      Mode
+	detectors []Detector
+	weights   map[string]float64
+	threshold float64
+}
+
+// NewEnsemble combines detectors with mode. weights (by detector name, default 1) and
+// threshold are only used by ModeWeighted.
+func NewEnsemble(mode Mode, detectors []Detector, weights map[string]float64, threshold float64) (*Ensemble, error) {
+	switch mode {
+	case ModeAny, ModeAll, ModeWeighted:
+	default:
+		return nil, fmt.Errorf("unknown ensemble mode %q", mode)
+	}
+	if len(detectors) == 0 {
+		return nil, errors.New("ensemble needs at least one detector")
+	}
+	return &Ensemble{mode: mode, detectors: detectors, weights: weights, threshold: threshold}, nil
+}
+
+func (e *Ensemble) Name() string { return "ensemble" }
+
+func (e *Ensemble) Detect(ctx context.Context, ev LogEvent) (Verdict, error) {
+	verdicts := make([]Verdict, len(e.detectors))
+	errs := make([]error, len(e.detectors))
+
+	var wg sync.WaitGroup
+	wg.Add(len(e.detectors))
+	for i, d := range e.detectors {
+		go func(i int, d Detector) {
+			defer wg.Done()
+			verdicts[i], errs[i] = d.Detect(ctx, ev)
+		}(i, d)
+	}
+	wg.Wait()
+
+	var votes []Verdict
+	for i, v := range verdicts {
+		if errs[i] == nil {
+			votes = append(votes, v)
+		}
+	}
+	if len(votes) == 0 {
+		return Verdict{}, fmt.Errorf("all detectors failed: %w", errors.Join(errs...))
+	}
+
+	out := Verdict{Detector: e.Name(), Votes: votes}
+	var reasons []string
+
+	switch e.mode {
+	case ModeAny:
+		for _, v := range votes {
+			if v.IsAnomaly {
+				out.IsAnomaly = true
+				out.Score = max(out.Score, v.Score)
+			}
+		}
+	case ModeAll:
+		out.IsAnomaly = true
+		out.Score = 1
+		for _, v := range votes {
+			out.IsAnomaly = out.IsAnomaly && v.IsAnomaly
+			out.Score = min(out.Score, v.Score)
+		}
+		if !out.IsAnomaly {
+			out.Score = 0
+		}
+	case ModeWeighted:
+		var total, anomalous float64
+		for _, v := range votes {
+			w := e.weight(v.Detector)
+			total += w
+			if v.IsAnomaly {
+				anomalous += w
+			}
+		}
+		if total > 0 {
+			out.Score = anomalous / total
+		}
+		out.IsAnomaly = out.Score > 0 && out.Score >= e.threshold
+	}
+
+	if out.IsAnomaly {
+		out.Label = LabelAnomaly
+		for _, v := range votes {
+			if v.IsAnomaly && v.Reason != "" {
+				reasons = append(reasons, v.Detector+": "+v.Reason)
+			}
+		}
+		out.Reason = strings.Join(reasons, "; ")
+	} else {
+		out.Label = LabelNormal
+	}
+	return out, nil
+}
+
+func (e *Ensemble) weight(name string) float64 {
+	if w, ok := e.weights[name]; ok {
+		return w
+	}
+	return 1
+}
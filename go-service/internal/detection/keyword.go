@@ -0,0 +1,56 @@
+package detection
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultKeywords are matched by the keyword detector when none are configured
+var DefaultKeywords = []string{
+	"panic", "fatal", "critical", "segfault", "segmentation fault",
+	"out of memory", "oom", "stack overflow", "deadlock", "unhandled exception",
+}
+
+// KeywordDetector is an in-process detector flagging logs that contain well-known failure terms.
+// It needs no external service, so it keeps working when the Python service is down.
+type KeywordDetector struct {
+	re *regexp.Regexp
+}
+
+// NewKeywordDetector builds a detector matching any of keywords as whole words, case-insensitively
+func NewKeywordDetector(keywords []string) (*KeywordDetector, error) {
+	if len(keywords) == 0 {
+		keywords = DefaultKeywords
+	}
+
+	quoted := make([]string, 0, len(keywords))
+	for _, k := range keywords {
+		if k = strings.TrimSpace(k); k != "" {
+			quoted = append(quoted, regexp.QuoteMeta(k))
+		}
+	}
+
+	re, err := regexp.Compile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile keywords: %w", err)
+	}
+	return &KeywordDetector{re: re}, nil
+}
+
+func (d *KeywordDetector) Name() string { return "keyword" }
+
+func (d *KeywordDetector) Detect(ctx context.Context, ev LogEvent) (Verdict, error) {
+	m := d.re.FindString(ev.Text)
+	if m == "" {
+		return Verdict{Detector: d.Name(), Label: LabelNormal}, nil
+	}
+	return Verdict{
+		Detector:  d.Name(),
+		Label:     LabelAnomaly,
+		Score:     1,
+		IsAnomaly: true,
+		Reason:    fmt.Sprintf("matched keyword %q", strings.ToLower(m)),
+	}, nil
+}
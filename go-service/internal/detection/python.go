@@ -0,0 +1,42 @@
+package detection
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// PredictFunc classifies a text and returns the model label and its score
+type PredictFunc func(ctx context.Context, text string) (string, float64, error)
+
+// PythonDetector delegates to the transformer classifier served by the Python service
+type PythonDetector struct {
+	predict   PredictFunc
+	threshold float64
+}
+
+// NewPythonDetector wraps predict (client.CallPythonPredict or a client.Batcher).
+// A log is anomalous when the model labels it "anomaly" or its score exceeds threshold.
+func NewPythonDetector(predict PredictFunc, threshold float64) *PythonDetector {
+	return &PythonDetector{predict: predict, threshold: threshold}
+}
+
+func (d *PythonDetector) Name() string { return "python" }
+
+func (d *PythonDetector) Detect(ctx context.Context, ev LogEvent) (Verdict, error) {
+	label, score, err := d.predict(ctx, ev.Text)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("python detector: %w", err)
+	}
+
+	v := Verdict{
+		Detector:  d.Name(),
+		Label:     label,
+		Score:     score,
+		IsAnomaly: strings.EqualFold(label, LabelAnomaly) || score > d.threshold,
+	}
+	if v.IsAnomaly {
+		v.Reason = fmt.Sprintf("classifier label %q with score %.2f", label, score)
+	}
+	return v, nil
+}
@@ -0,0 +1,150 @@
+package detection
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// templateBuckets is the resolution of the per-template frequency history
+const templateBuckets = 30
+
+// TemplateDetector flags logs whose template has never been seen before or has been
+// seen fewer than a threshold number of times within the rarity window. During the
+// learning window that follows the first log it sees, it only records frequencies.
+type TemplateDetector struct {
+	learningWindow time.Duration
+	rarityWindow   time.Duration
+	threshold      int64
+	bucketWidth    time.Duration
+
+	mu         sync.Mutex
+	learnUntil time.Time
+	history    map[string]*templateHistory
+	lastPrune  time.Time
+}
+
+type templateHistory struct {
+	counts   [templateBuckets]int64
+	epochs   [templateBuckets]int64
+	lastSeen time.Time
+}
+
+// NewTemplateDetector creates a new/rare template detector. A template is rare when it
+// was seen fewer than threshold times within rarityWindow.
+func NewTemplateDetector(learningWindow, rarityWindow time.Duration, threshold int) *TemplateDetector {
+	if rarityWindow < templateBuckets {
+		rarityWindow = templateBuckets
+	}
+	return &TemplateDetector{
+		learningWindow: learningWindow,
+		rarityWindow:   rarityWindow,
+		threshold:      int64(threshold),
+		bucketWidth:    rarityWindow / templateBuckets,
+		history:        make(map[string]*templateHistory),
+	}
+}
+
+func (d *TemplateDetector) Name() string { return "template" }
+
+func (d *TemplateDetector) Detect(ctx context.Context, ev LogEvent) (Verdict, error) {
+	normal := Verdict{Detector: d.Name(), Label: LabelNormal}
+	if ev.TemplateID == "" {
+		return normal, nil
+	}
+
+	now := ev.Timestamp
+	if now.IsZero() {
+		now = time.Now().UTC()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.learnUntil.IsZero() {
+		d.learnUntil = now.Add(d.learningWindow)
+	}
+	d.prune(now)
+
+	h, ok := d.history[ev.TemplateID]
+	if !ok {
+		h = &templateHistory{}
+		d.history[ev.TemplateID] = h
+		// Occurrences counted by the miner before this detector knew the template
+		// (e.g. restored after a restart) are credited to the time it was last seen
+		if ev.TemplateCount > 1 && now.Sub(ev.TemplatePrevSeen) < d.rarityWindow {
+			d.add(h, ev.TemplatePrevSeen, ev.TemplateCount-1)
+		}
+	}
+
+	seen := d.count(h, now)
+	d.add(h, now, 1)
+
+	if now.Before(d.learnUntil) || seen >= d.threshold {
+		return normal, nil
+	}
+
+	v := Verdict{
+		Detector:  d.Name(),
+		Label:     LabelAnomaly,
+		Score:     1 - float64(seen)/float64(d.threshold),
+		IsAnomaly: true,
+	}
+	switch {
+	case seen == 0 && ev.TemplateCount <= 1:
+		v.Reason = "first seen template"
+	case seen == 0:
+		v.Reason = fmt.Sprintf("template not seen in %s", formatWindow(d.rarityWindow))
+	case seen == 1:
+		v.Reason = fmt.Sprintf("seen 1 time in %s", formatWindow(d.rarityWindow))
+	default:
+		v.Reason = fmt.Sprintf("seen %d times in %s", seen, formatWindow(d.rarityWindow))
+	}
+	return v, nil
+}
+
+func (d *TemplateDetector) add(h *templateHistory, at time.Time, n int64) {
+	epoch := at.UnixNano() / int64(d.bucketWidth)
+	i := epoch % templateBuckets
+	if h.epochs[i] != epoch {
+		h.epochs[i] = epoch
+		h.counts[i] = 0
+	}
+	h.counts[i] += n
+	if at.After(h.lastSeen) {
+		h.lastSeen = at
+	}
+}
+
+func (d *TemplateDetector) count(h *templateHistory, now time.Time) int64 {
+	current := now.UnixNano() / int64(d.bucketWidth)
+	var total int64
+	for i, epoch := range h.epochs {
+		if epoch > current-templateBuckets && epoch <= current {
+			total += h.counts[i]
+		}
+	}
+	return total
+}
+
+// prune forgets templates not seen within the rarity window, at most once per bucket
+func (d *TemplateDetector) prune(now time.Time) {
+	if now.Sub(d.lastPrune) < d.bucketWidth {
+		return
+	}
+	d.lastPrune = now
+	for id, h := range d.history {
+		if now.Sub(h.lastSeen) > d.rarityWindow {
+			delete(d.history, id)
+		}
+	}
+}
+
+// formatWindow renders whole days as "30d" and anything else as a Go duration
+func formatWindow(w time.Duration) string {
+	if w >= 24*time.Hour && w%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", w/(24*time.Hour))
+	}
+	return w.String()
+}
@@ -0,0 +1,507 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// DeadLettersIndex keeps the documents the bulk indexer gave up on, with the reason
+const DeadLettersIndex = "dead_letters"
+
+// ErrIndexerClosed is returned by BulkIndexer.Add after Close has been called
+var ErrIndexerClosed = errors.New("bulk indexer is closed")
+
+// DeadLetter is a document Elasticsearch rejected permanently or kept failing to index
+type DeadLetter struct {
+	Index     string          `json:"index"`
+	DocID     string          `json:"doc_id,omitempty"`
+	Document  json.RawMessage `json:"document"`
+	Status    int             `json:"status,omitempty"`
+	ErrorType string          `json:"error_type,omitempty"`
+	Reason    string          `json:"reason"`
+	Attempts  int             `json:"attempts"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// DeadLetterStore keeps the documents the bulk indexer could not index; *Client implements it
+type DeadLetterStore interface {
+	SaveDeadLetters(ctx context.Context, letters []DeadLetter) error
+}
+
+// SaveDeadLetters stores rejected documents in the dead-letter index
+func (c *Client) SaveDeadLetters(ctx context.Context, letters []DeadLetter) error {
+	items := make([]*bulkItem, 0, len(letters))
+	for _, dl := range letters {
+		doc, err := NewDocument(c.index(DeadLettersIndex), "", dl)
+		if err != nil {
+			return err
+		}
+		items = append(items, &bulkItem{Document: doc})
+	}
+	return c.bulkWithRetry(ctx, items, "false")
+}
+
+// Document is a serialized document bound for an index
+type Document struct {
+	Index string          `json:"index"`
+	ID    string          `json:"id,omitempty"` // empty lets Elasticsearch choose one
+	Body  json.RawMessage `json:"doc"`
+}
+
+// NewDocument serializes v for indexing under id in index
+func NewDocument(index, id string, v interface{}) (Document, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to marshal document: %w", err)
+	}
+	return Document{Index: index, ID: id, Body: body}, nil
+}
+
+// bulkItem is one document of a _bulk request
+type bulkItem struct {
+	Document
+	attempts int
+}
+
+// bulkFailure is an item Elasticsearch did not index; status 0 means the whole request failed
+type bulkFailure struct {
+	item      *bulkItem
+	status    int
+	errorType string
+	reason    string
+}
+
+func (f bulkFailure) Error() string {
+	if f.status == 0 {
+		return f.reason
+	}
+	return fmt.Sprintf("%s: %s (status %d)", f.errorType, f.reason, f.status)
+}
+
+// retryable reports whether the item may be indexed by sending it again: the request
+// failed as a whole or Elasticsearch was overloaded
+func (f bulkFailure) retryable() bool {
+	return f.status == 0 || f.status == 429 || f.status >= 500
+}
+
+// deadLetter records the failed item for the dead-letter index
+func (f bulkFailure) deadLetter() DeadLetter {
+	return DeadLetter{
+		Index:     f.item.Index,
+		DocID:     f.item.ID,
+		Document:  f.item.Body,
+		Status:    f.status,
+		ErrorType: f.errorType,
+		Reason:    f.reason,
+		Attempts:  f.item.attempts,
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// bulk sends items in a single _bulk request and returns the ones that were not indexed
+func (c *Client) bulk(ctx context.Context, items []*bulkItem, refresh string) []bulkFailure {
+	var body bytes.Buffer
+	for _, it := range items {
+		meta := map[string]interface{}{"_index": it.Index}
+		if it.ID != "" {
+			meta["_id"] = it.ID
+		}
+		action, _ := json.Marshal(map[string]interface{}{"index": meta})
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(it.Body)
+		body.WriteByte('\n')
+	}
+
+	failAll := func(reason string) []bulkFailure {
+		failed := make([]bulkFailure, len(items))
+		for i, it := range items {
+			failed[i] = bulkFailure{item: it, errorType: "request_error", reason: reason}
+		}
+		return failed
+	}
+
+	req := esapi.BulkRequest{
+		Body:    &body,
+		Refresh: refresh,
+	}
+	start := time.Now()
+	res, err := req.Do(ctx, c.es)
+	metrics.ESBulkFlushLatency.Observe(time.Since(start).Seconds())
+	if err != nil {
+		return failAll(fmt.Sprintf("failed to send bulk request: %v", err))
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return failAll(fmt.Sprintf("Elasticsearch bulk error: %s", res.String()))
+	}
+
+	var out struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return failAll(fmt.Sprintf("failed to decode bulk response: %v", err))
+	}
+	if !out.Errors {
+		return nil
+	}
+	if len(out.Items) != len(items) {
+		return failAll(fmt.Sprintf("bulk response has %d items for %d documents", len(out.Items), len(items)))
+	}
+
+	var failed []bulkFailure
+	for i, result := range out.Items {
+		for _, r := range result {
+			if r.Error == nil && r.Status < 300 {
+				continue
+			}
+			f := bulkFailure{item: items[i], status: r.Status}
+			if r.Error != nil {
+				f.errorType, f.reason = r.Error.Type, r.Error.Reason
+			}
+			failed = append(failed, f)
+		}
+	}
+	return failed
+}
+
+// bulkWithRetry indexes items with the usual retry policy, sending again only the items
+// that failed with a retryable error
+func (c *Client) bulkWithRetry(ctx context.Context, items []*bulkItem, refresh string) error {
+	rejected, pending, err := c.bulkRetrying(ctx, items, refresh)
+	switch {
+	case err != nil:
+		return err
+	case len(rejected) > 0:
+		return fmt.Errorf("%d of %d documents were not indexed: %w", len(rejected)+len(pending), len(items), rejected[0])
+	case len(pending) > 0:
+		return fmt.Errorf("%d of %d documents were not indexed: %w", len(pending), len(items), pendingError(pending))
+	}
+	return nil
+}
+
+// bulkRetrying sends items up to three times with backoff, and returns the items
+// Elasticsearch rejected for good and those still failing with a retryable error
+func (c *Client) bulkRetrying(ctx context.Context, items []*bulkItem, refresh string) ([]bulkFailure, []bulkFailure, error) {
+	pending := items
+	var rejected, retryable []bulkFailure
+	for i := 0; i < 3 && len(pending) > 0; i++ {
+		var retry []*bulkItem
+		retryable = nil
+		for _, f := range c.bulk(ctx, pending, refresh) {
+			f.item.attempts++
+			metrics.ESBulkFailuresTotal.WithLabelValues(f.errorType).Inc()
+			if f.retryable() {
+				retry = append(retry, f.item)
+				retryable = append(retryable, f)
+			} else {
+				rejected = append(rejected, f)
+			}
+		}
+		pending = retry
+		if len(pending) == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(time.Duration(200*(1<<i)) * time.Millisecond):
+		}
+	}
+	return rejected, retryable, nil
+}
+
+// pendingError returns the error of the last item still failing
+func pendingError(pending []bulkFailure) error {
+	return pending[len(pending)-1]
+}
+
+// IndexDocuments indexes docs in a _bulk request with the usual retry policy and returns
+// once Elasticsearch has them. Documents it rejects for good, e.g. on a mapping conflict,
+// go to the dead-letter index, as sending them again cannot succeed; the error reports the
+// documents that were neither indexed nor dead-lettered.
+func (c *Client) IndexDocuments(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	items := make([]*bulkItem, len(docs))
+	for i, d := range docs {
+		items[i] = &bulkItem{Document: d}
+	}
+
+	rejected, pending, err := c.bulkRetrying(ctx, items, "")
+	if err != nil {
+		return err
+	}
+	metrics.ESBulkDocsTotal.WithLabelValues("indexed").Add(float64(len(items) - len(rejected) - len(pending)))
+	if len(pending) > 0 {
+		return fmt.Errorf("%d of %d documents were not indexed: %w", len(pending), len(items), pendingError(pending))
+	}
+	if len(rejected) == 0 {
+		return nil
+	}
+
+	letters := make([]DeadLetter, len(rejected))
+	for i, f := range rejected {
+		letters[i] = f.deadLetter()
+	}
+	if err := c.SaveDeadLetters(ctx, letters); err != nil {
+		return fmt.Errorf("failed to dead-letter %d rejected documents: %w", len(letters), err)
+	}
+	metrics.ESBulkDocsTotal.WithLabelValues("dead_lettered").Add(float64(len(letters)))
+	log.Printf("Dead-lettered %d documents Elasticsearch rejected, first: %v", len(letters), rejected[0])
+	return nil
+}
+
+// BulkConfig controls when the bulk indexer flushes and how it handles failures
+type BulkConfig struct {
+	FlushDocs     int           // documents per request, default 500
+	FlushBytes    int           // request body size that triggers a flush, default 5MiB
+	FlushInterval time.Duration // a partial batch waits at most this long, default 1s
+	QueueSize     int           // documents buffered before Add blocks, default 10000
+	MaxAttempts   int           // attempts of a document failing with a retryable error, default 5
+
+	// DeadLetters receives the documents that were rejected or ran out of attempts; when nil, they are logged and dropped
+	DeadLetters DeadLetterStore
+	// Spill receives the documents that ran out of attempts instead of DeadLetters, to be indexed later
+	Spill Spiller
+}
+
+// Spiller keeps documents Elasticsearch could not take for a later attempt; *wal.WAL implements it
+type Spiller interface {
+	Append(docs []Document) error
+}
+
+// BulkIndexer writes documents to Elasticsearch in the background, in _bulk requests.
+// A batch is sent once it holds FlushDocs documents or FlushBytes bytes, or FlushInterval
+// after the previous flush. Documents failing with a retryable error (429, 5xx, no
+// connection) are sent again with a backoff, then spilled for a later attempt when a
+// Spiller is configured; the others go to the dead-letter store.
+type BulkIndexer struct {
+	client *Client
+	cfg    BulkConfig
+
+	items  chan *bulkItem
+	done   chan struct{}
+	ctx    context.Context // cancelled when Close runs out of time
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewBulkIndexer starts a bulk indexer writing through client
+func NewBulkIndexer(client *Client, cfg BulkConfig) *BulkIndexer {
+	if cfg.FlushDocs <= 0 {
+		cfg.FlushDocs = 500
+	}
+	if cfg.FlushBytes <= 0 {
+		cfg.FlushBytes = 5 << 20
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &BulkIndexer{
+		client: client,
+		cfg:    cfg,
+		items:  make(chan *bulkItem, cfg.QueueSize),
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	b.wg.Add(1)
+	go b.loop()
+	return b
+}
+
+// Add queues doc for indexing under id in index, blocking while the queue is full. A nil
+// error means the document was queued, not that it was indexed.
+func (b *BulkIndexer) Add(ctx context.Context, index, id string, doc interface{}) error {
+	d, err := NewDocument(index, id, doc)
+	if err != nil {
+		return err
+	}
+	return b.AddDocument(ctx, d)
+}
+
+// AddDocument queues an already serialized document, like Add
+func (b *BulkIndexer) AddDocument(ctx context.Context, doc Document) error {
+	// Close waits for the senders, so that nothing is queued after the last flush
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrIndexerClosed
+	}
+	select {
+	case b.items <- &bulkItem{Document: doc}:
+		metrics.ESBulkQueueDepth.Inc()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// IndexLog queues a log document for indexing through the write alias of its log series
+func (b *BulkIndexer) IndexLog(ctx context.Context, doc *LogDocument) error {
+	return b.Add(ctx, b.client.LogIndex(doc), doc.ID, doc)
+}
+
+// Close stops accepting documents and flushes the queued ones; when ctx is done first,
+// the documents still failing get no further retries and are spilled or dead-lettered
+func (b *BulkIndexer) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+	b.mu.Unlock()
+
+	flushed := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+		b.cancel()
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		<-flushed
+		return ctx.Err()
+	}
+}
+
+func (b *BulkIndexer) loop() {
+	defer b.wg.Done()
+	ticker := time.NewTicker(b.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []*bulkItem
+	size := 0
+	add := func(it *bulkItem) {
+		batch = append(batch, it)
+		size += len(it.Body)
+		if len(batch) >= b.cfg.FlushDocs || size >= b.cfg.FlushBytes {
+			b.flush(batch)
+			batch, size = nil, 0
+		}
+	}
+
+	for {
+		select {
+		case it := <-b.items:
+			add(it)
+		case <-ticker.C:
+			if len(batch) > 0 {
+				b.flush(batch)
+				batch, size = nil, 0
+			}
+		case <-b.done:
+			for {
+				select {
+				case it := <-b.items:
+					add(it)
+				default:
+					if len(batch) > 0 {
+						b.flush(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush indexes a batch, retrying the failed documents until they are indexed or dead-lettered
+func (b *BulkIndexer) flush(batch []*bulkItem) {
+	pending := batch
+	for attempt := 0; len(pending) > 0; attempt++ {
+		failed := b.client.bulk(b.ctx, pending, "")
+		metrics.ESBulkDocsTotal.WithLabelValues("indexed").Add(float64(len(pending) - len(failed)))
+
+		var retry []*bulkItem
+		var retryErr error
+		var spill []Document
+		var dead []DeadLetter
+		for _, f := range failed {
+			f.item.attempts++
+			metrics.ESBulkFailuresTotal.WithLabelValues(f.errorType).Inc()
+			if f.retryable() {
+				if f.item.attempts < b.cfg.MaxAttempts && b.ctx.Err() == nil {
+					retry = append(retry, f.item)
+					retryErr = f
+					continue
+				}
+				if b.cfg.Spill != nil {
+					spill = append(spill, f.item.Document)
+					continue
+				}
+			}
+			dead = append(dead, f.deadLetter())
+		}
+		if len(spill) > 0 {
+			if err := b.cfg.Spill.Append(spill); err != nil {
+				log.Printf("Failed to spill %d documents: %v", len(spill), err)
+				metrics.ESBulkDocsTotal.WithLabelValues("dropped").Add(float64(len(spill)))
+			} else {
+				metrics.ESBulkDocsTotal.WithLabelValues("spilled").Add(float64(len(spill)))
+			}
+		}
+		b.deadLetter(dead)
+		metrics.ESBulkQueueDepth.Sub(float64(len(pending) - len(retry)))
+
+		if len(retry) == 0 {
+			return
+		}
+		metrics.ESBulkDocsTotal.WithLabelValues("retried").Add(float64(len(retry)))
+		log.Printf("Retrying %d of %d documents after bulk failure: %v", len(retry), len(pending), retryErr)
+		select {
+		case <-b.ctx.Done():
+		case <-time.After(time.Duration(200*(1<<attempt)) * time.Millisecond):
+		}
+		pending = retry
+	}
+}
+
+func (b *BulkIndexer) deadLetter(letters []DeadLetter) {
+	if len(letters) == 0 {
+		return
+	}
+	if b.cfg.DeadLetters != nil {
+		err := b.cfg.DeadLetters.SaveDeadLetters(b.ctx, letters)
+		if err == nil {
+			metrics.ESBulkDocsTotal.WithLabelValues("dead_lettered").Add(float64(len(letters)))
+			return
+		}
+		log.Printf("Failed to store %d dead letters: %v", len(letters), err)
+	}
+	metrics.ESBulkDocsTotal.WithLabelValues("dropped").Add(float64(len(letters)))
+	log.Printf("Dropped %d documents Elasticsearch did not index, first: %s: %s", len(letters), letters[0].ErrorType, letters[0].Reason)
+}
@@ -0,0 +1,163 @@
+package elastic
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestClient returns a client talking to handler as if it was Elasticsearch
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The client refuses servers that do not identify as Elasticsearch
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	c, err := New([]string{srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// bulkAction is an action line of a _bulk request body
+type bulkAction struct {
+	Index struct {
+		Index string `json:"_index"`
+		ID    string `json:"_id"`
+	} `json:"index"`
+}
+
+func readBulk(t *testing.T, r *http.Request) []bulkAction {
+	t.Helper()
+	var actions []bulkAction
+	sc := bufio.NewScanner(r.Body)
+	sc.Buffer(make([]byte, 1<<20), 1<<20)
+	for i := 0; sc.Scan(); i++ {
+		if i%2 == 1 {
+			continue // the document
+		}
+		var a bulkAction
+		if err := json.Unmarshal(sc.Bytes(), &a); err != nil {
+			t.Errorf("invalid bulk action %q: %v", sc.Text(), err)
+		}
+		actions = append(actions, a)
+	}
+	return actions
+}
+
+// fakeBulk answers _bulk requests item by item: documents whose ID starts with "retry"
+// fail with 429 on their first attempt, "busy" ones always do, and "reject" ones fail with
+// a mapping error. Dead letters are accepted unless deadLettersDown is set.
+type fakeBulk struct {
+	t               *testing.T
+	deadLettersDown bool
+
+	mu          sync.Mutex
+	requests    int
+	attempts    map[string]int
+	deadLetters []string
+}
+
+func (f *fakeBulk) handle(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/_bulk") {
+		f.t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	type result struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error,omitempty"`
+	}
+	fail := func(status int, typ string) result {
+		res := result{Status: status}
+		res.Error = &struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		}{typ, typ + " for the test"}
+		return res
+	}
+
+	var items []map[string]result
+	errors := false
+	for _, a := range readBulk(f.t, r) {
+		id := a.Index.ID
+		f.attempts[id]++
+		res := result{Status: 201}
+		switch {
+		case a.Index.Index == DeadLettersIndex:
+			if f.deadLettersDown {
+				res = fail(503, "unavailable_shards_exception")
+			} else {
+				f.deadLetters = append(f.deadLetters, a.Index.Index)
+			}
+		case strings.HasPrefix(id, "retry") && f.attempts[id] == 1, strings.HasPrefix(id, "busy"):
+			res = fail(429, "es_rejected_execution_exception")
+		case strings.HasPrefix(id, "reject"):
+			res = fail(400, "mapper_parsing_exception")
+		}
+		errors = errors || res.Error != nil
+		items = append(items, map[string]result{"index": res})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": errors, "items": items})
+}
+
+func TestIndexDocuments(t *testing.T) {
+	tests := []struct {
+		name            string
+		ids             []string
+		deadLettersDown bool
+		wantErr         bool
+		wantRequests    int
+		wantDeadLetters int
+	}{
+		{name: "all indexed", ids: []string{"a", "b"}, wantRequests: 1},
+		{name: "retryable failures are sent again", ids: []string{"a", "retry-1", "retry-2"}, wantRequests: 2},
+		{name: "rejected documents are dead-lettered", ids: []string{"a", "reject-1"}, wantRequests: 2, wantDeadLetters: 1},
+		{name: "documents out of attempts fail the call", ids: []string{"a", "busy-1"}, wantErr: true, wantRequests: 3},
+		{name: "dead letters that cannot be stored fail the call", ids: []string{"reject-1"}, deadLettersDown: true, wantErr: true, wantRequests: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeBulk{t: t, deadLettersDown: tt.deadLettersDown, attempts: make(map[string]int)}
+			c := newTestClient(t, f.handle)
+
+			var docs []Document
+			for _, id := range tt.ids {
+				d, err := NewDocument("logs-write", id, map[string]string{"id": id})
+				if err != nil {
+					t.Fatal(err)
+				}
+				docs = append(docs, d)
+			}
+			err := c.IndexDocuments(context.Background(), docs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IndexDocuments = %v, want error: %v", err, tt.wantErr)
+			}
+
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if f.requests != tt.wantRequests {
+				t.Errorf("%d bulk requests, want %d", f.requests, tt.wantRequests)
+			}
+			if len(f.deadLetters) != tt.wantDeadLetters {
+				t.Errorf("%d dead letters, want %d", len(f.deadLetters), tt.wantDeadLetters)
+			}
+		})
+	}
+}
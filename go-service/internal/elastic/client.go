@@ -5,28 +5,76 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
+
+	"anomaly-detection-platform/go-service/internal/metrics"
 )
 
 // Client wraps the Elasticsearch client with our custom methods
 type Client struct {
-	es *elasticsearch.Client
+	es      *elasticsearch.Client
+	healthy atomic.Bool
+	prefix  string // see SetIndexPrefix
 }
 
 // LogDocument represents a log entry stored in Elasticsearch
 type LogDocument struct {
-	ID        string    `json:"id,omitempty"`
-	Timestamp time.Time `json:"timestamp"`
-	LogText   string    `json:"log_text"`
-	IsAnomaly bool      `json:"is_anomaly"`
+	ID           string                 `json:"id,omitempty"`
+	BatchID      string                 `json:"batch_id,omitempty"`
+	Timestamp    time.Time              `json:"timestamp"`
+	LogText      string                 `json:"log_text"`
+	OriginalText string                 `json:"original_text,omitempty"`
+	IsAnomaly    bool                   `json:"is_anomaly"`
+	Label        string                 `json:"label,omitempty"`
+	Score        float64                `json:"score"`
+	Detector     string                 `json:"detector,omitempty"`
+	Reason       string                 `json:"reason,omitempty"`
+	Detections   []DetectorResult       `json:"detections,omitempty"`
+	ContentType  string                 `json:"content_type,omitempty"`
+	TemplateID   string                 `json:"template_id,omitempty"`
+	Params       []string               `json:"template_params,omitempty"`
+	IncidentID   string                 `json:"incident_id,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+
+	// Timestamp is the event time when the log carries one; ReceivedAt is when the service got it
+	ReceivedAt time.Time              `json:"received_at"`
+	Format     string                 `json:"log_format,omitempty"`
+	Level      string                 `json:"level,omitempty"`
+	Logger     string                 `json:"logger,omitempty"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+
+	// TraceID and SpanID link the log to a distributed trace
+	TraceID string `json:"trace_id,omitempty"`
+	SpanID  string `json:"span_id,omitempty"`
+
+	// Suppressed anomalies matched a suppression rule and are left out of anomaly queries by default
+	Suppressed    bool   `json:"suppressed,omitempty"`
+	SuppressionID string `json:"suppression_id,omitempty"`
+
+	// EffectiveLabel is the analyst label when there is feedback on the log, the model label otherwise
+	EffectiveLabel string     `json:"effective_label,omitempty"`
+	HumanLabel     string     `json:"human_label,omitempty"`
+	FeedbackAt     *time.Time `json:"feedback_at,omitempty"`
 }
 
-// NewClient creates a new Elasticsearch client
-func NewClient(addresses []string) (*Client, error) {
+// DetectorResult records the verdict of a single detector for a log
+type DetectorResult struct {
+	Detector  string  `json:"detector"`
+	Label     string  `json:"label,omitempty"`
+	Score     float64 `json:"score"`
+	IsAnomaly bool    `json:"is_anomaly"`
+	Reason    string  `json:"reason,omitempty"`
+}
+
+// New creates a client without contacting Elasticsearch; it reports itself unhealthy
+// until a health check reaches Elasticsearch
+func New(addresses []string) (*Client, error) {
 	cfg := elasticsearch.Config{
 		Addresses: addresses,
 	}
@@ -35,8 +83,15 @@ func NewClient(addresses []string) (*Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Elasticsearch client: %w", err)
 	}
+	return &Client{es: es}, nil
+}
 
-	client := &Client{es: es}
+// NewClient creates a new Elasticsearch client, failing when Elasticsearch cannot be reached
+func NewClient(addresses []string) (*Client, error) {
+	client, err := New(addresses)
+	if err != nil {
+		return nil, err
+	}
 
 	// Test connection with a short retry loop
 	var lastErr error
@@ -53,9 +108,74 @@ func NewClient(addresses []string) (*Client, error) {
 		return nil, fmt.Errorf("failed to connect to Elasticsearch: %w", lastErr)
 	}
 
+	client.healthy.Store(true)
 	return client, nil
 }
 
+// Ping checks that Elasticsearch answers
+func (c *Client) Ping(ctx context.Context) error {
+	res, err := esapi.PingRequest{}.Do(ctx, c.es)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("Elasticsearch ping error: %s", res.String())
+	}
+	return nil
+}
+
+// Healthy reports whether the last health check reached Elasticsearch
+func (c *Client) Healthy() bool {
+	return c.healthy.Load()
+}
+
+// Check pings Elasticsearch and updates the health of the client. When Elasticsearch
+// becomes reachable, onConnect runs first, e.g. to create the indices; the client is
+// reported healthy once it succeeds.
+func (c *Client) Check(ctx context.Context, onConnect func(context.Context) error) bool {
+	pctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	err := c.Ping(pctx)
+	cancel()
+
+	switch {
+	case err != nil:
+		if c.healthy.Swap(false) {
+			log.Printf("Lost connection to Elasticsearch: %v", err)
+		}
+	case !c.healthy.Load():
+		if onConnect != nil {
+			if err = onConnect(ctx); err != nil {
+				log.Printf("Elasticsearch is reachable but not ready: %v", err)
+				break
+			}
+		}
+		c.healthy.Store(true)
+		log.Println("Connected to Elasticsearch successfully")
+	}
+
+	if err != nil {
+		metrics.ElasticsearchUp.Set(0)
+		return false
+	}
+	metrics.ElasticsearchUp.Set(1)
+	return true
+}
+
+// Watch checks the connection every interval until ctx is done, reconnecting after an outage
+func (c *Client) Watch(ctx context.Context, interval time.Duration, onConnect func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Check(ctx, onConnect)
+		}
+	}
+}
+
 // IndexLog stores a log document in Elasticsearch
 func (c *Client) IndexLog(ctx context.Context, doc *LogDocument) error {
 	docBytes, err := json.Marshal(doc)
@@ -64,7 +184,7 @@ func (c *Client) IndexLog(ctx context.Context, doc *LogDocument) error {
 	}
 
 	req := esapi.IndexRequest{
-		Index:      "logs",
+		Index:      c.LogIndex(doc),
 		DocumentID: doc.ID,
 		Body:       bytes.NewReader(docBytes),
 		Refresh:    "true",
@@ -99,8 +219,9 @@ func (c *Client) SearchLogs(ctx context.Context, query map[string]interface{}) (
 	}
 
 	req := esapi.SearchRequest{
-		Index: []string{"logs"},
-		Body:  bytes.NewReader(queryBytes),
+		Index:             c.logIndices(),
+		Body:              bytes.NewReader(queryBytes),
+		IgnoreUnavailable: esapi.BoolPtr(true),
 	}
 
 	var res *esapi.Response
@@ -149,80 +270,148 @@ func (c *Client) SearchLogs(ctx context.Context, query map[string]interface{}) (
 	return documents, nil
 }
 
-// GetAnomalies retrieves all logs flagged as anomalies
-func (c *Client) GetAnomalies(ctx context.Context, from, size int) ([]LogDocument, error) {
+// LogQuery selects stored logs independently of the storage backend. Results are sorted
+// by timestamp, newest first.
+type LogQuery struct {
+	Start, End time.Time // event time range, unbounded when zero
+	Text       string    // logs whose text contains any of the words
+	TemplateID string
+	IncidentID string
+
+	// AnomaliesOnly leaves out normal logs, and suppressed anomalies unless IncludeSuppressed is set
+	AnomaliesOnly     bool
+	IncludeSuppressed bool
+
+	From, Size int
+
+	// Cursor continues from the NextCursor of an earlier page; it carries that page's query,
+	// so only Size applies besides it
+	Cursor string `json:"-"`
+}
+
+// FindLogs retrieves the logs matching q
+func (c *Client) FindLogs(ctx context.Context, q LogQuery) ([]LogDocument, error) {
 	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"filter": []map[string]interface{}{
-					{
-						"term": map[string]interface{}{
-							"is_anomaly": true,
-						},
-					},
-				},
-			},
-		},
-		"sort": []map[string]interface{}{
-			{
-				"timestamp": map[string]interface{}{
-					"order": "desc",
-				},
-			},
-		},
-		"from": from,
-		"size": size,
+		"query": logsQuery(q),
+		"sort":  logsSort,
+		"from":  q.From,
+		"size":  q.Size,
 	}
 
 	return c.SearchLogs(ctx, query)
 }
 
-// GetLogsByTimeRange retrieves logs within a time range
-func (c *Client) GetLogsByTimeRange(ctx context.Context, start, end time.Time, from, size int) ([]LogDocument, error) {
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"range": map[string]interface{}{
-				"timestamp": map[string]interface{}{
-					"gte": start.Format(time.RFC3339),
-					"lte": end.Format(time.RFC3339),
-				},
-			},
-		},
-		"sort": []map[string]interface{}{
-			{
-				"timestamp": map[string]interface{}{
-					"order": "desc",
-				},
-			},
+// logsSort orders logs newest first. search_after only pages searches on a point in time,
+// whose implicit _shard_doc tiebreaker keeps it from skipping logs of the same timestamp;
+// sorting on id instead would fail on the legacy logs index, which maps it as text.
+var logsSort = []map[string]interface{}{
+	{"timestamp": map[string]interface{}{"order": "desc"}},
+}
+
+// logsQuery returns the query clause selecting the logs matching q
+func logsQuery(q LogQuery) map[string]interface{} {
+	filters := []map[string]interface{}{}
+	if !q.Start.IsZero() || !q.End.IsZero() {
+		timeRange := map[string]interface{}{}
+		if !q.Start.IsZero() {
+			timeRange["gte"] = q.Start.Format(time.RFC3339)
+		}
+		if !q.End.IsZero() {
+			timeRange["lte"] = q.End.Format(time.RFC3339)
+		}
+		filters = append(filters, map[string]interface{}{
+			"range": map[string]interface{}{"timestamp": timeRange},
+		})
+	}
+	if q.AnomaliesOnly {
+		filters = append(filters, map[string]interface{}{
+			"term": map[string]interface{}{"is_anomaly": true},
+		})
+	}
+	if q.TemplateID != "" {
+		filters = append(filters, map[string]interface{}{
+			"term": map[string]interface{}{"template_id": q.TemplateID},
+		})
+	}
+	if q.IncidentID != "" {
+		filters = append(filters, map[string]interface{}{
+			"term": map[string]interface{}{"incident_id": q.IncidentID},
+		})
+	}
+
+	must := []map[string]interface{}{}
+	if q.Text != "" {
+		must = append(must, map[string]interface{}{
+			"match": map[string]interface{}{"log_text": q.Text},
+		})
+	}
+
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must":     must,
+			"filter":   filters,
+			"must_not": suppressedClauses(q.IncludeSuppressed || !q.AnomaliesOnly),
 		},
-		"from": from,
-		"size": size,
 	}
+}
 
-	return c.SearchLogs(ctx, query)
+// GetAnomalies retrieves all logs flagged as anomalies, leaving out suppressed ones unless includeSuppressed is set
+func (c *Client) GetAnomalies(ctx context.Context, from, size int, includeSuppressed bool) ([]LogDocument, error) {
+	return c.FindLogs(ctx, LogQuery{AnomaliesOnly: true, IncludeSuppressed: includeSuppressed, From: from, Size: size})
+}
+
+// GetLogsByTimeRange retrieves logs within a time range
+func (c *Client) GetLogsByTimeRange(ctx context.Context, start, end time.Time, from, size int) ([]LogDocument, error) {
+	return c.FindLogs(ctx, LogQuery{Start: start, End: end, From: from, Size: size})
 }
 
-// CreateIndex creates the logs index with proper mapping
+// CreateIndex installs the template of the log indices, creates the first generation of
+// each log series and creates the auxiliary indices with proper mappings
 func (c *Client) CreateIndex(ctx context.Context) error {
-	mapping := `{
-		"mappings": {
-			"properties": {
-				"timestamp": {
-					"type": "date"
-				},
-				"log_text": {
-					"type": "text",
-					"analyzer": "standard"
-				},
-				"is_anomaly": {
-					"type": "boolean"
-				}
-			}
+	if err := c.putLogsTemplate(ctx); err != nil {
+		return err
+	}
+	for _, series := range []string{LogsSeries, AnomaliesSeries} {
+		if err := c.bootstrapSeries(ctx, series); err != nil {
+			return err
 		}
-	}`
+	}
+	if err := c.createIndex(ctx, c.index(TemplatesIndex), templatesMapping); err != nil {
+		return err
+	}
+	if err := c.createIndex(ctx, c.index(VolumeAnomaliesIndex), volumeAnomaliesMapping); err != nil {
+		return err
+	}
+	if err := c.createIndex(ctx, c.index(IncidentsIndex), incidentsMapping); err != nil {
+		return err
+	}
+	if err := c.createIndex(ctx, c.index(FeedbackIndex), feedbackMapping); err != nil {
+		return err
+	}
+	if err := c.createIndex(ctx, c.index(DeadLettersIndex), deadLettersMapping); err != nil {
+		return err
+	}
+	return c.createIndex(ctx, c.index(SuppressionsIndex), suppressionsMapping)
+}
 
+// suppressedClauses returns the must_not clauses leaving out logs silenced by a suppression rule
+func suppressedClauses(includeSuppressed bool) []map[string]interface{} {
+	if includeSuppressed {
+		return []map[string]interface{}{}
+	}
+	return []map[string]interface{}{
+		{
+			"term": map[string]interface{}{
+				"suppressed": true,
+			},
+		},
+	}
+}
+
+// createIndex creates an index, treating an already existing index as success
+func (c *Client) createIndex(ctx context.Context, name, mapping string) error {
 	req := esapi.IndicesCreateRequest{
-		Index: "logs",
+		Index: name,
 		Body:  strings.NewReader(mapping),
 	}
 
@@ -251,63 +440,24 @@ func (c *Client) CreateIndex(ctx context.Context) error {
 	return lastErr
 }
 
-// SearchAnomaliesByText searches for anomalies containing specific text
-func (c *Client) SearchAnomaliesByText(ctx context.Context, searchText string, from, size int) ([]LogDocument, error) {
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"must": []map[string]interface{}{
-					{
-						"term": map[string]interface{}{
-							"is_anomaly": true,
-						},
-					},
-					{
-						"match": map[string]interface{}{
-							"log_text": searchText,
-						},
-					},
-				},
-			},
-		},
-		"sort": []map[string]interface{}{
-			{
-				"timestamp": map[string]interface{}{
-					"order": "desc",
-				},
-			},
-		},
-		"from": from,
-		"size": size,
-	}
-
-	return c.SearchLogs(ctx, query)
+// SearchAnomaliesByText searches for anomalies containing specific text, leaving out suppressed ones unless includeSuppressed is set
+func (c *Client) SearchAnomaliesByText(ctx context.Context, searchText string, from, size int, includeSuppressed bool) ([]LogDocument, error) {
+	return c.FindLogs(ctx, LogQuery{Text: searchText, AnomaliesOnly: true, IncludeSuppressed: includeSuppressed, From: from, Size: size})
 }
 
 // SearchLogsByText searches for logs containing specific text
 func (c *Client) SearchLogsByText(ctx context.Context, searchText string, from, size int) ([]LogDocument, error) {
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"match": map[string]interface{}{
-				"log_text": searchText,
-			},
-		},
-		"sort": []map[string]interface{}{
-			{
-				"timestamp": map[string]interface{}{
-					"order": "desc",
-				},
-			},
-		},
-		"from": from,
-		"size": size,
-	}
+	return c.FindLogs(ctx, LogQuery{Text: searchText, From: from, Size: size})
+}
 
-	return c.SearchLogs(ctx, query)
+// HistogramBucket is one interval of a date histogram in the stats responses
+type HistogramBucket struct {
+	KeyAsString string `json:"key_as_string"`
+	DocCount    int    `json:"doc_count"`
 }
 
-// GetAnomalyStats retrieves statistics about anomalies
-func (c *Client) GetAnomalyStats(ctx context.Context, startTime, endTime time.Time) (map[string]interface{}, error) {
+// GetAnomalyStats retrieves statistics about anomalies, leaving out suppressed ones unless includeSuppressed is set
+func (c *Client) GetAnomalyStats(ctx context.Context, startTime, endTime time.Time, includeSuppressed bool) (map[string]interface{}, error) {
 	query := map[string]interface{}{
 		"query": map[string]interface{}{
 			"bool": map[string]interface{}{
@@ -326,6 +476,7 @@ func (c *Client) GetAnomalyStats(ctx context.Context, startTime, endTime time.Ti
 						},
 					},
 				},
+				"must_not": suppressedClauses(includeSuppressed),
 			},
 		},
 		"aggs": map[string]interface{}{
@@ -350,8 +501,9 @@ func (c *Client) GetAnomalyStats(ctx context.Context, startTime, endTime time.Ti
 	}
 
 	req := esapi.SearchRequest{
-		Index: []string{"logs"},
-		Body:  bytes.NewReader(queryBytes),
+		Index:             c.logIndices(),
+		Body:              bytes.NewReader(queryBytes),
+		IgnoreUnavailable: esapi.BoolPtr(true),
 	}
 
 	var res *esapi.Response
@@ -386,10 +538,7 @@ func (c *Client) GetAnomalyStats(ctx context.Context, startTime, endTime time.Ti
 				Value int `json:"value"`
 			} `json:"total_anomalies"`
 			AnomaliesOverTime struct {
-				Buckets []struct {
-					KeyAsString string `json:"key_as_string"`
-					DocCount    int    `json:"doc_count"`
-				} `json:"buckets"`
+				Buckets []HistogramBucket `json:"buckets"`
 			} `json:"anomalies_over_time"`
 		} `json:"aggregations"`
 	}
@@ -428,9 +577,23 @@ func (c *Client) GetLogStats(ctx context.Context, startTime, endTime time.Time)
 				},
 			},
 			"anomaly_count": map[string]interface{}{
+				"filter": map[string]interface{}{
+					"bool": map[string]interface{}{
+						"filter": []map[string]interface{}{
+							{
+								"term": map[string]interface{}{
+									"is_anomaly": true,
+								},
+							},
+						},
+						"must_not": suppressedClauses(false),
+					},
+				},
+			},
+			"suppressed_count": map[string]interface{}{
 				"filter": map[string]interface{}{
 					"term": map[string]interface{}{
-						"is_anomaly": true,
+						"suppressed": true,
 					},
 				},
 			},
@@ -450,8 +613,9 @@ func (c *Client) GetLogStats(ctx context.Context, startTime, endTime time.Time)
 	}
 
 	req := esapi.SearchRequest{
-		Index: []string{"logs"},
-		Body:  bytes.NewReader(queryBytes),
+		Index:             c.logIndices(),
+		Body:              bytes.NewReader(queryBytes),
+		IgnoreUnavailable: esapi.BoolPtr(true),
 	}
 
 	var res *esapi.Response
@@ -488,11 +652,11 @@ func (c *Client) GetLogStats(ctx context.Context, startTime, endTime time.Time)
 			AnomalyCount struct {
 				DocCount int `json:"doc_count"`
 			} `json:"anomaly_count"`
+			SuppressedCount struct {
+				DocCount int `json:"doc_count"`
+			} `json:"suppressed_count"`
 			LogsOverTime struct {
-				Buckets []struct {
-					KeyAsString string `json:"key_as_string"`
-					DocCount    int    `json:"doc_count"`
-				} `json:"buckets"`
+				Buckets []HistogramBucket `json:"buckets"`
 			} `json:"logs_over_time"`
 		} `json:"aggregations"`
 	}
@@ -502,11 +666,12 @@ func (c *Client) GetLogStats(ctx context.Context, startTime, endTime time.Time)
 	}
 
 	stats := map[string]interface{}{
-		"total_logs":     searchResponse.Aggregations.TotalLogs.Value,
-		"anomaly_count":  searchResponse.Aggregations.AnomalyCount.DocCount,
-		"normal_count":   searchResponse.Aggregations.TotalLogs.Value - searchResponse.Aggregations.AnomalyCount.DocCount,
-		"logs_over_time": searchResponse.Aggregations.LogsOverTime.Buckets,
-		"anomaly_rate":   float64(searchResponse.Aggregations.AnomalyCount.DocCount) / float64(searchResponse.Aggregations.TotalLogs.Value),
+		"total_logs":       searchResponse.Aggregations.TotalLogs.Value,
+		"anomaly_count":    searchResponse.Aggregations.AnomalyCount.DocCount,
+		"suppressed_count": searchResponse.Aggregations.SuppressedCount.DocCount,
+		"normal_count":     searchResponse.Aggregations.TotalLogs.Value - searchResponse.Aggregations.AnomalyCount.DocCount - searchResponse.Aggregations.SuppressedCount.DocCount,
+		"logs_over_time":   searchResponse.Aggregations.LogsOverTime.Buckets,
+		"anomaly_rate":     float64(searchResponse.Aggregations.AnomalyCount.DocCount) / float64(searchResponse.Aggregations.TotalLogs.Value),
 		"time_range": map[string]string{
 			"start": startTime.Format(time.RFC3339),
 			"end":   endTime.Format(time.RFC3339),
@@ -518,11 +683,14 @@ func (c *Client) GetLogStats(ctx context.Context, startTime, endTime time.Time)
 
 // PushDetectionResult pushes a detection result directly to Elasticsearch
 func (c *Client) PushDetectionResult(ctx context.Context, logText string, isAnomaly bool, metadata map[string]interface{}) error {
+	now := time.Now().UTC()
 	doc := &LogDocument{
-		ID:        fmt.Sprintf("%d", time.Now().UnixNano()),
-		Timestamp: time.Now().UTC(),
-		LogText:   logText,
-		IsAnomaly: isAnomaly,
+		ID:         fmt.Sprintf("%d", now.UnixNano()),
+		Timestamp:  now,
+		ReceivedAt: now,
+		LogText:    logText,
+		IsAnomaly:  isAnomaly,
+		Metadata:   metadata,
 	}
 
 	return c.IndexLog(ctx, doc)
@@ -530,57 +698,80 @@ func (c *Client) PushDetectionResult(ctx context.Context, logText string, isAnom
 
 // BulkPushDetectionResults pushes multiple detection results in a single operation
 func (c *Client) BulkPushDetectionResults(ctx context.Context, results []DetectionResult) error {
-	if len(results) == 0 {
-		return nil
-	}
-
-	var bulkBody strings.Builder
-
-	for _, result := range results {
-		// Index action
-		indexAction := map[string]interface{}{
-			"index": map[string]interface{}{
-				"_index": "logs",
-				"_id":    result.ID,
-			},
-		}
+	return c.BulkIndexLogs(ctx, DetectionResultDocuments(results))
+}
 
-		// Document
-		doc := LogDocument{
-			ID:        result.ID,
-			Timestamp: result.Timestamp,
-			LogText:   result.LogText,
-			IsAnomaly: result.IsAnomaly,
+// DetectionResultDocuments converts detection results pushed by external detectors to log documents
+func DetectionResultDocuments(results []DetectionResult) []LogDocument {
+	receivedAt := time.Now().UTC()
+	docs := make([]LogDocument, len(results))
+	for i, result := range results {
+		docs[i] = LogDocument{
+			ID:         result.ID,
+			Timestamp:  result.Timestamp,
+			ReceivedAt: receivedAt,
+			LogText:    result.LogText,
+			IsAnomaly:  result.IsAnomaly,
+			Label:      result.Label,
+			Score:      result.Score,
+			Metadata:   result.Metadata,
 		}
+	}
+	return docs
+}
 
-		// Add to bulk body
-		indexBytes, _ := json.Marshal(indexAction)
-		docBytes, _ := json.Marshal(doc)
+// BulkIndexLogs stores several log documents in a single operation, failing when any of them was not indexed
+func (c *Client) BulkIndexLogs(ctx context.Context, docs []LogDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
 
-		bulkBody.Write(indexBytes)
-		bulkBody.WriteString("\n")
-		bulkBody.Write(docBytes)
-		bulkBody.WriteString("\n")
+	items := make([]*bulkItem, len(docs))
+	for i := range docs {
+		doc, err := NewDocument(c.LogIndex(&docs[i]), docs[i].ID, &docs[i])
+		if err != nil {
+			return err
+		}
+		items[i] = &bulkItem{Document: doc}
 	}
+	return c.bulkWithRetry(ctx, items, "true")
+}
 
-	req := esapi.BulkRequest{
-		Index:   "logs",
-		Body:    strings.NewReader(bulkBody.String()),
-		Refresh: "true",
+// DetectionResult represents a detection result for bulk operations
+type DetectionResult struct {
+	ID        string                 `json:"id"`
+	Timestamp time.Time              `json:"timestamp"`
+	LogText   string                 `json:"log_text"`
+	IsAnomaly bool                   `json:"is_anomaly"`
+	Label     string                 `json:"label,omitempty"`
+	Score     float64                `json:"score,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// indexDocument stores doc under id in index with the usual retry policy
+func (c *Client) indexDocument(ctx context.Context, index, id string, doc interface{}) error {
+	docBytes, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal document: %w", err)
 	}
 
 	var lastErr error
 	for i := 0; i < 3; i++ {
+		req := esapi.IndexRequest{
+			Index:      index,
+			DocumentID: id,
+			Body:       bytes.NewReader(docBytes),
+			Refresh:    "true",
+		}
 		res, err := req.Do(ctx, c.es)
 		if err != nil {
-			lastErr = fmt.Errorf("failed to bulk index documents: %w", err)
+			lastErr = err
 		} else {
-			defer res.Body.Close()
-			if res.IsError() {
-				lastErr = fmt.Errorf("Elasticsearch bulk error: %s", res.String())
-			} else {
+			res.Body.Close()
+			if !res.IsError() {
 				return nil
 			}
+			lastErr = fmt.Errorf("Elasticsearch error: %s", res.String())
 		}
 		select {
 		case <-ctx.Done():
@@ -591,12 +782,55 @@ func (c *Client) BulkPushDetectionResults(ctx context.Context, results []Detecti
 	return lastErr
 }
 
-// DetectionResult represents a detection result for bulk operations
-type DetectionResult struct {
-	ID        string    `json:"id"`
-	Timestamp time.Time `json:"timestamp"`
-	LogText   string    `json:"log_text"`
-	IsAnomaly bool      `json:"is_anomaly"`
+// search runs query against index with the usual retry policy and decodes the response into out
+func (c *Client) search(ctx context.Context, index string, query map[string]interface{}, out interface{}) error {
+	return c.searchIndices(ctx, []string{index}, false, query, out)
+}
+
+// searchIndices is search over several indices or patterns, skipping the indices that do
+// not exist when ignoreUnavailable is set; a query on a point in time takes no indices and
+// cannot set ignoreUnavailable
+func (c *Client) searchIndices(ctx context.Context, indices []string, ignoreUnavailable bool, query map[string]interface{}, out interface{}) error {
+	queryBytes, err := json.Marshal(query)
+	if err != nil {
+		return fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	var res *esapi.Response
+	var lastErr error
+	for i := 0; i < 3; i++ {
+		req := esapi.SearchRequest{
+			Index: indices,
+			Body:  bytes.NewReader(queryBytes),
+		}
+		if ignoreUnavailable {
+			req.IgnoreUnavailable = esapi.BoolPtr(true)
+		}
+		res, err = req.Do(ctx, c.es)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to search: %w", err)
+		} else if res.IsError() {
+			lastErr = fmt.Errorf("Elasticsearch search error: %s", res.String())
+			res.Body.Close()
+		} else {
+			lastErr = nil
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(200*(1<<i)) * time.Millisecond):
+		}
+	}
+	if lastErr != nil {
+		return lastErr
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode search response: %w", err)
+	}
+	return nil
 }
 
 // Close closes the Elasticsearch client
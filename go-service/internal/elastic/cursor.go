@@ -0,0 +1,311 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// pitKeepAlive is how long a point in time outlives the last page read from it
+const pitKeepAlive = "2m"
+
+// ErrInvalidCursor is returned for a cursor that is malformed or whose point in time expired
+var ErrInvalidCursor = errors.New("invalid or expired cursor")
+
+// LogPage is one page of the logs matching a LogQuery
+type LogPage struct {
+	Logs  []LogDocument
+	Total int // all the logs matching the query, not only those on the page
+
+	// NextCursor continues after the page; it is empty on the last page
+	NextCursor string
+}
+
+// LogCursor is the state behind the opaque cursor of a page: the query and the sort values
+// of the last log of the page. With Elasticsearch, it also holds the point in time keeping
+// the results stable while paging.
+type LogCursor struct {
+	PIT   string        `json:"pit,omitempty"`
+	After []interface{} `json:"after"`
+	Query LogQuery      `json:"query"`
+}
+
+// EncodeLogCursor turns a cursor into the opaque string handed out by the API
+func EncodeLogCursor(cur LogCursor) (string, error) {
+	cur.Query.From, cur.Query.Size = 0, 0
+	return encodeCursor(cur)
+}
+
+// DecodeLogCursor parses a cursor handed out by EncodeLogCursor
+func DecodeLogCursor(s string) (LogCursor, error) {
+	var cur LogCursor
+	if err := decodeCursor(s, &cur); err != nil || len(cur.After) == 0 {
+		return cur, ErrInvalidCursor
+	}
+	return cur, nil
+}
+
+// IncidentCursor is the state behind the cursor of an incident listing: its query and the
+// sort values of the last incident of the page
+type IncidentCursor struct {
+	After []interface{} `json:"after"`
+	Query IncidentQuery `json:"query"`
+}
+
+// EncodeIncidentCursor turns a cursor into the opaque string handed out by the API
+func EncodeIncidentCursor(cur IncidentCursor) (string, error) {
+	cur.Query.From, cur.Query.Size = 0, 0
+	return encodeCursor(cur)
+}
+
+// DecodeIncidentCursor parses a cursor handed out by EncodeIncidentCursor
+func DecodeIncidentCursor(s string) (IncidentCursor, error) {
+	var cur IncidentCursor
+	if err := decodeCursor(s, &cur); err != nil || len(cur.After) != 2 {
+		return cur, ErrInvalidCursor
+	}
+	return cur, nil
+}
+
+// volumeCursor is the state behind the cursor of a volume anomaly listing
+type volumeCursor struct {
+	After []interface{}      `json:"after"`
+	Query VolumeAnomalyQuery `json:"query"`
+}
+
+func encodeCursor(cur interface{}) (string, error) {
+	b, err := json.Marshal(cur)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(s string, cur interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber() // keep sort values such as epoch millis exact
+	if err := dec.Decode(cur); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// PageLogs retrieves a page of the logs matching q with their total count. The next pages
+// are read with search_after from a point in time, so deep pages are as cheap as the first
+// and logs indexed meanwhile do not shift them. The point in time is only opened when the
+// first page is not also the last; the first page is then read again from it, as the sort
+// values of a search on a point in time carry its tiebreaker.
+func (c *Client) PageLogs(ctx context.Context, q LogQuery) (*LogPage, error) {
+	cur := LogCursor{Query: q}
+	if q.Cursor != "" {
+		var err error
+		if cur, err = DecodeLogCursor(q.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	page, last, err := c.searchLogPage(ctx, &cur, q)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil && cur.PIT == "" {
+		if cur.PIT, err = c.openPointInTime(ctx); err != nil {
+			return nil, err
+		}
+		if page, last, err = c.searchLogPage(ctx, &cur, q); err != nil {
+			c.closePointInTime(ctx, cur.PIT)
+			return nil, err
+		}
+	}
+	if last == nil {
+		c.closePointInTime(ctx, cur.PIT)
+		return page, nil
+	}
+
+	cur.After = last
+	next, err := EncodeLogCursor(cur)
+	if err != nil {
+		return nil, err
+	}
+	page.NextCursor = next
+	return page, nil
+}
+
+// searchLogPage reads the page of q at cur, from the point in time of cur when it has one,
+// and updates the point in time ID. It returns the sort values of the last log of the page
+// when there is a next page, nil otherwise.
+func (c *Client) searchLogPage(ctx context.Context, cur *LogCursor, q LogQuery) (*LogPage, []interface{}, error) {
+	// One log more than the page tells whether there is a next page
+	fetch := 0
+	if q.Size > 0 {
+		fetch = q.Size + 1
+	}
+	query := map[string]interface{}{
+		"query":            logsQuery(cur.Query),
+		"sort":             logsSort,
+		"size":             fetch,
+		"track_total_hits": true,
+	}
+	if cur.After != nil {
+		query["search_after"] = cur.After
+	} else {
+		query["from"] = q.From
+	}
+	var indices []string
+	if cur.PIT != "" {
+		query["pit"] = map[string]interface{}{"id": cur.PIT, "keep_alive": pitKeepAlive}
+	} else {
+		indices = c.logIndices()
+	}
+
+	var searchResponse struct {
+		PITID string `json:"pit_id"`
+		Hits  struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source LogDocument   `json:"_source"`
+				Sort   []interface{} `json:"sort"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := c.searchIndices(ctx, indices, indices != nil, query, &searchResponse); err != nil {
+		if q.Cursor != "" && strings.Contains(err.Error(), "search_context_missing_exception") {
+			return nil, nil, ErrInvalidCursor
+		}
+		return nil, nil, err
+	}
+
+	hits := searchResponse.Hits.Hits
+	page := &LogPage{
+		Logs:  make([]LogDocument, 0, len(hits)),
+		Total: searchResponse.Hits.Total.Value,
+	}
+	for i := 0; i < len(hits) && i < q.Size; i++ {
+		page.Logs = append(page.Logs, hits[i].Source)
+	}
+
+	// Each search may hand out a new ID for the point in time
+	if searchResponse.PITID != "" {
+		cur.PIT = searchResponse.PITID
+	}
+	if len(hits) < fetch || fetch == 0 {
+		return page, nil, nil
+	}
+	return page, hits[q.Size-1].Sort, nil
+}
+
+// searchPage reads a page of the documents of index matching query, in the order of sort,
+// whose last field must tell every document apart. The page starts after the sort values
+// after, or at from when there are none, and is decoded into out, a pointer to a slice.
+// It returns the number of matching documents and, when there is a next page, the sort
+// values of the last document of the page. Unlike log listings, pages are read without a
+// point in time: the incident and volume anomaly indices are small and rarely change
+// under a reader.
+func (c *Client) searchPage(ctx context.Context, index string, query map[string]interface{}, sort []map[string]interface{}, after []interface{}, from, size int, out interface{}) (int, []interface{}, error) {
+	// One document more than the page tells whether there is a next page
+	fetch := 0
+	if size > 0 {
+		fetch = size + 1
+	}
+	body := map[string]interface{}{
+		"query":            query,
+		"sort":             sort,
+		"size":             fetch,
+		"track_total_hits": true,
+	}
+	if after != nil {
+		body["search_after"] = after
+	} else {
+		body["from"] = from
+	}
+
+	var searchResponse struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source json.RawMessage `json:"_source"`
+				Sort   []interface{}   `json:"sort"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := c.search(ctx, index, body, &searchResponse); err != nil {
+		return 0, nil, err
+	}
+
+	hits := searchResponse.Hits.Hits
+	sources := make([]json.RawMessage, 0, size)
+	for i := 0; i < len(hits) && i < size; i++ {
+		sources = append(sources, hits[i].Source)
+	}
+	b, err := json.Marshal(sources)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to decode search results: %w", err)
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return 0, nil, fmt.Errorf("failed to decode search results: %w", err)
+	}
+
+	if fetch == 0 || len(hits) < fetch {
+		return searchResponse.Hits.Total.Value, nil, nil
+	}
+	return searchResponse.Hits.Total.Value, hits[size-1].Sort, nil
+}
+
+// openPointInTime opens a point in time over the log indices
+func (c *Client) openPointInTime(ctx context.Context) (string, error) {
+	res, err := c.perform(ctx, func() esapi.Request {
+		return esapi.OpenPointInTimeRequest{
+			Index:             c.logIndices(),
+			KeepAlive:         pitKeepAlive,
+			IgnoreUnavailable: esapi.BoolPtr(true),
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to open point in time: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return "", fmt.Errorf("failed to open point in time: %s", res.String())
+	}
+
+	var pit struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&pit); err != nil {
+		return "", fmt.Errorf("failed to decode point in time: %w", err)
+	}
+	return pit.ID, nil
+}
+
+// closePointInTime releases a point in time once the last page was read; failures only
+// leave it to expire
+func (c *Client) closePointInTime(ctx context.Context, id string) {
+	if id == "" {
+		return
+	}
+	body, err := json.Marshal(map[string]string{"id": id})
+	if err != nil {
+		return
+	}
+	res, err := esapi.ClosePointInTimeRequest{Body: bytes.NewReader(body)}.Do(ctx, c.es)
+	if err != nil {
+		log.Printf("Failed to close point in time: %v", err)
+		return
+	}
+	res.Body.Close()
+}
@@ -0,0 +1,288 @@
+package elastic
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLogCursorRoundTrip(t *testing.T) {
+	cur := LogCursor{
+		PIT:   "pit-1",
+		After: []interface{}{int64(1718000000123), "log-9"},
+		Query: LogQuery{Text: "timeout", AnomaliesOnly: true, From: 20, Size: 10},
+	}
+	s, err := EncodeLogCursor(cur)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeLogCursor(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The page position is not part of the cursor, and sort values stay exact
+	want := cur
+	want.Query.From, want.Query.Size = 0, 0
+	want.After = []interface{}{json.Number("1718000000123"), "log-9"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeLogCursorRejects(t *testing.T) {
+	valid, err := EncodeLogCursor(LogCursor{After: []interface{}{"x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "not a cursor!"},
+		{"not json", encode("after=x")},
+		{"truncated", valid[:len(valid)-4]},
+		{"without sort values", encode(`{"pit":"p","query":{}}`)},
+		{"empty sort values", encode(`{"after":[]}`)},
+		{"sort values of the wrong type", encode(`{"after":"x"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeLogCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("err = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+// fakeLogSearch serves n logs sorted by position, through searches and points in time,
+// and records the requests it receives. Like Elasticsearch, it rejects sorts on the text
+// fields of mapping and adds a _shard_doc tiebreaker to the sort values of searches on a
+// point in time.
+type fakeLogSearch struct {
+	t       *testing.T
+	n       int
+	mapping map[string]string // field types
+
+	mu       sync.Mutex
+	requests []string
+	paths    []string
+}
+
+func (f *fakeLogSearch) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.paths = append(f.paths, r.Method+" "+r.URL.Path)
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/_pit"):
+		f.requests = append(f.requests, "open")
+		w.Write([]byte(`{"id": "pit-1"}`))
+	case r.Method == http.MethodDelete && r.URL.Path == "/_pit":
+		f.requests = append(f.requests, "close")
+		w.Write([]byte(`{"succeeded": true}`))
+	case strings.HasSuffix(r.URL.Path, "/_search"):
+		var body struct {
+			Size        int                          `json:"size"`
+			From        int                          `json:"from"`
+			SearchAfter []json.Number                `json:"search_after"`
+			Sort        []map[string]json.RawMessage `json:"sort"`
+			PIT         *struct {
+				ID string `json:"id"`
+			} `json:"pit"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Error(err)
+		}
+		for _, s := range body.Sort {
+			for field := range s {
+				if f.mapping[field] == "text" {
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprintf(w, `{"error": {"type": "illegal_argument_exception", "reason": "Fielddata is disabled on [%s] in [logs]"}}`, field)
+					return
+				}
+			}
+		}
+		sortValues := len(body.Sort)
+		if body.PIT != nil {
+			sortValues++ // _shard_doc
+		}
+		if body.SearchAfter != nil && len(body.SearchAfter) != sortValues {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": {"type": "illegal_argument_exception", "reason": "search_after has %d value(s) but sort has %d"}}`, len(body.SearchAfter), sortValues)
+			return
+		}
+		start := body.From
+		if body.SearchAfter != nil {
+			last, _ := body.SearchAfter[0].Int64()
+			start = int(last) + 1
+		}
+
+		type hit struct {
+			Source map[string]string `json:"_source"`
+			Sort   []int             `json:"sort"`
+		}
+		hits := []hit{}
+		for i := start; i < f.n && len(hits) < body.Size; i++ {
+			sort := make([]int, sortValues)
+			for j := range sort {
+				sort[j] = i
+			}
+			hits = append(hits, hit{Source: map[string]string{"id": fmt.Sprintf("log-%d", i)}, Sort: sort})
+		}
+		res := map[string]interface{}{
+			"hits": map[string]interface{}{"total": map[string]int{"value": f.n}, "hits": hits},
+		}
+		if body.PIT != nil {
+			f.requests = append(f.requests, "search pit")
+			res["pit_id"] = body.PIT.ID
+		} else {
+			f.requests = append(f.requests, "search")
+		}
+		json.NewEncoder(w).Encode(res)
+	default:
+		f.t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPageLogsPointInTime(t *testing.T) {
+	tests := []struct {
+		name         string
+		logs, size   int
+		mapping      map[string]string
+		wantPages    [][]string
+		wantRequests []string
+	}{
+		{
+			name:         "single page opens no point in time",
+			logs:         2,
+			size:         2,
+			wantPages:    [][]string{{"log-0", "log-1"}},
+			wantRequests: []string{"search"},
+		},
+		{
+			name:         "no logs",
+			size:         2,
+			wantPages:    [][]string{nil},
+			wantRequests: []string{"search"},
+		},
+		{
+			name:         "count only",
+			logs:         3,
+			wantPages:    [][]string{nil},
+			wantRequests: []string{"search"},
+		},
+		{
+			name:      "several pages",
+			logs:      5,
+			size:      2,
+			wantPages: [][]string{{"log-0", "log-1"}, {"log-2", "log-3"}, {"log-4"}},
+			// The first page is read again from the point in time, which the last page closes
+			wantRequests: []string{"search", "open", "search pit", "search pit", "search pit", "close"},
+		},
+		{
+			name: "legacy mapping",
+			logs: 3,
+			size: 2,
+			// Dynamic mapping made the id of the legacy logs index a text field
+			mapping:      map[string]string{"id": "text", "timestamp": "date"},
+			wantPages:    [][]string{{"log-0", "log-1"}, {"log-2"}},
+			wantRequests: []string{"search", "open", "search pit", "search pit", "close"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeLogSearch{t: t, n: tt.logs, mapping: tt.mapping}
+			c := newTestClient(t, f.handle)
+
+			var pages [][]string
+			q := LogQuery{Size: tt.size}
+			for {
+				page, err := c.PageLogs(context.Background(), q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if page.Total != tt.logs {
+					t.Errorf("total = %d, want %d", page.Total, tt.logs)
+				}
+				var ids []string
+				for _, l := range page.Logs {
+					ids = append(ids, l.ID)
+				}
+				pages = append(pages, ids)
+				if page.NextCursor == "" || len(pages) > len(tt.wantPages) {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+
+			if !reflect.DeepEqual(pages, tt.wantPages) {
+				t.Errorf("pages = %q, want %q", pages, tt.wantPages)
+			}
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if !reflect.DeepEqual(f.requests, tt.wantRequests) {
+				t.Errorf("requests = %q, want %q", f.requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestPageVolumeAnomalies(t *testing.T) {
+	var bodies []map[string]interface{}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/volume_anomalies/_search" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		bodies = append(bodies, body)
+		if body["search_after"] == nil {
+			w.Write([]byte(`{"hits": {"total": {"value": 3}, "hits": [
+				{"_source": {"id": "v3", "kind": "spike"}, "sort": [1718000000300, "v3"]},
+				{"_source": {"id": "v2", "kind": "spike"}, "sort": [1718000000200, "v2"]},
+				{"_source": {"id": "v1", "kind": "spike"}, "sort": [1718000000100, "v1"]}]}}`))
+			return
+		}
+		w.Write([]byte(`{"hits": {"total": {"value": 3}, "hits": [
+			{"_source": {"id": "v1", "kind": "spike"}, "sort": [1718000000100, "v1"]}]}}`))
+	})
+
+	page, err := c.PageVolumeAnomalies(context.Background(), VolumeAnomalyQuery{Kind: "spike", Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Anomalies) != 2 || page.Total != 3 || page.NextCursor == "" {
+		t.Fatalf("first page: %d anomalies of %d, cursor %q", len(page.Anomalies), page.Total, page.NextCursor)
+	}
+
+	// The cursor carries the filters of the first page
+	page, err = c.PageVolumeAnomalies(context.Background(), VolumeAnomalyQuery{Size: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Anomalies) != 1 || page.Anomalies[0].ID != "v1" || page.Total != 3 || page.NextCursor != "" {
+		t.Errorf("last page: %+v", page)
+	}
+	if after := bodies[1]["search_after"]; !reflect.DeepEqual(after, []interface{}{1718000000200.0, "v2"}) {
+		t.Errorf("search_after = %v, want the sort values of v2", after)
+	}
+	if q, _ := json.Marshal(bodies[1]["query"]); !strings.Contains(string(q), `"kind":"spike"`) {
+		t.Errorf("query of the second page %s lost the kind filter", q)
+	}
+
+	if _, err := c.PageVolumeAnomalies(context.Background(), VolumeAnomalyQuery{Size: 2, Cursor: "bad"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("bad cursor: err = %v, want ErrInvalidCursor", err)
+	}
+}
@@ -0,0 +1,179 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// FeedbackIndex stores analyst verdicts on stored logs, one document per log
+const FeedbackIndex = "feedback"
+
+// FeedbackDocument is the latest analyst verdict on a log, together with the model
+// output it judges so it can be used as a training example
+type FeedbackDocument struct {
+	LogID      string           `json:"log_id"`
+	Verdict    string           `json:"verdict"`
+	Comment    string           `json:"comment,omitempty"`
+	User       string           `json:"user,omitempty"`
+	Timestamp  time.Time        `json:"timestamp"`
+	LogText    string           `json:"log_text"`
+	ModelLabel string           `json:"model_label,omitempty"`
+	Score      float64          `json:"score"`
+	IsAnomaly  bool             `json:"is_anomaly"`
+	Detector   string           `json:"detector,omitempty"`
+	Detections []DetectorResult `json:"detections,omitempty"`
+}
+
+// GetLog retrieves a stored log by ID; it returns nil when the log does not exist
+func (c *Client) GetLog(ctx context.Context, id string) (*LogDocument, error) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"ids": map[string]interface{}{
+				"values": []string{id},
+			},
+		},
+		"size": 1,
+	}
+
+	logs, err := c.SearchLogs(ctx, query)
+	if err != nil || len(logs) == 0 {
+		return nil, err
+	}
+	return &logs[0], nil
+}
+
+// SaveFeedback stores a verdict, replacing any earlier verdict on the same log
+func (c *Client) SaveFeedback(ctx context.Context, doc *FeedbackDocument) error {
+	return c.indexDocument(ctx, c.index(FeedbackIndex), doc.LogID, doc)
+}
+
+// GetFeedback retrieves the verdict on a log; it returns nil when there is none
+func (c *Client) GetFeedback(ctx context.Context, logID string) (*FeedbackDocument, error) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"ids": map[string]interface{}{
+				"values": []string{logID},
+			},
+		},
+		"size": 1,
+	}
+
+	var searchResponse feedbackSearchResponse
+	if err := c.search(ctx, c.index(FeedbackIndex), query, &searchResponse); err != nil {
+		return nil, err
+	}
+	if len(searchResponse.Hits.Hits) == 0 {
+		return nil, nil
+	}
+	return &searchResponse.Hits.Hits[0].Source, nil
+}
+
+// SetHumanLabel records the analyst label on a stored log and makes it the log's effective label.
+// The log is updated by query since the generation of the series holding it is not known.
+func (c *Client) SetHumanLabel(ctx context.Context, logID, label string, at time.Time) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"ids": map[string]interface{}{
+				"values": []string{logID},
+			},
+		},
+		"script": map[string]interface{}{
+			"lang":   "painless",
+			"source": "ctx._source.human_label = params.label; ctx._source.effective_label = params.label; ctx._source.feedback_at = params.at",
+			"params": map[string]interface{}{
+				"label": label,
+				"at":    at,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal update: %w", err)
+	}
+
+	refresh := true
+	var lastErr error
+	for i := 0; i < 3; i++ {
+		req := esapi.UpdateByQueryRequest{
+			Index:             c.logIndices(),
+			Body:              bytes.NewReader(body),
+			Refresh:           &refresh,
+			IgnoreUnavailable: esapi.BoolPtr(true),
+		}
+		res, err := req.Do(ctx, c.es)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to update log: %w", err)
+		} else {
+			var result struct {
+				Updated int `json:"updated"`
+			}
+			if res.IsError() {
+				lastErr = fmt.Errorf("Elasticsearch update error: %s", res.String())
+			} else if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+				lastErr = fmt.Errorf("failed to decode update response: %w", err)
+			} else {
+				res.Body.Close()
+				if result.Updated == 0 {
+					return fmt.Errorf("log %s not found", logID)
+				}
+				return nil
+			}
+			res.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(200*(1<<i)) * time.Millisecond):
+		}
+	}
+	return lastErr
+}
+
+// ScanFeedback calls fn for every stored verdict, oldest first, paging with search_after
+func (c *Client) ScanFeedback(ctx context.Context, fn func(FeedbackDocument) error) error {
+	var after []interface{}
+	for {
+		query := map[string]interface{}{
+			"query": map[string]interface{}{
+				"match_all": map[string]interface{}{},
+			},
+			"sort": []map[string]interface{}{
+				{"timestamp": map[string]interface{}{"order": "asc"}},
+				{"log_id": map[string]interface{}{"order": "asc"}},
+			},
+			"size": 500,
+		}
+		if after != nil {
+			query["search_after"] = after
+		}
+
+		var searchResponse feedbackSearchResponse
+		if err := c.search(ctx, c.index(FeedbackIndex), query, &searchResponse); err != nil {
+			return err
+		}
+
+		hits := searchResponse.Hits.Hits
+		for _, hit := range hits {
+			if err := fn(hit.Source); err != nil {
+				return err
+			}
+		}
+		if len(hits) < 500 {
+			return nil
+		}
+		after = hits[len(hits)-1].Sort
+	}
+}
+
+type feedbackSearchResponse struct {
+	Hits struct {
+		Hits []struct {
+			Source FeedbackDocument `json:"_source"`
+			Sort   []interface{}    `json:"sort"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
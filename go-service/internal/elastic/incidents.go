@@ -0,0 +1,235 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// IncidentsIndex stores incidents grouping related anomalies
+const IncidentsIndex = "incidents"
+
+// IncidentDocument represents an incident stored in Elasticsearch
+type IncidentDocument struct {
+	ID             string            `json:"id"`
+	Title          string            `json:"title"`
+	Status         string            `json:"status"`
+	Source         string            `json:"source"`
+	SourceKeys     map[string]string `json:"source_keys,omitempty"`
+	TemplateIDs    []string          `json:"template_ids,omitempty"`
+	Assignee       string            `json:"assignee,omitempty"`
+	AnomalyCount   int64             `json:"anomaly_count"`
+	FirstAnomalyID string            `json:"first_anomaly_id"`
+	LastAnomalyID  string            `json:"last_anomaly_id"`
+	FirstAnomalyAt time.Time         `json:"first_anomaly_at"`
+	LastAnomalyAt  time.Time         `json:"last_anomaly_at"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	AcknowledgedAt *time.Time        `json:"acknowledged_at,omitempty"`
+	ResolvedAt     *time.Time        `json:"resolved_at,omitempty"`
+	Timeline       []IncidentEvent   `json:"timeline"`
+}
+
+// IncidentEvent is an entry of an incident's timeline
+type IncidentEvent struct {
+	At      time.Time `json:"at"`
+	Type    string    `json:"type"`
+	User    string    `json:"user,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// SaveIncidents upserts incidents, keyed by incident ID
+func (c *Client) SaveIncidents(ctx context.Context, incidents []IncidentDocument) error {
+	if len(incidents) == 0 {
+		return nil
+	}
+
+	var bulkBody strings.Builder
+	for _, inc := range incidents {
+		indexAction := map[string]interface{}{
+			"index": map[string]interface{}{
+				"_index": c.index(IncidentsIndex),
+				"_id":    inc.ID,
+			},
+		}
+
+		indexBytes, _ := json.Marshal(indexAction)
+		docBytes, err := json.Marshal(inc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal incident: %w", err)
+		}
+
+		bulkBody.Write(indexBytes)
+		bulkBody.WriteString("\n")
+		bulkBody.Write(docBytes)
+		bulkBody.WriteString("\n")
+	}
+
+	req := esapi.BulkRequest{
+		Index:   c.index(IncidentsIndex),
+		Body:    strings.NewReader(bulkBody.String()),
+		Refresh: "true",
+	}
+
+	var lastErr error
+	for i := 0; i < 3; i++ {
+		res, err := req.Do(ctx, c.es)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to save incidents: %w", err)
+		} else {
+			defer res.Body.Close()
+			if res.IsError() {
+				lastErr = fmt.Errorf("Elasticsearch bulk error: %s", res.String())
+			} else {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(200*(1<<i)) * time.Millisecond):
+		}
+	}
+	return lastErr
+}
+
+// GetIncident retrieves an incident by ID; it returns nil when the incident does not exist
+func (c *Client) GetIncident(ctx context.Context, id string) (*IncidentDocument, error) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"ids": map[string]interface{}{
+				"values": []string{id},
+			},
+		},
+		"size": 1,
+	}
+
+	incidents, err := c.searchIncidents(ctx, query)
+	if err != nil || len(incidents) == 0 {
+		return nil, err
+	}
+	return &incidents[0], nil
+}
+
+// IncidentQuery selects incidents; an empty status selects all of them
+type IncidentQuery struct {
+	Status string
+
+	From, Size int
+
+	// Cursor continues from the NextCursor of an earlier page; it carries that page's query,
+	// so only Size applies besides it
+	Cursor string `json:"-"`
+}
+
+// IncidentPage is one page of the incidents matching an IncidentQuery
+type IncidentPage struct {
+	Incidents []IncidentDocument
+	Total     int // all the incidents matching the query, not only those on the page
+
+	// NextCursor continues after the page; it is empty on the last page
+	NextCursor string
+}
+
+// incidentsSort orders incidents most recently active first, their ID breaking ties
+var incidentsSort = []map[string]interface{}{
+	{"last_anomaly_at": map[string]interface{}{"order": "desc"}},
+	{"id": map[string]interface{}{"order": "desc"}},
+}
+
+// ListIncidents retrieves a page of the incidents matching q, most recently active first,
+// with their total count
+func (c *Client) ListIncidents(ctx context.Context, q IncidentQuery) (*IncidentPage, error) {
+	cur := IncidentCursor{Query: q}
+	if q.Cursor != "" {
+		var err error
+		if cur, err = DecodeIncidentCursor(q.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	filters := []map[string]interface{}{}
+	if cur.Query.Status != "" {
+		filters = append(filters, map[string]interface{}{
+			"term": map[string]interface{}{
+				"status": cur.Query.Status,
+			},
+		})
+	}
+	query := map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": filters,
+		},
+	}
+
+	page := &IncidentPage{}
+	total, last, err := c.searchPage(ctx, c.index(IncidentsIndex), query, incidentsSort, cur.After, q.From, q.Size, &page.Incidents)
+	if err != nil {
+		return nil, err
+	}
+	page.Total = total
+	if last != nil {
+		cur.After = last
+		if page.NextCursor, err = EncodeIncidentCursor(cur); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// GetActiveIncidents retrieves the incidents that are not resolved and had an anomaly since the given time
+func (c *Client) GetActiveIncidents(ctx context.Context, since time.Time) ([]IncidentDocument, error) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must_not": []map[string]interface{}{
+					{
+						"term": map[string]interface{}{
+							"status": "resolved",
+						},
+					},
+				},
+				"filter": []map[string]interface{}{
+					{
+						"range": map[string]interface{}{
+							"last_anomaly_at": map[string]interface{}{
+								"gte": since.Format(time.RFC3339),
+							},
+						},
+					},
+				},
+			},
+		},
+		"size": 1000,
+	}
+
+	return c.searchIncidents(ctx, query)
+}
+
+// GetLogsByIncident retrieves the anomalies grouped into an incident
+func (c *Client) GetLogsByIncident(ctx context.Context, incidentID string, from, size int) ([]LogDocument, error) {
+	return c.FindLogs(ctx, LogQuery{IncidentID: incidentID, From: from, Size: size})
+}
+
+func (c *Client) searchIncidents(ctx context.Context, query map[string]interface{}) ([]IncidentDocument, error) {
+	var searchResponse struct {
+		Hits struct {
+			Hits []struct {
+				Source IncidentDocument `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := c.search(ctx, c.index(IncidentsIndex), query, &searchResponse); err != nil {
+		return nil, err
+	}
+
+	incidents := make([]IncidentDocument, len(searchResponse.Hits.Hits))
+	for i, hit := range searchResponse.Hits.Hits {
+		incidents[i] = hit.Source
+	}
+	return incidents, nil
+}
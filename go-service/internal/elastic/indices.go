@@ -0,0 +1,298 @@
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// Logs are written to two series of indices, one for normal logs and one for anomalies, so
+// that each can be kept for its own time. A series is a sequence of generations, e.g.
+// logs-000001, logs-000002, of which the newest receives the writes through the write alias;
+// a rollover starts the next generation and old ones are eventually deleted as a whole.
+const (
+	LogsSeries      = "logs"
+	AnomaliesSeries = "anomalies"
+)
+
+// legacyLogsIndex is the single index earlier versions wrote every log to. It is still
+// searched, so that its logs stay visible until they are reindexed into the series or
+// deleted; searches skip it when it does not exist.
+const legacyLogsIndex = "logs"
+
+// SetIndexPrefix puts prefix in front of the names of all the indices, aliases and templates
+// of the client, so that several environments can share a cluster. It must be called
+// before the client is used.
+func (c *Client) SetIndexPrefix(prefix string) {
+	c.prefix = prefix
+}
+
+// index returns the name of an index with the prefix of the client
+func (c *Client) index(name string) string {
+	if c.prefix == "" {
+		return name
+	}
+	return c.prefix + "-" + name
+}
+
+// writeAlias returns the alias pointing at the generation of a series that receives writes
+func (c *Client) writeAlias(series string) string {
+	return c.index(series) + "-write"
+}
+
+// seriesPattern matches the generations of a series. The zero padded generation keeps the
+// pattern clear of the logs-*-* data streams of the built-in Elasticsearch templates.
+func (c *Client) seriesPattern(series string) string {
+	return c.index(series) + "-0*"
+}
+
+// seriesPatterns returns the patterns matching the generations of both log series
+func (c *Client) seriesPatterns() []string {
+	return []string{c.seriesPattern(LogsSeries), c.seriesPattern(AnomaliesSeries)}
+}
+
+// logIndices returns the indices and patterns the log queries search: both series and
+// the legacy index, so requests over them must ignore unavailable indices. Log IDs are only
+// unique within an index: a log indexed again after a rollover, or classified differently
+// on a second pass, is found in two indices, and queries return both copies.
+func (c *Client) logIndices() []string {
+	return append(c.seriesPatterns(), c.index(legacyLogsIndex))
+}
+
+// LogIndex returns the alias a log is written to, which depends on whether it is an anomaly
+func (c *Client) LogIndex(doc *LogDocument) string {
+	if doc.IsAnomaly {
+		return c.writeAlias(AnomaliesSeries)
+	}
+	return c.writeAlias(LogsSeries)
+}
+
+// perform sends the request built by newReq with the usual retry policy, retrying transport
+// errors and the statuses worth another attempt; the caller closes the body of the response
+func (c *Client) perform(ctx context.Context, newReq func() esapi.Request) (*esapi.Response, error) {
+	var lastErr error
+	for i := 0; i < 3; i++ {
+		res, err := newReq().Do(ctx, c.es)
+		if err != nil {
+			lastErr = err
+		} else if res.StatusCode == 429 || res.StatusCode >= 500 {
+			lastErr = fmt.Errorf("Elasticsearch error: %s", res.String())
+			res.Body.Close()
+		} else {
+			return res, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(200*(1<<i)) * time.Millisecond):
+		}
+	}
+	return nil, lastErr
+}
+
+// putLogsTemplate installs the index template giving every generation of the log series
+// the logs mapping
+func (c *Client) putLogsTemplate(ctx context.Context) error {
+	var mapping struct {
+		Mappings json.RawMessage `json:"mappings"`
+	}
+	if err := json.Unmarshal([]byte(logsMapping), &mapping); err != nil {
+		return fmt.Errorf("invalid logs mapping: %w", err)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"index_patterns": c.seriesPatterns(),
+		// Above the priority of the built-in templates, which may overlap
+		"priority": 200,
+		"template": map[string]interface{}{
+			"mappings": mapping.Mappings,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal index template: %w", err)
+	}
+
+	res, err := c.perform(ctx, func() esapi.Request {
+		return esapi.IndicesPutIndexTemplateRequest{
+			Name: c.index(LogsSeries),
+			Body: bytes.NewReader(body),
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put index template: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to put index template: %s", res.String())
+	}
+	return nil
+}
+
+// bootstrapSeries creates the first generation of a series with its write alias, unless
+// the alias already exists
+func (c *Client) bootstrapSeries(ctx context.Context, series string) error {
+	alias := c.writeAlias(series)
+	res, err := c.perform(ctx, func() esapi.Request {
+		return esapi.IndicesExistsAliasRequest{Name: []string{alias}}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to look up alias %s: %w", alias, err)
+	}
+	res.Body.Close()
+	switch {
+	case res.StatusCode == 404:
+	case res.IsError():
+		return fmt.Errorf("failed to look up alias %s: %s", alias, res.String())
+	default:
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"aliases": map[string]interface{}{
+			alias: map[string]interface{}{"is_write_index": true},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal aliases: %w", err)
+	}
+	return c.createIndex(ctx, c.index(series)+"-000001", string(body))
+}
+
+// Rollover starts a new generation of a series once its write index is older than maxAge
+// or, when maxSize is set, once a primary shard has grown to maxSize (e.g. 50gb)
+func (c *Client) Rollover(ctx context.Context, series string, maxAge time.Duration, maxSize string) error {
+	conditions := map[string]interface{}{
+		"max_age": fmt.Sprintf("%ds", int64(maxAge.Seconds())),
+	}
+	if maxSize != "" {
+		conditions["max_primary_shard_size"] = maxSize
+	}
+	body, err := json.Marshal(map[string]interface{}{"conditions": conditions})
+	if err != nil {
+		return fmt.Errorf("failed to marshal rollover conditions: %w", err)
+	}
+
+	res, err := c.perform(ctx, func() esapi.Request {
+		return esapi.IndicesRolloverRequest{
+			Alias: c.writeAlias(series),
+			Body:  bytes.NewReader(body),
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to roll over %s: %w", series, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to roll over %s: %s", series, res.String())
+	}
+
+	var result struct {
+		RolledOver bool   `json:"rolled_over"`
+		OldIndex   string `json:"old_index"`
+		NewIndex   string `json:"new_index"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode rollover response: %w", err)
+	}
+	if result.RolledOver {
+		metrics.ESIndexLifecycleTotal.WithLabelValues("rollover").Inc()
+		log.Printf("Rolled %s over to %s", result.OldIndex, result.NewIndex)
+	}
+	return nil
+}
+
+// SeriesIndex is one generation of a series of log indices
+type SeriesIndex struct {
+	Name      string
+	CreatedAt time.Time
+
+	// RolledOverAt is when the next generation was created, zero for the write index
+	RolledOverAt time.Time
+}
+
+// SeriesIndices lists the generations of a series, oldest first
+func (c *Client) SeriesIndices(ctx context.Context, series string) ([]SeriesIndex, error) {
+	res, err := c.perform(ctx, func() esapi.Request {
+		return esapi.CatIndicesRequest{
+			Index:  []string{c.seriesPattern(series)},
+			Format: "json",
+			H:      []string{"index", "creation.date"},
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s indices: %w", series, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return nil, fmt.Errorf("failed to list %s indices: %s", series, res.String())
+	}
+
+	var rows []struct {
+		Index        string `json:"index"`
+		CreationDate string `json:"creation.date"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("failed to decode index list: %w", err)
+	}
+
+	indices := make([]SeriesIndex, 0, len(rows))
+	for _, row := range rows {
+		ms, err := strconv.ParseInt(row.CreationDate, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid creation date of %s: %q", row.Index, row.CreationDate)
+		}
+		indices = append(indices, SeriesIndex{Name: row.Index, CreatedAt: time.UnixMilli(ms).UTC()})
+	}
+	// Generations are zero padded, so the names sort in creation order
+	sort.Slice(indices, func(i, j int) bool {
+		return indices[i].Name < indices[j].Name
+	})
+	for i := 0; i+1 < len(indices); i++ {
+		indices[i].RolledOverAt = indices[i+1].CreatedAt
+	}
+	return indices, nil
+}
+
+// ForceMerge merges an index that no longer receives writes down to a single segment
+func (c *Client) ForceMerge(ctx context.Context, index string) error {
+	segments := 1
+	res, err := c.perform(ctx, func() esapi.Request {
+		return esapi.IndicesForcemergeRequest{
+			Index:          []string{index},
+			MaxNumSegments: &segments,
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to force-merge %s: %w", index, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to force-merge %s: %s", index, res.String())
+	}
+	metrics.ESIndexLifecycleTotal.WithLabelValues("forcemerge").Inc()
+	return nil
+}
+
+// DeleteIndex deletes an index
+func (c *Client) DeleteIndex(ctx context.Context, index string) error {
+	res, err := c.perform(ctx, func() esapi.Request {
+		return esapi.IndicesDeleteRequest{Index: []string{index}}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", index, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != 404 {
+		return fmt.Errorf("failed to delete %s: %s", index, res.String())
+	}
+	metrics.ESIndexLifecycleTotal.WithLabelValues("delete").Inc()
+	return nil
+}
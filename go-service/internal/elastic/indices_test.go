@@ -0,0 +1,109 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLogQueriesSearchLegacyIndex(t *testing.T) {
+	for _, prefix := range []string{"", "staging"} {
+		t.Run("prefix "+prefix, func(t *testing.T) {
+			var path, ignore string
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				path, ignore = r.URL.Path, r.URL.Query().Get("ignore_unavailable")
+				w.Write([]byte(`{"hits": {"hits": []}}`))
+			})
+			c.SetIndexPrefix(prefix)
+
+			if _, err := c.GetLog(context.Background(), "log-1"); err != nil {
+				t.Fatal(err)
+			}
+			want := "/logs-0*,anomalies-0*,logs/_search"
+			if prefix != "" {
+				want = "/staging-logs-0*,staging-anomalies-0*,staging-logs/_search"
+			}
+			if path != want {
+				t.Errorf("searched %s, want %s", path, want)
+			}
+			// The legacy index does not exist in new deployments
+			if ignore != "true" {
+				t.Errorf("ignore_unavailable = %q, want true", ignore)
+			}
+		})
+	}
+}
+
+func TestLogsTemplateLeavesLegacyIndexOut(t *testing.T) {
+	var patterns []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/_index_template/") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var body struct {
+			IndexPatterns []string `json:"index_patterns"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		patterns = body.IndexPatterns
+		w.Write([]byte(`{"acknowledged": true}`))
+	})
+
+	if err := c.putLogsTemplate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"logs-0*", "anomalies-0*"}; !reflect.DeepEqual(patterns, want) {
+		t.Errorf("template patterns = %q, want %q", patterns, want)
+	}
+}
+
+func TestPagingCoversLegacyIndex(t *testing.T) {
+	// The legacy logs index maps id as text, which the fake rejects sorting on
+	f := &fakeLogSearch{t: t, n: 5, mapping: map[string]string{"id": "text", "timestamp": "date"}}
+	c := newTestClient(t, f.handle)
+
+	logs, err := c.FindLogs(context.Background(), LogQuery{From: 3, Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 || logs[0].ID != "log-3" {
+		t.Errorf("FindLogs returned %d logs starting at %q, want log-3 and log-4", len(logs), logs[0].ID)
+	}
+
+	var ids []string
+	q := LogQuery{Size: 2}
+	for i := 0; i < 5; i++ {
+		page, err := c.PageLogs(context.Background(), q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range page.Logs {
+			ids = append(ids, l.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	if want := []string{"log-0", "log-1", "log-2", "log-3", "log-4"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("paged %q, want %q", ids, want)
+	}
+
+	// The searches without a point in time and the point in time span the series and the legacy index
+	want := []string{
+		"POST /logs-0*,anomalies-0*,logs/_search",
+		"POST /logs-0*,anomalies-0*,logs/_search",
+		"POST /logs-0*,anomalies-0*,logs/_pit",
+		"POST /_search", "POST /_search", "POST /_search",
+		"DELETE /_pit",
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !reflect.DeepEqual(f.paths, want) {
+		t.Errorf("requests = %q, want %q", f.paths, want)
+	}
+}
@@ -0,0 +1,381 @@
+package elastic
+
+// Index mappings, kept in one place so the stored documents and their mappings are easy to compare
+
+const logsMapping = `{
+	"mappings": {
+		"properties": {
+			"id": {
+				"type": "keyword"
+			},
+			"timestamp": {
+				"type": "date"
+			},
+			"batch_id": {
+				"type": "keyword"
+			},
+			"log_text": {
+				"type": "text",
+				"analyzer": "standard"
+			},
+			"original_text": {
+				"type": "text",
+				"analyzer": "standard"
+			},
+			"is_anomaly": {
+				"type": "boolean"
+			},
+			"label": {
+				"type": "keyword"
+			},
+			"score": {
+				"type": "float"
+			},
+			"detector": {
+				"type": "keyword"
+			},
+			"reason": {
+				"type": "text"
+			},
+			"detections": {
+				"properties": {
+					"detector": {
+						"type": "keyword"
+					},
+					"label": {
+						"type": "keyword"
+					},
+					"score": {
+						"type": "float"
+					},
+					"is_anomaly": {
+						"type": "boolean"
+					},
+					"reason": {
+						"type": "text"
+					}
+				}
+			},
+			"content_type": {
+				"type": "keyword"
+			},
+			"template_id": {
+				"type": "keyword"
+			},
+			"template_params": {
+				"type": "keyword",
+				"ignore_above": 256
+			},
+			"incident_id": {
+				"type": "keyword"
+			},
+			"effective_label": {
+				"type": "keyword"
+			},
+			"human_label": {
+				"type": "keyword"
+			},
+			"feedback_at": {
+				"type": "date"
+			},
+			"suppressed": {
+				"type": "boolean"
+			},
+			"suppression_id": {
+				"type": "keyword"
+			},
+			"metadata": {
+				"type": "flattened"
+			},
+			"received_at": {
+				"type": "date"
+			},
+			"log_format": {
+				"type": "keyword"
+			},
+			"level": {
+				"type": "keyword"
+			},
+			"logger": {
+				"type": "keyword"
+			},
+			"fields": {
+				"type": "flattened"
+			},
+			"trace_id": {
+				"type": "keyword"
+			},
+			"span_id": {
+				"type": "keyword"
+			}
+		}
+	}
+}`
+
+const templatesMapping = `{
+	"mappings": {
+		"properties": {
+			"template_id": {
+				"type": "keyword"
+			},
+			"template": {
+				"type": "text",
+				"fields": {
+					"raw": {
+						"type": "keyword",
+						"ignore_above": 2048
+					}
+				}
+			},
+			"tokens": {
+				"type": "integer"
+			},
+			"count": {
+				"type": "long"
+			},
+			"first_seen": {
+				"type": "date"
+			},
+			"last_seen": {
+				"type": "date"
+			}
+		}
+	}
+}`
+
+const volumeAnomaliesMapping = `{
+	"mappings": {
+		"properties": {
+			"id": {
+				"type": "keyword"
+			},
+			"timestamp": {
+				"type": "date"
+			},
+			"source": {
+				"type": "keyword"
+			},
+			"source_keys": {
+				"type": "flattened"
+			},
+			"kind": {
+				"type": "keyword"
+			},
+			"method": {
+				"type": "keyword"
+			},
+			"window_start": {
+				"type": "date"
+			},
+			"window_end": {
+				"type": "date"
+			},
+			"count": {
+				"type": "long"
+			},
+			"expected": {
+				"type": "float"
+			},
+			"score": {
+				"type": "float"
+			}
+		}
+	}
+}`
+
+const incidentsMapping = `{
+	"mappings": {
+		"properties": {
+			"id": {
+				"type": "keyword"
+			},
+			"title": {
+				"type": "text"
+			},
+			"status": {
+				"type": "keyword"
+			},
+			"source": {
+				"type": "keyword"
+			},
+			"source_keys": {
+				"type": "flattened"
+			},
+			"template_ids": {
+				"type": "keyword"
+			},
+			"assignee": {
+				"type": "keyword"
+			},
+			"anomaly_count": {
+				"type": "long"
+			},
+			"first_anomaly_id": {
+				"type": "keyword"
+			},
+			"last_anomaly_id": {
+				"type": "keyword"
+			},
+			"first_anomaly_at": {
+				"type": "date"
+			},
+			"last_anomaly_at": {
+				"type": "date"
+			},
+			"created_at": {
+				"type": "date"
+			},
+			"updated_at": {
+				"type": "date"
+			},
+			"acknowledged_at": {
+				"type": "date"
+			},
+			"resolved_at": {
+				"type": "date"
+			},
+			"timeline": {
+				"properties": {
+					"at": {
+						"type": "date"
+					},
+					"type": {
+						"type": "keyword"
+					},
+					"user": {
+						"type": "keyword"
+					},
+					"message": {
+						"type": "text"
+					}
+				}
+			}
+		}
+	}
+}`
+
+const feedbackMapping = `{
+	"mappings": {
+		"properties": {
+			"log_id": {
+				"type": "keyword"
+			},
+			"verdict": {
+				"type": "keyword"
+			},
+			"comment": {
+				"type": "text"
+			},
+			"user": {
+				"type": "keyword"
+			},
+			"timestamp": {
+				"type": "date"
+			},
+			"log_text": {
+				"type": "text"
+			},
+			"model_label": {
+				"type": "keyword"
+			},
+			"score": {
+				"type": "float"
+			},
+			"is_anomaly": {
+				"type": "boolean"
+			},
+			"detector": {
+				"type": "keyword"
+			},
+			"detections": {
+				"properties": {
+					"detector": {
+						"type": "keyword"
+					},
+					"label": {
+						"type": "keyword"
+					},
+					"score": {
+						"type": "float"
+					},
+					"is_anomaly": {
+						"type": "boolean"
+					},
+					"reason": {
+						"type": "text"
+					}
+				}
+			}
+		}
+	}
+}`
+
+const suppressionsMapping = `{
+	"mappings": {
+		"properties": {
+			"id": {
+				"type": "keyword"
+			},
+			"name": {
+				"type": "text"
+			},
+			"pattern": {
+				"type": "keyword",
+				"index": false
+			},
+			"template_id": {
+				"type": "keyword"
+			},
+			"match": {
+				"type": "flattened"
+			},
+			"expires_at": {
+				"type": "date"
+			},
+			"comment": {
+				"type": "text"
+			},
+			"created_by": {
+				"type": "keyword"
+			},
+			"created_at": {
+				"type": "date"
+			},
+			"updated_at": {
+				"type": "date"
+			}
+		}
+	}
+}`
+
+const deadLettersMapping = `{
+	"mappings": {
+		"properties": {
+			"index": {
+				"type": "keyword"
+			},
+			"doc_id": {
+				"type": "keyword"
+			},
+			"document": {
+				"type": "object",
+				"enabled": false
+			},
+			"status": {
+				"type": "integer"
+			},
+			"error_type": {
+				"type": "keyword"
+			},
+			"reason": {
+				"type": "text"
+			},
+			"attempts": {
+				"type": "integer"
+			},
+			"timestamp": {
+				"type": "date"
+			}
+		}
+	}
+}`
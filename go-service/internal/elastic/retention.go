@@ -0,0 +1,89 @@
+package elastic
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// RetentionConfig sets the lifecycle of the log indices. Ages of old generations count from
+// their rollover, so a log is kept for at least the retention of its series and at most
+// that plus RolloverMaxAge.
+type RetentionConfig struct {
+	RolloverMaxAge  time.Duration // start a new generation once the write index is this old
+	RolloverMaxSize string        // or once a primary shard reaches this size, e.g. 50gb; empty to ignore
+	ForceMergeAfter time.Duration // merge generations to one segment this long after their rollover; 0 never does
+
+	// Generations are deleted this long after their rollover; 0 keeps them forever
+	LogsRetention      time.Duration
+	AnomaliesRetention time.Duration
+}
+
+// RetentionManager applies the retention policy to the log series, ILM style: it rolls the
+// write indices over, force-merges the generations that no longer receive writes and
+// deletes the expired ones
+type RetentionManager struct {
+	client *Client
+	cfg    RetentionConfig
+	merged map[string]bool // generations force-merged since startup
+}
+
+// NewRetentionManager creates a retention manager; call Apply periodically
+func NewRetentionManager(client *Client, cfg RetentionConfig) *RetentionManager {
+	if cfg.RolloverMaxAge <= 0 {
+		cfg.RolloverMaxAge = 24 * time.Hour
+	}
+	return &RetentionManager{
+		client: client,
+		cfg:    cfg,
+		merged: make(map[string]bool),
+	}
+}
+
+// Apply runs one pass of the policy over both series. It does nothing while Elasticsearch
+// is unreachable.
+func (m *RetentionManager) Apply(ctx context.Context) error {
+	if !m.client.Healthy() {
+		return nil
+	}
+	return errors.Join(
+		m.apply(ctx, LogsSeries, m.cfg.LogsRetention),
+		m.apply(ctx, AnomaliesSeries, m.cfg.AnomaliesRetention),
+	)
+}
+
+func (m *RetentionManager) apply(ctx context.Context, series string, retention time.Duration) error {
+	if err := m.client.Rollover(ctx, series, m.cfg.RolloverMaxAge, m.cfg.RolloverMaxSize); err != nil {
+		return err
+	}
+	indices, err := m.client.SeriesIndices(ctx, series)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var errs []error
+	for _, idx := range indices {
+		if idx.RolledOverAt.IsZero() {
+			continue // the write index
+		}
+		age := now.Sub(idx.RolledOverAt)
+		switch {
+		case retention > 0 && age > retention:
+			if err := m.client.DeleteIndex(ctx, idx.Name); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			delete(m.merged, idx.Name)
+			log.Printf("Deleted index %s, rolled over %s ago", idx.Name, age.Round(time.Minute))
+		case m.cfg.ForceMergeAfter > 0 && age > m.cfg.ForceMergeAfter && !m.merged[idx.Name]:
+			if err := m.client.ForceMerge(ctx, idx.Name); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			m.merged[idx.Name] = true
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,88 @@
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// SuppressionsIndex stores the suppression rules managed through the API
+const SuppressionsIndex = "suppressions"
+
+// SuppressionRule silences anomalies that are known to be noise. Every condition that
+// is set must hold for a log to match.
+type SuppressionRule struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name,omitempty"`
+	Pattern    string            `json:"pattern,omitempty"`     // regular expression on the cleaned text
+	TemplateID string            `json:"template_id,omitempty"` // Drain template of the log
+	Match      map[string]string `json:"match,omitempty"`       // metadata values the log must carry
+	ExpiresAt  *time.Time        `json:"expires_at,omitempty"`
+	Comment    string            `json:"comment,omitempty"`
+	CreatedBy  string            `json:"created_by,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// SaveSuppression creates or replaces a suppression rule
+func (c *Client) SaveSuppression(ctx context.Context, rule *SuppressionRule) error {
+	return c.indexDocument(ctx, c.index(SuppressionsIndex), rule.ID, rule)
+}
+
+// DeleteSuppression removes a suppression rule; deleting a missing rule is not an error
+func (c *Client) DeleteSuppression(ctx context.Context, id string) error {
+	var lastErr error
+	for i := 0; i < 3; i++ {
+		req := esapi.DeleteRequest{
+			Index:      c.index(SuppressionsIndex),
+			DocumentID: id,
+			Refresh:    "true",
+		}
+		res, err := req.Do(ctx, c.es)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to delete suppression rule: %w", err)
+		} else {
+			res.Body.Close()
+			if !res.IsError() || res.StatusCode == http.StatusNotFound {
+				return nil
+			}
+			lastErr = fmt.Errorf("Elasticsearch delete error: %s", res.String())
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(200*(1<<i)) * time.Millisecond):
+		}
+	}
+	return lastErr
+}
+
+// GetSuppressions retrieves all suppression rules
+func (c *Client) GetSuppressions(ctx context.Context) ([]SuppressionRule, error) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"match_all": map[string]interface{}{},
+		},
+		"size": 10000,
+	}
+
+	var searchResponse struct {
+		Hits struct {
+			Hits []struct {
+				Source SuppressionRule `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := c.search(ctx, c.index(SuppressionsIndex), query, &searchResponse); err != nil {
+		return nil, err
+	}
+
+	rules := make([]SuppressionRule, len(searchResponse.Hits.Hits))
+	for i, hit := range searchResponse.Hits.Hits {
+		rules[i] = hit.Source
+	}
+	return rules, nil
+}
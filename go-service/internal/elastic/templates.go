@@ -0,0 +1,118 @@
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// TemplatesIndex stores the log templates mined from ingested logs
+const TemplatesIndex = "log_templates"
+
+// TemplateDocument represents a log template stored in Elasticsearch
+type TemplateDocument struct {
+	ID        string    `json:"template_id"`
+	Template  string    `json:"template"`
+	Tokens    int       `json:"tokens"`
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// SaveTemplates upserts templates, keyed by template ID
+func (c *Client) SaveTemplates(ctx context.Context, templates []TemplateDocument) error {
+	if len(templates) == 0 {
+		return nil
+	}
+
+	var bulkBody strings.Builder
+	for _, t := range templates {
+		indexAction := map[string]interface{}{
+			"index": map[string]interface{}{
+				"_index": c.index(TemplatesIndex),
+				"_id":    t.ID,
+			},
+		}
+
+		indexBytes, _ := json.Marshal(indexAction)
+		docBytes, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Errorf("failed to marshal template: %w", err)
+		}
+
+		bulkBody.Write(indexBytes)
+		bulkBody.WriteString("\n")
+		bulkBody.Write(docBytes)
+		bulkBody.WriteString("\n")
+	}
+
+	req := esapi.BulkRequest{
+		Index: c.index(TemplatesIndex),
+		Body:  strings.NewReader(bulkBody.String()),
+	}
+
+	var lastErr error
+	for i := 0; i < 3; i++ {
+		res, err := req.Do(ctx, c.es)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to save templates: %w", err)
+		} else {
+			defer res.Body.Close()
+			if res.IsError() {
+				lastErr = fmt.Errorf("Elasticsearch bulk error: %s", res.String())
+			} else {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(200*(1<<i)) * time.Millisecond):
+		}
+	}
+	return lastErr
+}
+
+// GetTemplates loads up to size templates, most frequent first
+func (c *Client) GetTemplates(ctx context.Context, size int) ([]TemplateDocument, error) {
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"match_all": map[string]interface{}{},
+		},
+		"sort": []map[string]interface{}{
+			{
+				"count": map[string]interface{}{
+					"order": "desc",
+				},
+			},
+		},
+		"size": size,
+	}
+
+	var searchResponse struct {
+		Hits struct {
+			Hits []struct {
+				Source TemplateDocument `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := c.search(ctx, c.index(TemplatesIndex), query, &searchResponse); err != nil {
+		return nil, err
+	}
+
+	templates := make([]TemplateDocument, len(searchResponse.Hits.Hits))
+	for i, hit := range searchResponse.Hits.Hits {
+		templates[i] = hit.Source
+	}
+	return templates, nil
+}
+
+// GetLogsByTemplate retrieves the logs assigned to a template, optionally only the anomalies.
+// Suppressed anomalies are only returned with includeSuppressed.
+func (c *Client) GetLogsByTemplate(ctx context.Context, templateID string, anomaliesOnly, includeSuppressed bool, from, size int) ([]LogDocument, error) {
+	return c.FindLogs(ctx, LogQuery{TemplateID: templateID, AnomaliesOnly: anomaliesOnly, IncludeSuppressed: includeSuppressed, From: from, Size: size})
+}
@@ -0,0 +1,123 @@
+package elastic
+
+import (
+	"context"
+	"time"
+)
+
+// VolumeAnomaliesIndex stores the log volume spikes and drops detected per source
+const VolumeAnomaliesIndex = "volume_anomalies"
+
+// VolumeAnomalyDocument represents a volume anomaly stored in Elasticsearch
+type VolumeAnomalyDocument struct {
+	ID          string            `json:"id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Source      string            `json:"source"`
+	SourceKeys  map[string]string `json:"source_keys,omitempty"`
+	Kind        string            `json:"kind"`
+	Method      string            `json:"method"`
+	WindowStart time.Time         `json:"window_start"`
+	WindowEnd   time.Time         `json:"window_end"`
+	Count       int64             `json:"count"`
+	Expected    float64           `json:"expected"`
+	Score       float64           `json:"score"`
+}
+
+// IndexVolumeAnomaly stores a volume anomaly
+func (c *Client) IndexVolumeAnomaly(ctx context.Context, doc *VolumeAnomalyDocument) error {
+	return c.indexDocument(ctx, c.index(VolumeAnomaliesIndex), doc.ID, doc)
+}
+
+// VolumeAnomalyQuery selects volume anomalies. Empty source or kind and zero times disable
+// the corresponding filter.
+type VolumeAnomalyQuery struct {
+	Source     string
+	Kind       string
+	Start, End time.Time
+
+	From, Size int
+
+	// Cursor continues from the NextCursor of an earlier page; it carries that page's query,
+	// so only Size applies besides it
+	Cursor string `json:"-"`
+}
+
+// VolumeAnomalyPage is one page of the volume anomalies matching a VolumeAnomalyQuery
+type VolumeAnomalyPage struct {
+	Anomalies []VolumeAnomalyDocument
+	Total     int // all the anomalies matching the query, not only those on the page
+
+	// NextCursor continues after the page; it is empty on the last page
+	NextCursor string
+}
+
+// volumeAnomaliesSort orders volume anomalies newest first, their ID breaking ties
+var volumeAnomaliesSort = []map[string]interface{}{
+	{"timestamp": map[string]interface{}{"order": "desc"}},
+	{"id": map[string]interface{}{"order": "desc"}},
+}
+
+// PageVolumeAnomalies retrieves a page of the volume anomalies matching q, newest first,
+// with their total count
+func (c *Client) PageVolumeAnomalies(ctx context.Context, q VolumeAnomalyQuery) (*VolumeAnomalyPage, error) {
+	cur := volumeCursor{Query: q}
+	if q.Cursor != "" {
+		if err := decodeCursor(q.Cursor, &cur); err != nil || len(cur.After) != len(volumeAnomaliesSort) {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	page := &VolumeAnomalyPage{}
+	total, last, err := c.searchPage(ctx, c.index(VolumeAnomaliesIndex), volumeAnomaliesQuery(cur.Query), volumeAnomaliesSort, cur.After, q.From, q.Size, &page.Anomalies)
+	if err != nil {
+		return nil, err
+	}
+	page.Total = total
+	if last != nil {
+		cur.After = last
+		cur.Query.From, cur.Query.Size = 0, 0
+		if page.NextCursor, err = encodeCursor(cur); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// volumeAnomaliesQuery returns the query clause selecting the volume anomalies matching q
+func volumeAnomaliesQuery(q VolumeAnomalyQuery) map[string]interface{} {
+	filters := []map[string]interface{}{}
+	if q.Source != "" {
+		filters = append(filters, map[string]interface{}{
+			"term": map[string]interface{}{
+				"source": q.Source,
+			},
+		})
+	}
+	if q.Kind != "" {
+		filters = append(filters, map[string]interface{}{
+			"term": map[string]interface{}{
+				"kind": q.Kind,
+			},
+		})
+	}
+	if !q.Start.IsZero() || !q.End.IsZero() {
+		r := map[string]interface{}{}
+		if !q.Start.IsZero() {
+			r["gte"] = q.Start.Format(time.RFC3339)
+		}
+		if !q.End.IsZero() {
+			r["lte"] = q.End.Format(time.RFC3339)
+		}
+		filters = append(filters, map[string]interface{}{
+			"range": map[string]interface{}{
+				"timestamp": r,
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": filters,
+		},
+	}
+}
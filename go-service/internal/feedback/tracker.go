@@ -0,0 +1,122 @@
+package feedback
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// Verdicts an analyst can give on a log
+const (
+	VerdictAnomaly = "anomaly"
+	VerdictNormal  = "normal"
+)
+
+// ParseVerdict normalizes a verdict, accepting false_positive and true_positive as
+// shorthands for a flagged log being normal or anomalous
+func ParseVerdict(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case VerdictAnomaly, "true_positive":
+		return VerdictAnomaly, nil
+	case VerdictNormal, "false_positive":
+		return VerdictNormal, nil
+	default:
+		return "", fmt.Errorf("invalid verdict %q, use anomaly or normal", s)
+	}
+}
+
+// Outcomes of a detector verdict judged against the analyst verdict
+const (
+	TruePositive  = "true_positive"
+	FalsePositive = "false_positive"
+	FalseNegative = "false_negative"
+	TrueNegative  = "true_negative"
+)
+
+// Outcome classifies a detector verdict against the analyst verdict
+func Outcome(predicted bool, verdict string) string {
+	actual := verdict == VerdictAnomaly
+	switch {
+	case predicted && actual:
+		return TruePositive
+	case predicted:
+		return FalsePositive
+	case actual:
+		return FalseNegative
+	default:
+		return TrueNegative
+	}
+}
+
+type confusion map[string]int64
+
+// Tracker keeps the confusion matrix of every detector over the logs that received
+// feedback and publishes their precision and recall
+type Tracker struct {
+	mu     sync.Mutex
+	counts map[string]confusion
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{counts: make(map[string]confusion)}
+}
+
+// Apply accounts for a new verdict. prev is the verdict it replaces, if any, so
+// that changing one's mind about a log does not count it twice.
+func (t *Tracker) Apply(prev, doc *elastic.FeedbackDocument) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	touched := map[string]bool{}
+	if prev != nil {
+		for detector, predicted := range predictions(prev) {
+			t.add(detector, Outcome(predicted, prev.Verdict), -1)
+			touched[detector] = true
+		}
+	}
+	if doc != nil {
+		for detector, predicted := range predictions(doc) {
+			t.add(detector, Outcome(predicted, doc.Verdict), 1)
+			touched[detector] = true
+		}
+	}
+	for detector := range touched {
+		t.publish(detector)
+	}
+}
+
+func (t *Tracker) add(detector, outcome string, n int64) {
+	c, ok := t.counts[detector]
+	if !ok {
+		c = confusion{}
+		t.counts[detector] = c
+	}
+	c[outcome] += n
+	metrics.FeedbackOutcomes.WithLabelValues(detector, outcome).Set(float64(c[outcome]))
+}
+
+func (t *Tracker) publish(detector string) {
+	c := t.counts[detector]
+	if flagged := c[TruePositive] + c[FalsePositive]; flagged > 0 {
+		metrics.DetectorPrecision.WithLabelValues(detector).Set(float64(c[TruePositive]) / float64(flagged))
+	}
+	if actual := c[TruePositive] + c[FalseNegative]; actual > 0 {
+		metrics.DetectorRecall.WithLabelValues(detector).Set(float64(c[TruePositive]) / float64(actual))
+	}
+}
+
+// predictions returns what each detector predicted for the log, including the final verdict
+func predictions(doc *elastic.FeedbackDocument) map[string]bool {
+	out := make(map[string]bool, len(doc.Detections)+1)
+	for _, d := range doc.Detections {
+		out[d.Detector] = d.IsAnomaly
+	}
+	if doc.Detector != "" {
+		out[doc.Detector] = doc.IsAnomaly
+	}
+	return out
+}
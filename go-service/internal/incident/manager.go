@@ -0,0 +1,504 @@
+package incident
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// Incident statuses
+const (
+	StatusOpen         = "open"
+	StatusAcknowledged = "acknowledged"
+	StatusResolved     = "resolved"
+)
+
+// Timeline event types
+const (
+	EventOpened       = "opened"
+	EventTemplate     = "template_added"
+	EventAcknowledged = "acknowledged"
+	EventAssigned     = "assigned"
+	EventResolved     = "resolved"
+	EventComment      = "comment"
+)
+
+var (
+	// ErrNotFound is returned when an incident ID does not exist
+	ErrNotFound = errors.New("incident not found")
+	// ErrResolved is returned when acknowledging or resolving an incident that is already resolved
+	ErrResolved = errors.New("incident is already resolved")
+)
+
+// Incident is a group of related anomalies
+type Incident = elastic.IncidentDocument
+
+// Store persists incidents; *elastic.Client implements it
+type Store interface {
+	SaveIncidents(ctx context.Context, incidents []elastic.IncidentDocument) error
+	GetIncident(ctx context.Context, id string) (*elastic.IncidentDocument, error)
+	ListIncidents(ctx context.Context, q elastic.IncidentQuery) (*elastic.IncidentPage, error)
+	GetActiveIncidents(ctx context.Context, since time.Time) ([]elastic.IncidentDocument, error)
+}
+
+// Config controls how anomalies are grouped
+type Config struct {
+	SourceKeys []string      // metadata keys that together identify a source, e.g. ["service", "host"]
+	Gap        time.Duration // anomalies further apart than this start a new incident
+}
+
+// Anomaly is an anomalous log to be assigned to an incident
+type Anomaly struct {
+	LogID      string
+	Text       string
+	TemplateID string
+	Metadata   map[string]interface{}
+	Timestamp  time.Time
+}
+
+// Manager groups anomalies into incidents. Anomalies of the same source that are less
+// than Gap apart belong to the same incident; anomalies without a known source are
+// grouped by template instead. Incidents stop collecting anomalies once resolved or
+// after Gap without a new one, but stay open until someone resolves them.
+//
+// Changes caused by new anomalies are kept in memory until Flush; changes made by
+// users are stored immediately.
+type Manager struct {
+	cfg   Config
+	store Store
+
+	updateMu sync.Mutex // serializes changes made by users
+
+	mu     sync.Mutex
+	active map[string]*Incident // by grouping key
+	byID   map[string]*Incident
+	dirty  map[string]bool
+}
+
+// NewManager creates a manager; store may be nil, in which case incidents only live in memory
+func NewManager(cfg Config, store Store) (*Manager, error) {
+	if cfg.Gap <= 0 {
+		return nil, fmt.Errorf("incident gap must be positive, got %s", cfg.Gap)
+	}
+	return &Manager{
+		cfg:    cfg,
+		store:  store,
+		active: make(map[string]*Incident),
+		byID:   make(map[string]*Incident),
+		dirty:  make(map[string]bool),
+	}, nil
+}
+
+// Load restores the incidents that can still collect anomalies, e.g. at startup
+func (m *Manager) Load(ctx context.Context) error {
+	if m.store == nil {
+		return nil
+	}
+	incidents, err := m.store.GetActiveIncidents(ctx, time.Now().UTC().Add(-m.cfg.Gap))
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range incidents {
+		inc := incidents[i]
+		key := groupKey(inc.Source, inc.TemplateIDs)
+		if cur, ok := m.active[key]; ok && cur.LastAnomalyAt.After(inc.LastAnomalyAt) {
+			continue
+		}
+		m.active[key] = &inc
+		m.byID[inc.ID] = &inc
+	}
+	return nil
+}
+
+// Record assigns an anomaly to an incident, opening one if needed, and returns the incident ID
+func (m *Manager) Record(a Anomaly) string {
+	source, keys := m.sourceOf(a.Metadata)
+	var templates []string
+	if a.TemplateID != "" {
+		templates = []string{a.TemplateID}
+	}
+	key := groupKey(source, templates)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inc, ok := m.active[key]
+	if ok && a.Timestamp.Sub(inc.LastAnomalyAt) > m.cfg.Gap {
+		delete(m.active, key)
+		inc, ok = nil, false
+	}
+
+	if !ok {
+		inc = &Incident{
+			ID:             newID(),
+			Title:          title(source, a.Text),
+			Status:         StatusOpen,
+			Source:         source,
+			SourceKeys:     keys,
+			TemplateIDs:    templates,
+			FirstAnomalyID: a.LogID,
+			FirstAnomalyAt: a.Timestamp,
+			CreatedAt:      time.Now().UTC(),
+			Timeline: []elastic.IncidentEvent{
+				{At: a.Timestamp, Type: EventOpened, Message: a.Text},
+			},
+		}
+		m.active[key] = inc
+		m.byID[inc.ID] = inc
+		metrics.IncidentsOpenedTotal.Inc()
+	} else if a.TemplateID != "" && !contains(inc.TemplateIDs, a.TemplateID) {
+		inc.TemplateIDs = append(inc.TemplateIDs, a.TemplateID)
+		inc.Timeline = append(inc.Timeline, elastic.IncidentEvent{
+			At:      a.Timestamp,
+			Type:    EventTemplate,
+			Message: a.Text,
+		})
+	}
+
+	inc.AnomalyCount++
+	if !a.Timestamp.Before(inc.LastAnomalyAt) {
+		inc.LastAnomalyID = a.LogID
+		inc.LastAnomalyAt = a.Timestamp
+	}
+	inc.UpdatedAt = time.Now().UTC()
+	m.dirty[inc.ID] = true
+	return inc.ID
+}
+
+// Flush stores the incidents changed by new anomalies and forgets the ones that can
+// no longer collect anomalies
+func (m *Manager) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	pending := make([]Incident, 0, len(m.dirty))
+	for id := range m.dirty {
+		if inc, ok := m.byID[id]; ok {
+			pending = append(pending, clone(inc))
+		}
+	}
+	m.dirty = make(map[string]bool)
+	m.mu.Unlock()
+
+	if m.store != nil && len(pending) > 0 {
+		if err := m.store.SaveIncidents(ctx, pending); err != nil {
+			m.mu.Lock()
+			for _, inc := range pending {
+				m.dirty[inc.ID] = true
+			}
+			m.mu.Unlock()
+			return err
+		}
+	}
+
+	if m.store != nil {
+		m.prune(time.Now().UTC())
+	}
+	return nil
+}
+
+// Get returns an incident by ID
+func (m *Manager) Get(ctx context.Context, id string) (Incident, error) {
+	m.mu.Lock()
+	if inc, ok := m.byID[id]; ok {
+		out := clone(inc)
+		m.mu.Unlock()
+		return out, nil
+	}
+	m.mu.Unlock()
+
+	if m.store == nil {
+		return Incident{}, ErrNotFound
+	}
+	inc, err := m.store.GetIncident(ctx, id)
+	if err != nil {
+		return Incident{}, err
+	}
+	if inc == nil {
+		return Incident{}, ErrNotFound
+	}
+	return *inc, nil
+}
+
+// List returns a page of the incidents matching q, most recently active first. Without a
+// store, its cursors hold the last activity time and ID of the last incident of a page.
+func (m *Manager) List(ctx context.Context, q elastic.IncidentQuery) (*elastic.IncidentPage, error) {
+	if m.store != nil {
+		if err := m.Flush(ctx); err != nil {
+			return nil, err
+		}
+		return m.store.ListIncidents(ctx, q)
+	}
+
+	cur := elastic.IncidentCursor{Query: q}
+	var afterAt time.Time
+	var afterID string
+	if q.Cursor != "" {
+		var err error
+		if cur, err = elastic.DecodeIncidentCursor(q.Cursor); err != nil {
+			return nil, err
+		}
+		at, ok := cur.After[0].(string)
+		id, ok2 := cur.After[1].(string)
+		if afterAt, err = time.Parse(time.RFC3339Nano, at); !ok || !ok2 || err != nil {
+			return nil, elastic.ErrInvalidCursor
+		}
+		afterID = id
+	}
+
+	m.mu.Lock()
+	matched := []Incident{}
+	for _, inc := range m.byID {
+		if cur.Query.Status == "" || inc.Status == cur.Query.Status {
+			matched = append(matched, clone(inc))
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(matched, func(i, j int) bool { return before(&matched[i], &matched[j]) })
+	start := min(q.From, len(matched))
+	if q.Cursor != "" {
+		last := Incident{ID: afterID, LastAnomalyAt: afterAt}
+		start = sort.Search(len(matched), func(i int) bool { return before(&last, &matched[i]) })
+	}
+	end := min(start+q.Size, len(matched))
+
+	page := &elastic.IncidentPage{Incidents: matched[start:end], Total: len(matched)}
+	if end < len(matched) && q.Size > 0 {
+		last := matched[end-1]
+		cur.After = []interface{}{last.LastAnomalyAt.Format(time.RFC3339Nano), last.ID}
+		var err error
+		if page.NextCursor, err = elastic.EncodeIncidentCursor(cur); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// before reports whether a is listed before b: most recently active first, by ID on ties
+func before(a, b *Incident) bool {
+	if !a.LastAnomalyAt.Equal(b.LastAnomalyAt) {
+		return a.LastAnomalyAt.After(b.LastAnomalyAt)
+	}
+	return a.ID > b.ID
+}
+
+// Acknowledge marks an incident as being worked on, optionally assigning it
+func (m *Manager) Acknowledge(ctx context.Context, id, user, assignee, comment string) (Incident, error) {
+	return m.update(ctx, id, func(inc *Incident, now time.Time) error {
+		if inc.Status == StatusResolved {
+			return ErrResolved
+		}
+		if inc.Status != StatusAcknowledged {
+			inc.Status = StatusAcknowledged
+			inc.AcknowledgedAt = &now
+			inc.Timeline = append(inc.Timeline, elastic.IncidentEvent{At: now, Type: EventAcknowledged, User: user, Message: comment})
+		} else if comment != "" {
+			inc.Timeline = append(inc.Timeline, elastic.IncidentEvent{At: now, Type: EventComment, User: user, Message: comment})
+		}
+		if assignee != "" && assignee != inc.Assignee {
+			inc.Assignee = assignee
+			inc.Timeline = append(inc.Timeline, elastic.IncidentEvent{At: now, Type: EventAssigned, User: user, Message: assignee})
+		}
+		return nil
+	})
+}
+
+// Resolve closes an incident; later anomalies of the same source open a new one
+func (m *Manager) Resolve(ctx context.Context, id, user, comment string) (Incident, error) {
+	return m.update(ctx, id, func(inc *Incident, now time.Time) error {
+		if inc.Status == StatusResolved {
+			return ErrResolved
+		}
+		inc.Status = StatusResolved
+		inc.ResolvedAt = &now
+		inc.Timeline = append(inc.Timeline, elastic.IncidentEvent{At: now, Type: EventResolved, User: user, Message: comment})
+		return nil
+	})
+}
+
+// Comment adds a comment to an incident's timeline
+func (m *Manager) Comment(ctx context.Context, id, user, message string) (Incident, error) {
+	return m.update(ctx, id, func(inc *Incident, now time.Time) error {
+		inc.Timeline = append(inc.Timeline, elastic.IncidentEvent{At: now, Type: EventComment, User: user, Message: message})
+		return nil
+	})
+}
+
+// update applies fn to a copy of an incident and stores it right away. The copy only
+// replaces the incident in memory once it is stored, so a failed save changes nothing.
+func (m *Manager) update(ctx context.Context, id string, fn func(inc *Incident, now time.Time) error) (Incident, error) {
+	// Changes by users are serialized so two of them cannot overwrite each other
+	m.updateMu.Lock()
+	defer m.updateMu.Unlock()
+	now := time.Now().UTC()
+
+	m.mu.Lock()
+	var out Incident
+	var seen time.Time
+	inc, live := m.byID[id]
+	if live {
+		out = clone(inc)
+		seen = inc.UpdatedAt
+	}
+	m.mu.Unlock()
+
+	if !live {
+		// Incidents no longer collecting anomalies are only changed by users
+		if m.store == nil {
+			return Incident{}, ErrNotFound
+		}
+		stored, err := m.store.GetIncident(ctx, id)
+		if err != nil {
+			return Incident{}, err
+		}
+		if stored == nil {
+			return Incident{}, ErrNotFound
+		}
+		out = *stored
+	}
+
+	if err := fn(&out, now); err != nil {
+		return Incident{}, err
+	}
+	out.UpdatedAt = now
+	if m.store != nil {
+		if err := m.store.SaveIncidents(ctx, []Incident{out}); err != nil {
+			return Incident{}, err
+		}
+	}
+	if !live {
+		return out, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inc, ok := m.byID[id]
+	if !ok {
+		return out, nil
+	}
+	if inc.UpdatedAt.Equal(seen) {
+		*inc = clone(&out)
+		delete(m.dirty, id)
+	} else {
+		// Anomalies arrived while saving; they marked the incident dirty, so the next
+		// Flush stores them together with the change
+		fn(inc, now)
+	}
+	if inc.Status == StatusResolved {
+		m.release(inc)
+	}
+	return out, nil
+}
+
+// release stops an incident from collecting anomalies
+func (m *Manager) release(inc *Incident) {
+	for key, cur := range m.active {
+		if cur == inc {
+			delete(m.active, key)
+		}
+	}
+	if m.store != nil {
+		delete(m.byID, inc.ID)
+	}
+}
+
+// prune forgets stored incidents that can no longer collect anomalies
+func (m *Manager) prune(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, inc := range m.active {
+		if now.Sub(inc.LastAnomalyAt) > m.cfg.Gap {
+			delete(m.active, key)
+		}
+	}
+	for id, inc := range m.byID {
+		if m.dirty[id] {
+			continue
+		}
+		if inc.Status == StatusResolved || now.Sub(inc.LastAnomalyAt) > m.cfg.Gap {
+			delete(m.byID, id)
+		}
+	}
+}
+
+func (m *Manager) sourceOf(metadata map[string]interface{}) (string, map[string]string) {
+	keys := make(map[string]string, len(m.cfg.SourceKeys))
+	parts := make([]string, 0, len(m.cfg.SourceKeys))
+	for _, k := range m.cfg.SourceKeys {
+		raw, ok := metadata[k]
+		if !ok || raw == nil {
+			continue
+		}
+		v := fmt.Sprint(raw)
+		keys[k] = v
+		parts = append(parts, k+"="+v)
+	}
+	if len(parts) == 0 {
+		return "", nil
+	}
+	return strings.Join(parts, ","), keys
+}
+
+// groupKey identifies the incident an anomaly can join: its source, or its template
+// when the source is unknown
+func groupKey(source string, templates []string) string {
+	if source != "" {
+		return "source:" + source
+	}
+	if len(templates) > 0 {
+		return "template:" + templates[0]
+	}
+	return "unknown"
+}
+
+func title(source, text string) string {
+	if r := []rune(text); len(r) > 80 {
+		text = string(r[:80]) + "..."
+	}
+	if source == "" {
+		return text
+	}
+	return source + ": " + text
+}
+
+func clone(inc *Incident) Incident {
+	out := *inc
+	out.TemplateIDs = append([]string(nil), inc.TemplateIDs...)
+	out.Timeline = append([]elastic.IncidentEvent(nil), inc.Timeline...)
+	if inc.SourceKeys != nil {
+		out.SourceKeys = make(map[string]string, len(inc.SourceKeys))
+		for k, v := range inc.SourceKeys {
+			out.SourceKeys[k] = v
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("inc-%d", time.Now().UnixNano())
+	}
+	return "inc-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,150 @@
+package incident
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+)
+
+// memStore keeps saved incidents in memory and fails saves while err is set
+type memStore struct {
+	mu    sync.Mutex
+	err   error
+	saved map[string]elastic.IncidentDocument
+}
+
+func (s *memStore) SaveIncidents(_ context.Context, incidents []elastic.IncidentDocument) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	for _, inc := range incidents {
+		s.saved[inc.ID] = inc
+	}
+	return nil
+}
+
+func (s *memStore) GetIncident(_ context.Context, id string) (*elastic.IncidentDocument, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inc, ok := s.saved[id]
+	if !ok {
+		return nil, nil
+	}
+	return &inc, nil
+}
+
+func (s *memStore) ListIncidents(context.Context, elastic.IncidentQuery) (*elastic.IncidentPage, error) {
+	return &elastic.IncidentPage{}, nil
+}
+
+func (s *memStore) GetActiveIncidents(context.Context, time.Time) ([]elastic.IncidentDocument, error) {
+	return nil, nil
+}
+
+func TestUpdateKeepsIncidentWhenSaveFails(t *testing.T) {
+	ctx := context.Background()
+	store := &memStore{saved: make(map[string]elastic.IncidentDocument)}
+	m, err := NewManager(Config{SourceKeys: []string{"service"}, Gap: time.Hour}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := m.Record(Anomaly{LogID: "log-1", Text: "disk full", Metadata: map[string]interface{}{"service": "db"}, Timestamp: time.Now().UTC()})
+
+	store.err = errors.New("elasticsearch unavailable")
+	if _, err := m.Resolve(ctx, id, "ana", "fixed"); !errors.Is(err, store.err) {
+		t.Fatalf("Resolve = %v, want %v", err, store.err)
+	}
+	inc, err := m.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inc.Status != StatusOpen || len(inc.Timeline) != 1 {
+		t.Fatalf("after a failed save: status %s with %d timeline events, want it unchanged", inc.Status, len(inc.Timeline))
+	}
+	// The incident still collects anomalies of its source
+	if got := m.Record(Anomaly{LogID: "log-2", Text: "disk full", Metadata: map[string]interface{}{"service": "db"}, Timestamp: time.Now().UTC()}); got != id {
+		t.Errorf("anomaly recorded in %s, want %s", got, id)
+	}
+
+	store.err = nil
+	if _, err := m.Acknowledge(ctx, id, "ana", "bob", "looking"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Resolve(ctx, id, "ana", "fixed"); err != nil {
+		t.Fatal(err)
+	}
+	inc, err = m.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inc.Status != StatusResolved || inc.Assignee != "bob" || inc.AnomalyCount != 2 {
+		t.Errorf("stored incident %+v, want it resolved, assigned to bob with 2 anomalies", inc)
+	}
+	if got := m.Record(Anomaly{LogID: "log-3", Text: "disk full", Metadata: map[string]interface{}{"service": "db"}, Timestamp: time.Now().UTC()}); got == id {
+		t.Error("anomaly recorded in the resolved incident")
+	}
+}
+
+func TestListPagesInMemory(t *testing.T) {
+	ctx := context.Background()
+	m, err := NewManager(Config{SourceKeys: []string{"service"}, Gap: time.Hour}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().UTC()
+	ids := make(map[string]string)
+	// b and c were last active at the same time
+	for service, minute := range map[string]int{"a": 0, "b": 1, "c": 1, "d": 3, "e": 4} {
+		ids[service] = m.Record(Anomaly{LogID: service, Text: "error", Metadata: map[string]interface{}{"service": service}, Timestamp: start.Add(time.Duration(minute) * time.Minute)})
+	}
+	if _, err := m.Resolve(ctx, ids["d"], "ana", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pages continue from their cursor even when an incident is resolved in between
+	var got []string
+	var totals []int
+	q := elastic.IncidentQuery{Status: StatusOpen, Size: 2}
+	for pages := 0; ; pages++ {
+		page, err := m.List(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		totals = append(totals, page.Total)
+		for _, inc := range page.Incidents {
+			got = append(got, inc.Source)
+		}
+		if page.NextCursor == "" || pages > 3 {
+			break
+		}
+		if pages == 0 {
+			if _, err := m.Resolve(ctx, ids["a"], "ana", ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	// Ties go to the higher ID first; a was resolved after the first page
+	want := []string{"service=e", "service=b", "service=c"}
+	if ids["c"] > ids["b"] {
+		want[1], want[2] = want[2], want[1]
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listed %q, want %q", got, want)
+	}
+	if want := []int{4, 3}; !reflect.DeepEqual(totals, want) {
+		t.Errorf("totals = %v, want %v", totals, want)
+	}
+
+	if _, err := m.List(ctx, elastic.IncidentQuery{Size: 2, Cursor: "not a cursor"}); !errors.Is(err, elastic.ErrInvalidCursor) {
+		t.Errorf("List with a bad cursor = %v, want ErrInvalidCursor", err)
+	}
+}
@@ -0,0 +1,314 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// MultilineRule decides which lines continue the previous event of a stream. A line is a
+// continuation when it is indented (Indent), matches Continue, or does not match Start.
+type MultilineRule struct {
+	Name     string            `yaml:"name" json:"name"`
+	Match    map[string]string `yaml:"match,omitempty" json:"match,omitempty"`       // metadata values the log must carry
+	Start    string            `yaml:"start,omitempty" json:"start,omitempty"`       // regex of the first line of an event
+	Continue string            `yaml:"continue,omitempty" json:"continue,omitempty"` // regex of continuation lines
+	Indent   bool              `yaml:"indent,omitempty" json:"indent,omitempty"`     // lines starting with whitespace continue
+}
+
+// MultilineConfig is the layout of the multiline config file. The first rule matching
+// a log is used; logs no rule applies to pass through unchanged.
+type MultilineConfig struct {
+	SourceKeys []string        `yaml:"source_keys,omitempty"` // metadata keys identifying a stream, default service and host
+	Timeout    time.Duration   `yaml:"timeout,omitempty"`     // an event is complete after this long without new lines, default 2s
+	MaxLines   int             `yaml:"max_lines,omitempty"`   // default 500
+	MaxBytes   int             `yaml:"max_bytes,omitempty"`   // default 64KiB
+	Rules      []MultilineRule `yaml:"rules"`
+}
+
+// LoadMultilineConfig reads the multiline configuration file
+func LoadMultilineConfig(path string) (*MultilineConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read multiline config: %w", err)
+	}
+	cfg := &MultilineConfig{}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse multiline config: %w", err)
+	}
+	return cfg, nil
+}
+
+type multilineRule struct {
+	MultilineRule
+	start *regexp.Regexp
+	cont  *regexp.Regexp
+}
+
+func (r *multilineRule) matches(metadata map[string]interface{}) bool {
+	for k, want := range r.Match {
+		got, ok := metadata[k]
+		if !ok || fmt.Sprint(got) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// continues reports whether line belongs to the event before it
+func (r *multilineRule) continues(line string) bool {
+	if r.Indent && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+		return true
+	}
+	if r.cont != nil && r.cont.MatchString(line) {
+		return true
+	}
+	return r.start != nil && !r.start.MatchString(line)
+}
+
+type pendingEvent struct {
+	ev      Event
+	rule    string
+	lines   int
+	seq     uint64
+	updated time.Time
+}
+
+// Assembler joins the lines of stack traces and other multiline messages into single
+// events. Lines are buffered per stream, so an event can span several requests.
+type Assembler struct {
+	cfg   MultilineConfig
+	rules []*multilineRule
+
+	mu      sync.Mutex
+	pending map[string]*pendingEvent
+	seq     uint64
+	joined  map[string]int // multiline events completed per rule, not yet counted in the metrics
+}
+
+// NewAssembler compiles the rules of cfg
+func NewAssembler(cfg MultilineConfig) (*Assembler, error) {
+	if len(cfg.SourceKeys) == 0 {
+		cfg.SourceKeys = []string{"service", "host"}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if cfg.MaxLines <= 0 {
+		cfg.MaxLines = 500
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 64 << 10
+	}
+
+	a := &Assembler{cfg: cfg, pending: make(map[string]*pendingEvent)}
+	for i, rc := range cfg.Rules {
+		if rc.Name == "" {
+			rc.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if rc.Start == "" && rc.Continue == "" && !rc.Indent {
+			return nil, fmt.Errorf("multiline rule %q: it needs start, continue or indent", rc.Name)
+		}
+		r := &multilineRule{MultilineRule: rc}
+		var err error
+		if rc.Start != "" {
+			if r.start, err = regexp.Compile(rc.Start); err != nil {
+				return nil, fmt.Errorf("multiline rule %q: bad start pattern: %w", rc.Name, err)
+			}
+		}
+		if rc.Continue != "" {
+			if r.cont, err = regexp.Compile(rc.Continue); err != nil {
+				return nil, fmt.Errorf("multiline rule %q: bad continue pattern: %w", rc.Name, err)
+			}
+		}
+		a.rules = append(a.rules, r)
+	}
+	if len(a.rules) == 0 {
+		return nil, errors.New("multiline config has no rules")
+	}
+	return a, nil
+}
+
+// Timeout returns how long an event waits for more lines
+func (a *Assembler) Timeout() time.Duration { return a.cfg.Timeout }
+
+// Add splits the events into lines and appends them to their streams, then hands the
+// events completed by these lines to commit. The last event of every stream stays pending
+// until a new event starts or it times out; logs no rule applies to are passed as they are.
+// When commit fails, e.g. because the queue is full, the streams are left as they were, so
+// the caller can reject the request and the client send it again.
+func (a *Assembler) Add(events []Event, commit func([]Event) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	staged := a.stage()
+	out := staged.add(events)
+	if err := commit(out); err != nil {
+		return err
+	}
+	a.pending, a.seq = staged.pending, staged.seq
+	staged.report()
+	return nil
+}
+
+// Join assembles a self-contained batch: streams start empty and every event is returned,
+// without touching the lines pending from other requests
+func (a *Assembler) Join(events []Event) []Event {
+	batch := &Assembler{cfg: a.cfg, rules: a.rules, pending: make(map[string]*pendingEvent)}
+	out := batch.add(events)
+	out = append(out, batch.flush(func(*pendingEvent) bool { return true })...)
+	batch.report()
+	return out
+}
+
+// FlushExpired hands the pending events that received no line for the timeout to commit;
+// they stay pending when commit fails
+func (a *Assembler) FlushExpired(now time.Time, commit func([]Event) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	staged := a.stage()
+	out := staged.flush(func(p *pendingEvent) bool { return now.Sub(p.updated) >= a.cfg.Timeout })
+	if len(out) == 0 {
+		return nil
+	}
+	if err := commit(out); err != nil {
+		return err
+	}
+	a.pending = staged.pending
+	staged.report()
+	return nil
+}
+
+// Flush returns every pending event, e.g. at shutdown
+func (a *Assembler) Flush() []Event {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := a.flush(func(*pendingEvent) bool { return true })
+	a.report()
+	return out
+}
+
+// stage returns a copy of the assembler whose streams can be changed without touching a
+func (a *Assembler) stage() *Assembler {
+	staged := &Assembler{cfg: a.cfg, rules: a.rules, pending: make(map[string]*pendingEvent, len(a.pending)), seq: a.seq}
+	for key, p := range a.pending {
+		cp := *p
+		staged.pending[key] = &cp
+	}
+	return staged
+}
+
+// report counts the multiline events completed since the last report
+func (a *Assembler) report() {
+	for rule, n := range a.joined {
+		metrics.MultilineEventsTotal.WithLabelValues(rule).Add(float64(n))
+	}
+	a.joined = nil
+}
+
+func (a *Assembler) add(events []Event) []Event {
+	var out []Event
+	for _, ev := range events {
+		rule := a.rule(ev.Metadata)
+		if rule == nil {
+			out = append(out, ev)
+			continue
+		}
+		key := a.streamKey(rule, ev)
+
+		lines := splitLines(ev.Text)
+		for i, line := range lines {
+			p := a.pending[key]
+			if p != nil && rule.continues(line) && p.lines < a.cfg.MaxLines && len(p.ev.Text)+1+len(line) <= a.cfg.MaxBytes {
+				p.ev.Text += "\n" + line
+				p.lines++
+				p.updated = ev.ReceivedAt
+				continue
+			}
+			if p != nil {
+				out = append(out, a.finish(p))
+			}
+
+			lineEv := ev
+			lineEv.Text = line
+			if len(lines) > 1 {
+				lineEv.ID = fmt.Sprintf("%s-%d", ev.ID, i)
+			}
+			a.seq++
+			a.pending[key] = &pendingEvent{ev: lineEv, rule: rule.Name, lines: 1, seq: a.seq, updated: ev.ReceivedAt}
+		}
+	}
+	return out
+}
+
+func (a *Assembler) flush(done func(*pendingEvent) bool) []Event {
+	var flushed []*pendingEvent
+	for key, p := range a.pending {
+		if done(p) {
+			flushed = append(flushed, p)
+			delete(a.pending, key)
+		}
+	}
+	sort.Slice(flushed, func(i, j int) bool { return flushed[i].seq < flushed[j].seq })
+
+	out := make([]Event, len(flushed))
+	for i, p := range flushed {
+		out[i] = a.finish(p)
+	}
+	return out
+}
+
+func (a *Assembler) finish(p *pendingEvent) Event {
+	if p.lines > 1 {
+		if a.joined == nil {
+			a.joined = make(map[string]int)
+		}
+		a.joined[p.rule]++
+	}
+	return p.ev
+}
+
+func (a *Assembler) rule(metadata map[string]interface{}) *multilineRule {
+	for _, r := range a.rules {
+		if r.matches(metadata) {
+			return r
+		}
+	}
+	return nil
+}
+
+// streamKey identifies the stream of an event by its rule, content type and source
+func (a *Assembler) streamKey(r *multilineRule, ev Event) string {
+	parts := []string{r.Name, ev.ContentType}
+	for _, k := range a.cfg.SourceKeys {
+		v, ok := ev.Metadata[k]
+		if !ok {
+			parts = append(parts, "")
+			continue
+		}
+		parts = append(parts, fmt.Sprint(v))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// splitLines splits text on line breaks, dropping blank lines
+func splitLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
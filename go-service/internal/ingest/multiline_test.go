@@ -0,0 +1,195 @@
+package ingest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testAssembler(t *testing.T, cfg MultilineConfig) *Assembler {
+	t.Helper()
+	if cfg.Rules == nil {
+		cfg.Rules = []MultilineRule{
+			{Name: "java", Match: map[string]string{"service": "java"}, Indent: true, Continue: `^Caused by:`},
+			{Name: "dated", Match: map[string]string{"service": "api"}, Start: `^\d{4}-\d{2}-\d{2} `},
+		}
+	}
+	a, err := NewAssembler(cfg)
+	if err != nil {
+		t.Fatalf("NewAssembler: %v", err)
+	}
+	return a
+}
+
+func texts(events []Event) []string {
+	var out []string
+	for _, ev := range events {
+		out = append(out, ev.Text)
+	}
+	return out
+}
+
+func event(service, text string) Event {
+	return Event{ID: "ev", Text: text, Metadata: map[string]interface{}{"service": service}, ReceivedAt: time.Now()}
+}
+
+func TestAssemblerJoin(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    MultilineConfig
+		events []Event
+		want   []string
+	}{
+		{
+			name:   "indented stack trace",
+			events: []Event{event("java", "Exception in thread main\n\tat a.b(C.java:1)\n\tat d.e(F.java:2)\nCaused by: boom\nnext line")},
+			want:   []string{"Exception in thread main\n\tat a.b(C.java:1)\n\tat d.e(F.java:2)\nCaused by: boom", "next line"},
+		},
+		{
+			name: "lines not matching start continue",
+			events: []Event{
+				event("api", "2024-01-01 request failed"),
+				event("api", "  details: timeout"),
+				event("api", "retrying"),
+				event("api", "2024-01-01 request ok"),
+			},
+			want: []string{"2024-01-01 request failed\n  details: timeout\nretrying", "2024-01-01 request ok"},
+		},
+		{
+			name:   "logs without a rule pass through",
+			events: []Event{event("db", "one\n  two")},
+			want:   []string{"one\n  two"},
+		},
+		{
+			name:   "streams are kept apart",
+			events: []Event{event("java", "first"), event("api", "  not java"), event("java", "  java")},
+			want:   []string{"first\n  java", "  not java"},
+		},
+		{
+			name:   "max lines starts a new event",
+			cfg:    MultilineConfig{MaxLines: 2, Rules: []MultilineRule{{Name: "indent", Indent: true}}},
+			events: []Event{event("x", "a\n b\n c")},
+			want:   []string{"a\n b", " c"},
+		},
+		{
+			name:   "blank lines are dropped",
+			events: []Event{event("java", "head\n\n\tat x\r\n")},
+			want:   []string{"head\n\tat x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testAssembler(t, tt.cfg)
+			if got := texts(a.Join(tt.events)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Join = %q, want %q", got, tt.want)
+			}
+			if got := a.Flush(); len(got) != 0 {
+				t.Errorf("Join left %d events pending", len(got))
+			}
+		})
+	}
+}
+
+func TestAssemblerAddAcrossCalls(t *testing.T) {
+	a := testAssembler(t, MultilineConfig{})
+	var committed []string
+	commit := func(events []Event) error {
+		committed = append(committed, texts(events)...)
+		return nil
+	}
+
+	if err := a.Add([]Event{event("java", "Exception: boom\n\tat a.b")}, commit); err != nil {
+		t.Fatal(err)
+	}
+	if len(committed) != 0 {
+		t.Fatalf("pending event committed early: %q", committed)
+	}
+	if err := a.Add([]Event{event("java", "\tat c.d\nnext")}, commit); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Exception: boom\n\tat a.b\n\tat c.d"}; !reflect.DeepEqual(committed, want) {
+		t.Fatalf("committed %q, want %q", committed, want)
+	}
+	if got := texts(a.Flush()); !reflect.DeepEqual(got, []string{"next"}) {
+		t.Errorf("Flush = %q", got)
+	}
+}
+
+func TestAssemblerAddKeepsStateWhenCommitFails(t *testing.T) {
+	a := testAssembler(t, MultilineConfig{})
+	ok := func([]Event) error { return nil }
+	if err := a.Add([]Event{event("java", "first\n\tat a.b")}, ok); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rejected batch would complete the first event and start a new one
+	batch := []Event{event("java", "\tat c.d\nsecond")}
+	if err := a.Add(batch, func([]Event) error { return ErrQueueFull }); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Add = %v, want ErrQueueFull", err)
+	}
+
+	// Sent again, the batch is assembled as if it was the first attempt
+	var committed []string
+	if err := a.Add(batch, func(events []Event) error {
+		committed = texts(events)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"first\n\tat a.b\n\tat c.d"}; !reflect.DeepEqual(committed, want) {
+		t.Errorf("committed %q, want %q", committed, want)
+	}
+	if got := texts(a.Flush()); !reflect.DeepEqual(got, []string{"second"}) {
+		t.Errorf("Flush = %q", got)
+	}
+}
+
+func TestAssemblerFlushExpired(t *testing.T) {
+	a := testAssembler(t, MultilineConfig{Timeout: time.Second})
+	start := time.Now()
+	ev := event("java", "head\n\tat x")
+	ev.ReceivedAt = start
+	if err := a.Add([]Event{ev}, func([]Event) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	fail := func([]Event) error { return ErrQueueFull }
+	if err := a.FlushExpired(start.Add(500*time.Millisecond), fail); err != nil {
+		t.Fatalf("FlushExpired before the timeout = %v, want nothing to flush", err)
+	}
+	if err := a.FlushExpired(start.Add(time.Second), fail); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("FlushExpired = %v, want ErrQueueFull", err)
+	}
+
+	var flushed []string
+	if err := a.FlushExpired(start.Add(time.Second), func(events []Event) error {
+		flushed = texts(events)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"head\n\tat x"}; !reflect.DeepEqual(flushed, want) {
+		t.Errorf("flushed %q, want %q", flushed, want)
+	}
+	if got := a.Flush(); len(got) != 0 {
+		t.Errorf("%d events still pending", len(got))
+	}
+}
+
+func TestNewAssemblerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  MultilineConfig
+	}{
+		{"no rules", MultilineConfig{}},
+		{"rule without condition", MultilineConfig{Rules: []MultilineRule{{Name: "empty"}}}},
+		{"bad start", MultilineConfig{Rules: []MultilineRule{{Start: "("}}}},
+		{"bad continue", MultilineConfig{Rules: []MultilineRule{{Continue: "["}}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewAssembler(tt.cfg); err == nil {
+			t.Errorf("%s: NewAssembler succeeded", tt.name)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+var (
+	// ErrQueueFull is returned by Enqueue when the batch does not fit in the remaining capacity
+	ErrQueueFull = errors.New("ingestion queue is full")
+	// ErrQueueClosed is returned by Enqueue once the queue has been shut down
+	ErrQueueClosed = errors.New("ingestion queue is closed")
+)
+
+// Event is a single log line waiting to be preprocessed, classified and indexed
+type Event struct {
+	ID          string                 `json:"id"`
+	BatchID     string                 `json:"batch_id"`
+	Text        string                 `json:"text"`
+	ContentType string                 `json:"content_type"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	ReceivedAt  time.Time              `json:"received_at"`
+
+	// Time and Level are set by inputs whose transport carries them, e.g. the syslog header
+	Time  time.Time `json:"time,omitempty"`
+	Level string    `json:"level,omitempty"`
+	// TraceID and SpanID are the hex trace context of OTLP records
+	TraceID string `json:"trace_id,omitempty"`
+	SpanID  string `json:"span_id,omitempty"`
+}
+
+// ProcessFunc handles one event taken off the queue
+type ProcessFunc func(ctx context.Context, ev Event)
+
+// Queue is a bounded in-memory queue drained by a fixed pool of workers
+type Queue struct {
+	events  chan Event
+	process ProcessFunc
+	wg      sync.WaitGroup
+
+	mu       sync.Mutex
+	reserved int
+	closed   bool
+}
+
+// NewQueue creates a queue holding at most capacity events and starts workers goroutines to drain it
+func NewQueue(capacity, workers int, process ProcessFunc) *Queue {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	q := &Queue{
+		events:  make(chan Event, capacity),
+		process: process,
+	}
+
+	q.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+	return q
+}
+
+// Enqueue adds all events or none of them. It never blocks: when the batch does not fit,
+// ErrQueueFull is returned so the caller can apply backpressure.
+func (q *Queue) Enqueue(events []Event) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if q.reserved+len(events) > cap(q.events) {
+		metrics.IngestRejectedTotal.Add(float64(len(events)))
+		return ErrQueueFull
+	}
+
+	// Reserved slots never exceed the channel capacity, so these sends cannot block
+	q.reserved += len(events)
+	for _, ev := range events {
+		q.events <- ev
+	}
+	metrics.IngestQueueDepth.Set(float64(q.reserved))
+	return nil
+}
+
+// Len returns the number of events waiting to be processed
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.reserved
+}
+
+// Close stops accepting new events and waits for the workers to drain the queue
+// or for ctx to expire, whichever comes first.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for ev := range q.events {
+		q.mu.Lock()
+		q.reserved--
+		metrics.IngestQueueDepth.Set(float64(q.reserved))
+		q.mu.Unlock()
+
+		q.process(context.Background(), ev)
+	}
+}
@@ -0,0 +1,362 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/ingest"
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// FluentConfig sets the forward listener and how records map to log text and metadata
+type FluentConfig struct {
+	Addr string
+
+	// MessageKeys are the record keys tried for the log text, default log, message and msg;
+	// records without any of them are sent as JSON
+	MessageKeys []string
+	// MetadataKeys are the record keys copied to metadata, as "path" or "path=name" where
+	// path may reach into nested maps (kubernetes.pod_name=pod); empty copies every other key
+	MetadataKeys []string
+	// LevelKey is the record key holding the level, default level
+	LevelKey string
+	// TagKey is the metadata key holding the tag, default tag; "-" leaves the tag out
+	TagKey string
+	// TagFields name the dot-separated parts of the tag, e.g. env,service for "prod.payments"
+	TagFields []string
+
+	MaxChunkSize int           // entries whose encoded size is larger close the connection, default 8MiB
+	IdleTimeout  time.Duration // connections silent for longer are closed, default 5m
+}
+
+type metadataKey struct {
+	path []string
+	name string
+}
+
+// FluentServer speaks the Fluent Forward protocol v1 over TCP: Message, Forward, PackedForward
+// and CompressedPackedForward modes, with acks for entries carrying a chunk option.
+// Authentication (HELO/PING) and the UDP heartbeat are not supported.
+type FluentServer struct {
+	cfg          FluentConfig
+	sink         Sink
+	metadataKeys []metadataKey
+
+	listener *streamListener
+	wg       sync.WaitGroup
+}
+
+// NewFluentServer applies the defaults of cfg
+func NewFluentServer(cfg FluentConfig, sink Sink) (*FluentServer, error) {
+	if len(cfg.MessageKeys) == 0 {
+		cfg.MessageKeys = []string{"log", "message", "msg"}
+	}
+	if cfg.LevelKey == "" {
+		cfg.LevelKey = "level"
+	}
+	if cfg.TagKey == "" {
+		cfg.TagKey = "tag"
+	}
+	if cfg.MaxChunkSize <= 0 {
+		cfg.MaxChunkSize = 8 << 20
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 5 * time.Minute
+	}
+
+	s := &FluentServer{cfg: cfg, sink: sink}
+	for _, k := range cfg.MetadataKeys {
+		path, name, _ := strings.Cut(k, "=")
+		if path == "" {
+			return nil, fmt.Errorf("invalid fluent metadata key %q", k)
+		}
+		if name == "" {
+			name = path
+		}
+		s.metadataKeys = append(s.metadataKeys, metadataKey{path: strings.Split(path, "."), name: name})
+	}
+	return s, nil
+}
+
+// Listen binds the forward listener
+func (s *FluentServer) Listen() error {
+	l, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for fluent forward on %s: %w", s.cfg.Addr, err)
+	}
+	s.listener = newStreamListener("fluent", l)
+	log.Printf("Fluent forward listening on tcp %s", l.Addr())
+	return nil
+}
+
+// Serve receives entries until ctx is done, then closes the listener and connections
+func (s *FluentServer) Serve(ctx context.Context) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.listener.serve(ctx, func(conn net.Conn) { s.serveConn(ctx, conn) })
+	}()
+
+	<-ctx.Done()
+	s.listener.close()
+	s.wg.Wait()
+}
+
+func (s *FluentServer) serveConn(ctx context.Context, conn net.Conn) {
+	r := bufio.NewReaderSize(conn, 64<<10)
+	dec := newMsgpackDecoder(r, s.cfg.MaxChunkSize)
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.cfg.IdleTimeout))
+		v, err := dec.decode()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && ctx.Err() == nil {
+				reason := reasonInvalid
+				if errors.Is(err, errMsgpackTooLarge) {
+					reason = reasonTooLarge
+				}
+				metrics.InputDroppedTotal.WithLabelValues("fluent", reason).Inc()
+				log.Printf("Fluent connection from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		events, chunk, err := s.entry(v)
+		if err != nil {
+			// The stream may be out of sync, and without an ack the client sends the chunk again
+			metrics.InputDroppedTotal.WithLabelValues("fluent", reasonInvalid).Inc()
+			log.Printf("Closing fluent connection from %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+
+		// The connection is not read while the events are stored; the chunk is only
+		// acknowledged once they are, and left for the client to send again otherwise
+		if err := deliver(ctx, s.sink, "fluent", events, true); err != nil {
+			return
+		}
+		if chunk != "" {
+			ack := appendMsgpackString(append([]byte{0x81}, 0xa3, 'a', 'c', 'k'), chunk)
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if _, err := conn.Write(ack); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// entry decodes one forward protocol entry into events, returning the chunk ID to acknowledge
+func (s *FluentServer) entry(v interface{}) ([]ingest.Event, string, error) {
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) < 2 {
+		return nil, "", errors.New("entry is not an array")
+	}
+	tag := msgpackString(arr[0])
+
+	var events []ingest.Event
+	var option interface{}
+	switch second := arr[1].(type) {
+	case []interface{}:
+		// Forward mode: [tag, [[time, record], ...], option]
+		for _, e := range second {
+			ev, err := s.event(tag, e)
+			if err != nil {
+				return nil, "", err
+			}
+			events = append(events, ev)
+		}
+		if len(arr) > 2 {
+			option = arr[2]
+		}
+
+	case string, []byte:
+		// PackedForward mode: [tag, <concatenated [time, record] entries>, option]
+		if len(arr) > 2 {
+			option = arr[2]
+		}
+		data := []byte(msgpackString(second))
+		if opts, ok := option.(map[string]interface{}); ok && msgpackString(opts["compressed"]) == "gzip" {
+			var err error
+			if data, err = gunzip(data, s.cfg.MaxChunkSize); err != nil {
+				return nil, "", err
+			}
+		}
+
+		dec := newMsgpackDecoder(bufio.NewReader(bytes.NewReader(data)), s.cfg.MaxChunkSize)
+		for {
+			e, err := dec.decode()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid packed entries: %w", err)
+			}
+			ev, err := s.event(tag, e)
+			if err != nil {
+				return nil, "", err
+			}
+			events = append(events, ev)
+		}
+
+	default:
+		// Message mode: [tag, time, record, option]
+		if len(arr) < 3 {
+			return nil, "", errors.New("message entry without a record")
+		}
+		ev, err := s.event(tag, []interface{}{arr[1], arr[2]})
+		if err != nil {
+			return nil, "", err
+		}
+		events = append(events, ev)
+		if len(arr) > 3 {
+			option = arr[3]
+		}
+	}
+
+	var chunk string
+	if opts, ok := option.(map[string]interface{}); ok {
+		chunk = msgpackString(opts["chunk"])
+	}
+	return events, chunk, nil
+}
+
+// event maps a [time, record] pair to an ingestion event
+func (s *FluentServer) event(tag string, v interface{}) (ingest.Event, error) {
+	pair, ok := v.([]interface{})
+	if !ok || len(pair) < 2 {
+		return ingest.Event{}, errors.New("event is not a [time, record] pair")
+	}
+	record, ok := pair[1].(map[string]interface{})
+	if !ok {
+		return ingest.Event{}, errors.New("event record is not a map")
+	}
+	stringifyBytes(record)
+
+	ev := ingest.Event{
+		ContentType: "fluent",
+		Time:        fluentTime(pair[0]),
+		Metadata:    make(map[string]interface{}),
+	}
+
+	rest := make(map[string]interface{}, len(record))
+	for k, v := range record {
+		rest[k] = v
+	}
+	for _, k := range s.cfg.MessageKeys {
+		if text, ok := rest[k]; ok {
+			ev.Text = msgpackString(text)
+			delete(rest, k)
+			break
+		}
+	}
+	if ev.Text == "" {
+		b, _ := json.Marshal(record)
+		ev.Text = string(b)
+	}
+	if level, ok := rest[s.cfg.LevelKey]; ok {
+		ev.Level = msgpackString(level)
+	}
+
+	if s.metadataKeys == nil {
+		for k, v := range rest {
+			ev.Metadata[k] = v
+		}
+	}
+	for _, k := range s.metadataKeys {
+		if v, ok := lookupPath(record, k.path); ok {
+			ev.Metadata[k.name] = v
+		}
+	}
+
+	if s.cfg.TagKey != "-" {
+		ev.Metadata[s.cfg.TagKey] = tag
+	}
+	parts := strings.Split(tag, ".")
+	for i, name := range s.cfg.TagFields {
+		if i < len(parts) && name != "" && name != "-" {
+			ev.Metadata[name] = parts[i]
+		}
+	}
+	return ev, nil
+}
+
+// fluentTime reads an EventTime extension or integer seconds; anything else means now
+func fluentTime(v interface{}) time.Time {
+	switch t := v.(type) {
+	case msgpackExt:
+		if t.Type == 0 && len(t.Data) == 8 {
+			sec := binary.BigEndian.Uint32(t.Data[:4])
+			nsec := binary.BigEndian.Uint32(t.Data[4:])
+			return time.Unix(int64(sec), int64(nsec))
+		}
+	case int64:
+		return time.Unix(t, 0)
+	case uint64:
+		return time.Unix(int64(t), 0)
+	case float64:
+		return time.Unix(0, int64(t*1e9))
+	}
+	return time.Time{}
+}
+
+func lookupPath(m map[string]interface{}, path []string) (interface{}, bool) {
+	var v interface{} = m
+	for _, p := range path {
+		cur, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = cur[p]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// stringifyBytes turns the binary values Fluentd uses for strings into strings, recursively
+func stringifyBytes(m map[string]interface{}) {
+	for k, v := range m {
+		switch t := v.(type) {
+		case []byte:
+			m[k] = string(t)
+		case map[string]interface{}:
+			stringifyBytes(t)
+		case []interface{}:
+			for i, e := range t {
+				switch et := e.(type) {
+				case []byte:
+					t[i] = string(et)
+				case map[string]interface{}:
+					stringifyBytes(et)
+				}
+			}
+		}
+	}
+}
+
+func gunzip(data []byte, maxSize int) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed entries: %w", err)
+	}
+	defer zr.Close()
+
+	out, err := io.ReadAll(io.LimitReader(zr, int64(maxSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed entries: %w", err)
+	}
+	if len(out) > maxSize {
+		return nil, errMsgpackTooLarge
+	}
+	return out, nil
+}
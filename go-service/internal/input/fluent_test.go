@@ -0,0 +1,234 @@
+package input
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/ingest"
+)
+
+func eventTime(sec, nsec uint32) msgpackExt {
+	data := []byte{byte(sec >> 24), byte(sec >> 16), byte(sec >> 8), byte(sec), byte(nsec >> 24), byte(nsec >> 16), byte(nsec >> 8), byte(nsec)}
+	return msgpackExt{Type: 0, Data: data}
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFluentEntry(t *testing.T) {
+	s, err := NewFluentServer(FluentConfig{TagFields: []string{"env", "service"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := func(msg string) map[string]interface{} {
+		return map[string]interface{}{"log": msg, "level": "warn", "pod": []byte("p1")}
+	}
+	packed := append(
+		encodeMsgpack([]interface{}{int64(1700000000), record("one")}),
+		encodeMsgpack([]interface{}{eventTime(1700000001, 500), record("two")})...,
+	)
+
+	tests := []struct {
+		name      string
+		entry     []interface{}
+		wantTexts []string
+		wantChunk string
+	}{
+		{
+			name:      "message mode",
+			entry:     []interface{}{"prod.api", int64(1700000000), record("one"), map[string]interface{}{"chunk": "c1"}},
+			wantTexts: []string{"one"},
+			wantChunk: "c1",
+		},
+		{
+			name: "forward mode",
+			entry: []interface{}{"prod.api", []interface{}{
+				[]interface{}{int64(1700000000), record("one")},
+				[]interface{}{eventTime(1700000001, 500), record("two")},
+			}},
+			wantTexts: []string{"one", "two"},
+		},
+		{
+			name:      "packed forward mode",
+			entry:     []interface{}{"prod.api", packed, map[string]interface{}{"chunk": "c2", "size": int64(2)}},
+			wantTexts: []string{"one", "two"},
+			wantChunk: "c2",
+		},
+		{
+			name:      "packed forward mode as a string",
+			entry:     []interface{}{"prod.api", string(packed)},
+			wantTexts: []string{"one", "two"},
+		},
+		{
+			name:      "compressed packed forward mode",
+			entry:     []interface{}{"prod.api", gzipped(t, packed), map[string]interface{}{"compressed": "gzip", "chunk": "c3"}},
+			wantTexts: []string{"one", "two"},
+			wantChunk: "c3",
+		},
+		{
+			name:      "record without a message key",
+			entry:     []interface{}{"prod.api", int64(1700000000), map[string]interface{}{"status": int64(500)}},
+			wantTexts: []string{`{"status":500}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := decodeMsgpack(encodeMsgpack(tt.entry), 1<<20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			events, chunk, err := s.entry(v)
+			if err != nil {
+				t.Fatalf("entry: %v", err)
+			}
+			if chunk != tt.wantChunk {
+				t.Errorf("chunk = %q, want %q", chunk, tt.wantChunk)
+			}
+			var texts []string
+			for _, ev := range events {
+				texts = append(texts, ev.Text)
+				if ev.Metadata["tag"] != "prod.api" || ev.Metadata["env"] != "prod" || ev.Metadata["service"] != "api" {
+					t.Errorf("tag metadata = %v", ev.Metadata)
+				}
+			}
+			if !reflect.DeepEqual(texts, tt.wantTexts) {
+				t.Errorf("texts = %q, want %q", texts, tt.wantTexts)
+			}
+		})
+	}
+}
+
+func TestFluentEvent(t *testing.T) {
+	s, err := NewFluentServer(FluentConfig{
+		MetadataKeys: []string{"kubernetes.pod_name=pod", "stream"},
+		TagKey:       "-",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := s.event("app", []interface{}{eventTime(1700000000, 250), map[string]interface{}{
+		"message":    []byte("hello"),
+		"level":      "error",
+		"stream":     "stderr",
+		"other":      "ignored",
+		"kubernetes": map[string]interface{}{"pod_name": []byte("web-1")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Text != "hello" || ev.Level != "error" {
+		t.Errorf("text, level = %q, %q", ev.Text, ev.Level)
+	}
+	if want := time.Unix(1700000000, 250); !ev.Time.Equal(want) {
+		t.Errorf("time = %v, want %v", ev.Time, want)
+	}
+	if want := map[string]interface{}{"pod": "web-1", "stream": "stderr"}; !reflect.DeepEqual(ev.Metadata, want) {
+		t.Errorf("metadata = %v, want %v", ev.Metadata, want)
+	}
+}
+
+func TestFluentEntryErrors(t *testing.T) {
+	s, err := NewFluentServer(FluentConfig{MaxChunkSize: 64}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		entry interface{}
+	}{
+		{"not an array", "tag"},
+		{"message without a record", []interface{}{"tag", int64(1)}},
+		{"record not a map", []interface{}{"tag", int64(1), "text"}},
+		{"forward event not a pair", []interface{}{"tag", []interface{}{int64(1)}}},
+		{"packed garbage", []interface{}{"tag", []byte{0xc1}}},
+		{"bad gzip", []interface{}{"tag", []byte("nope"), map[string]interface{}{"compressed": "gzip"}}},
+		{
+			"gzip bomb",
+			[]interface{}{"tag", gzipped(t, bytes.Repeat([]byte{0xc0}, 1000)), map[string]interface{}{"compressed": "gzip"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := s.entry(tt.entry); err == nil {
+				t.Error("entry succeeded")
+			}
+		})
+	}
+}
+
+func TestFluentServeConn(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	sink := func(_ context.Context, events []ingest.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, ev := range events {
+			got = append(got, ev.Text)
+		}
+		return nil
+	}
+	s, err := NewFluentServer(FluentConfig{MaxChunkSize: 256}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		s.serveConn(context.Background(), server)
+		close(done)
+	}()
+
+	// The chunk is acknowledged once its events are delivered
+	entry := encodeMsgpack([]interface{}{"tag", int64(1), map[string]interface{}{"log": "first"}, map[string]interface{}{"chunk": "abc"}})
+	if _, err := client.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+	ack := make([]byte, 9)
+	if _, err := io.ReadFull(client, ack); err != nil {
+		t.Fatal(err)
+	}
+	v, err := decodeMsgpack(ack, 64)
+	if err != nil || !reflect.DeepEqual(v, map[string]interface{}{"ack": "abc"}) {
+		t.Fatalf("ack = %v, %v", v, err)
+	}
+
+	// An entry larger than MaxChunkSize as a whole ends the connection; on a pipe, an ack
+	// would block until read, so returning proves none was sent
+	big := encodeMsgpack([]interface{}{"tag", int64(1), map[string]interface{}{
+		"log": strings.Repeat("a", 100), "x": strings.Repeat("b", 100), "y": strings.Repeat("c", 100),
+	}, map[string]interface{}{"chunk": "def"}})
+	go client.Write(big)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection still served after an oversized entry")
+	}
+	server.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"first"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
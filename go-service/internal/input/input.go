@@ -0,0 +1,127 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/ingest"
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// Sink takes the events received by an input into the ingestion pipeline. api.IngestEvents
+// implements it by queueing the events, and api.ProcessEvents by storing them before it
+// returns, for inputs that acknowledge what they received.
+type Sink func(ctx context.Context, events []ingest.Event) error
+
+// Drop reasons reported by app_input_dropped_total
+const (
+	reasonQueueFull = "queue_full"
+	reasonClosed    = "closed"
+	reasonStore     = "store_failed"
+	reasonTooLarge  = "too_large"
+	reasonInvalid   = "invalid"
+)
+
+// deliver hands events to the sink. When wait is set, a full queue is retried with
+// backoff until ctx is done, which stalls the sender the way a 429 would; otherwise the
+// events are dropped, as there is nobody to push back on (e.g. UDP).
+func deliver(ctx context.Context, sink Sink, input string, events []ingest.Event, wait bool) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	backoff := 50 * time.Millisecond
+	for {
+		err := sink(ctx, events)
+		if err == nil {
+			metrics.InputEventsTotal.WithLabelValues(input).Add(float64(len(events)))
+			return nil
+		}
+		if !wait || !errors.Is(err, ingest.ErrQueueFull) {
+			reason := dropReason(err)
+			metrics.InputDroppedTotal.WithLabelValues(input, reason).Add(float64(len(events)))
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			metrics.InputDroppedTotal.WithLabelValues(input, reasonClosed).Add(float64(len(events)))
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Second)
+	}
+}
+
+// dropReason tells why the sink refused events: the queue is full or closed, or they were
+// processed inline and could not be stored
+func dropReason(err error) string {
+	switch {
+	case errors.Is(err, ingest.ErrQueueFull):
+		return reasonQueueFull
+	case errors.Is(err, ingest.ErrQueueClosed), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return reasonClosed
+	default:
+		return reasonStore
+	}
+}
+
+// streamListener accepts connections and tracks them, so that they are closed at shutdown
+type streamListener struct {
+	name string
+	l    net.Listener
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+func newStreamListener(name string, l net.Listener) *streamListener {
+	return &streamListener{name: name, l: l, conns: make(map[net.Conn]struct{})}
+}
+
+// serve runs handle for every connection until the listener is closed, then waits for them
+func (s *streamListener) serve(ctx context.Context, handle func(conn net.Conn)) {
+	defer s.wg.Wait()
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("%s accept error: %v", s.name, err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				conn.Close()
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+			}()
+			handle(conn)
+		}()
+	}
+}
+
+// close stops accepting connections and closes the open ones
+func (s *streamListener) close() {
+	s.l.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+}
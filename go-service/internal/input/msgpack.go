@@ -0,0 +1,267 @@
+package input
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// A minimal MessagePack decoder for the Fluent Forward protocol. Values decode to nil,
+// bool, int64, uint64, float64, string, []byte, []interface{}, map[string]interface{}
+// and msgpackExt.
+
+var errMsgpackTooLarge = errors.New("msgpack value too large")
+
+// msgpackExt is an extension value, e.g. the Fluent EventTime (type 0)
+type msgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+type msgpackDecoder struct {
+	r       *bufio.Reader
+	maxSize int // bounds the encoded size of every value returned by decode
+	read    int // bytes of the current value read so far
+}
+
+func newMsgpackDecoder(r *bufio.Reader, maxSize int) *msgpackDecoder {
+	return &msgpackDecoder{r: r, maxSize: maxSize}
+}
+
+// decode reads the next value. Once the value has taken maxSize bytes, whatever the sizes
+// of its parts, errMsgpackTooLarge is returned and the stream is no longer in sync.
+func (d *msgpackDecoder) decode() (interface{}, error) {
+	d.read = 0
+	return d.decodeDepth(0)
+}
+
+// remaining is how many more bytes the current value may take
+func (d *msgpackDecoder) remaining() int {
+	return d.maxSize - d.read
+}
+
+// readByte and readFull read from the stream, counting against the size of the current value
+func (d *msgpackDecoder) readByte() (byte, error) {
+	if d.remaining() <= 0 {
+		return 0, errMsgpackTooLarge
+	}
+	b, err := d.r.ReadByte()
+	if err == nil {
+		d.read++
+	}
+	return b, err
+}
+
+func (d *msgpackDecoder) readFull(buf []byte) error {
+	if len(buf) > d.remaining() {
+		return errMsgpackTooLarge
+	}
+	n, err := io.ReadFull(d.r, buf)
+	d.read += n
+	return err
+}
+
+func (d *msgpackDecoder) decodeDepth(depth int) (interface{}, error) {
+	if depth > 32 {
+		return nil, errors.New("msgpack value nested too deeply")
+	}
+	b, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b >= 0x80 && b <= 0x8f:
+		return d.decodeMap(int(b&0x0f), depth)
+	case b >= 0x90 && b <= 0x9f:
+		return d.decodeArray(int(b&0x0f), depth)
+	case b >= 0xa0 && b <= 0xbf:
+		return d.readString(int(b & 0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readLength(1 << (b - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.readBytes(n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readLength(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.readExt(n)
+	case 0xca:
+		v, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.readUint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := d.readUint(1 << (b - 0xcc))
+		if err == nil && v <= math.MaxInt64 {
+			return int64(v), nil
+		}
+		return v, err
+	case 0xd0:
+		v, err := d.readUint(1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := d.readUint(2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := d.readUint(4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := d.readUint(8)
+		return int64(v), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.readExt(1 << (b - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readLength(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.readString(n)
+	case 0xdc, 0xdd:
+		n, err := d.readLength(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.readLength(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n, depth)
+	}
+	return nil, fmt.Errorf("invalid msgpack type byte 0x%02x", b)
+}
+
+func (d *msgpackDecoder) decodeArray(n, depth int) ([]interface{}, error) {
+	// Every element takes at least a byte
+	if n > d.remaining() {
+		return nil, errMsgpackTooLarge
+	}
+	out := make([]interface{}, 0, min(n, 1024))
+	for i := 0; i < n; i++ {
+		v, err := d.decodeDepth(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n, depth int) (map[string]interface{}, error) {
+	if 2*n > d.remaining() {
+		return nil, errMsgpackTooLarge
+	}
+	out := make(map[string]interface{}, min(n, 1024))
+	for i := 0; i < n; i++ {
+		k, err := d.decodeDepth(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decodeDepth(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		out[msgpackString(k)] = v
+	}
+	return out, nil
+}
+
+func (d *msgpackDecoder) readUint(size int) (uint64, error) {
+	var buf [8]byte
+	if err := d.readFull(buf[:size]); err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range buf[:size] {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (d *msgpackDecoder) readLength(size int) (int, error) {
+	v, err := d.readUint(size)
+	if err != nil {
+		return 0, err
+	}
+	if v > uint64(d.remaining()) {
+		return 0, errMsgpackTooLarge
+	}
+	return int(v), nil
+}
+
+func (d *msgpackDecoder) readBytes(n int) ([]byte, error) {
+	if n > d.remaining() {
+		return nil, errMsgpackTooLarge
+	}
+	buf := make([]byte, n)
+	if err := d.readFull(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (d *msgpackDecoder) readString(n int) (string, error) {
+	b, err := d.readBytes(n)
+	return string(b), err
+}
+
+func (d *msgpackDecoder) readExt(n int) (msgpackExt, error) {
+	t, err := d.readByte()
+	if err != nil {
+		return msgpackExt{}, err
+	}
+	data, err := d.readBytes(n)
+	return msgpackExt{Type: int8(t), Data: data}, err
+}
+
+// msgpackString returns strings and binaries as strings, and formats other values
+func msgpackString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// appendMsgpackString encodes s as a msgpack str
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n < 1<<8:
+		b = append(b, 0xd9, byte(n))
+	case n < 1<<16:
+		b = append(b, 0xda)
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b = append(b, 0xdb)
+		b = binary.BigEndian.AppendUint32(b, uint32(n))
+	}
+	return append(b, s...)
+}
@@ -0,0 +1,193 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// encodeMsgpack encodes the values the decoder produces, for building test streams
+func encodeMsgpack(v interface{}) []byte {
+	var b []byte
+	switch t := v.(type) {
+	case nil:
+		b = append(b, 0xc0)
+	case bool:
+		if t {
+			b = append(b, 0xc3)
+		} else {
+			b = append(b, 0xc2)
+		}
+	case int:
+		b = append(b, 0xd3)
+		b = binary.BigEndian.AppendUint64(b, uint64(t))
+	case int64:
+		b = append(b, 0xd3)
+		b = binary.BigEndian.AppendUint64(b, uint64(t))
+	case float64:
+		b = append(b, 0xcb)
+		b = binary.BigEndian.AppendUint64(b, math.Float64bits(t))
+	case string:
+		b = appendMsgpackString(b, t)
+	case []byte:
+		b = append(b, 0xc6)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(t)))
+		b = append(b, t...)
+	case msgpackExt:
+		b = append(b, 0xc9)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(t.Data)))
+		b = append(b, byte(t.Type))
+		b = append(b, t.Data...)
+	case []interface{}:
+		b = append(b, 0xdd)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(t)))
+		for _, e := range t {
+			b = append(b, encodeMsgpack(e)...)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = append(b, 0xdf)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(t)))
+		for _, k := range keys {
+			b = append(b, encodeMsgpack(k)...)
+			b = append(b, encodeMsgpack(t[k])...)
+		}
+	default:
+		panic(fmt.Sprintf("cannot encode %T", v))
+	}
+	return b
+}
+
+func decodeMsgpack(data []byte, maxSize int) (interface{}, error) {
+	return newMsgpackDecoder(bufio.NewReader(bytes.NewReader(data)), maxSize).decode()
+}
+
+func TestMsgpackDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want interface{}
+	}{
+		{"positive fixint", []byte{0x7f}, int64(127)},
+		{"negative fixint", []byte{0xff}, int64(-1)},
+		{"nil", []byte{0xc0}, nil},
+		{"false", []byte{0xc2}, false},
+		{"true", []byte{0xc3}, true},
+		{"uint8", []byte{0xcc, 0xff}, int64(255)},
+		{"uint16", []byte{0xcd, 0x01, 0x00}, int64(256)},
+		{"uint32", []byte{0xce, 0x00, 0x01, 0x00, 0x00}, int64(65536)},
+		{"uint64 above int64", []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, uint64(math.MaxUint64)},
+		{"int8", []byte{0xd0, 0x80}, int64(-128)},
+		{"int16", []byte{0xd1, 0xff, 0x00}, int64(-256)},
+		{"int32", []byte{0xd2, 0xff, 0xff, 0xff, 0xfe}, int64(-2)},
+		{"int64", []byte{0xd3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfd}, int64(-3)},
+		{"float32", []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}, float64(1.5)},
+		{"float64", encodeMsgpack(2.25), 2.25},
+		{"fixstr", []byte{0xa3, 'a', 'b', 'c'}, "abc"},
+		{"str8", []byte{0xd9, 0x02, 'h', 'i'}, "hi"},
+		{"str16", append([]byte{0xda, 0x00, 0x03}, "xyz"...), "xyz"},
+		{"bin8", []byte{0xc4, 0x02, 0x00, 0x01}, []byte{0x00, 0x01}},
+		{"fixext8", []byte{0xd7, 0x00, 1, 2, 3, 4, 5, 6, 7, 8}, msgpackExt{Type: 0, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}}},
+		{"ext8", []byte{0xc7, 0x01, 0x05, 0xaa}, msgpackExt{Type: 5, Data: []byte{0xaa}}},
+		{"fixarray", []byte{0x92, 0x01, 0xa1, 'x'}, []interface{}{int64(1), "x"}},
+		{"array16", []byte{0xdc, 0x00, 0x01, 0xc0}, []interface{}{nil}},
+		{"fixmap", []byte{0x81, 0xa1, 'k', 0x02}, map[string]interface{}{"k": int64(2)}},
+		{"map with binary and integer keys", []byte{0x82, 0xc4, 0x01, 'b', 0x01, 0x07, 0x02}, map[string]interface{}{"b": int64(1), "7": int64(2)}},
+		{"map32", encodeMsgpack(map[string]interface{}{"a": "b"}), map[string]interface{}{"a": "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeMsgpack(tt.data, 1024)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMsgpackDecodeErrors(t *testing.T) {
+	tooDeep := bytes.Repeat([]byte{0x91}, 40)
+	tests := []struct {
+		name    string
+		data    []byte
+		maxSize int
+		wantErr error // nil accepts any error
+	}{
+		{name: "invalid type byte", data: []byte{0xc1}},
+		{name: "nested too deeply", data: append(tooDeep, 0x00)},
+		{name: "truncated string", data: []byte{0xa5, 'a'}, wantErr: io.ErrUnexpectedEOF},
+		{name: "truncated array", data: []byte{0x92, 0x01}, wantErr: io.EOF},
+		{name: "empty stream", data: nil, wantErr: io.EOF},
+		{name: "string longer than the limit", data: encodeMsgpack(strings.Repeat("x", 20)), maxSize: 16, wantErr: errMsgpackTooLarge},
+		{name: "array longer than the limit", data: []byte{0xdd, 0x00, 0x01, 0x00, 0x00}, maxSize: 1024, wantErr: errMsgpackTooLarge},
+		{name: "map longer than the limit", data: []byte{0xdf, 0x00, 0x00, 0x02, 0x00}, maxSize: 1024, wantErr: errMsgpackTooLarge},
+		{
+			// Every part is below the limit, but not the entry as a whole
+			name:    "parts adding up past the limit",
+			data:    encodeMsgpack([]interface{}{strings.Repeat("a", 10), strings.Repeat("b", 10), strings.Repeat("c", 10)}),
+			maxSize: 32,
+			wantErr: errMsgpackTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxSize := tt.maxSize
+			if maxSize == 0 {
+				maxSize = 1024
+			}
+			_, err := decodeMsgpack(tt.data, maxSize)
+			if err == nil {
+				t.Fatal("decode succeeded")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMsgpackLimitIsPerValue(t *testing.T) {
+	entry := encodeMsgpack([]interface{}{"tag", strings.Repeat("x", 20)})
+	stream := append(append([]byte{}, entry...), entry...)
+
+	// Each value fits exactly, although the stream is twice as large
+	dec := newMsgpackDecoder(bufio.NewReader(bytes.NewReader(stream)), len(entry))
+	for i := 0; i < 2; i++ {
+		if _, err := dec.decode(); err != nil {
+			t.Fatalf("value %d: %v", i, err)
+		}
+	}
+	if _, err := dec.decode(); !errors.Is(err, io.EOF) {
+		t.Fatalf("err = %v, want EOF", err)
+	}
+
+	dec = newMsgpackDecoder(bufio.NewReader(bytes.NewReader(stream)), len(entry)-1)
+	if _, err := dec.decode(); !errors.Is(err, errMsgpackTooLarge) {
+		t.Fatalf("err = %v, want errMsgpackTooLarge", err)
+	}
+}
+
+func TestAppendMsgpackString(t *testing.T) {
+	for _, n := range []int{0, 31, 32, 255, 256, 65535, 65536} {
+		s := strings.Repeat("s", n)
+		got, err := decodeMsgpack(appendMsgpackString(nil, s), 1<<20)
+		if err != nil || got != s {
+			t.Errorf("length %d: round trip = %d bytes, %v", n, len(msgpackString(got)), err)
+		}
+	}
+}
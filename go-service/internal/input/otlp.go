@@ -0,0 +1,262 @@
+package input
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"anomaly-detection-platform/go-service/internal/ingest"
+)
+
+// OTLPEvents maps the log records of an OTLP export request to ingestion events. The body is
+// the log text, service.name and host.name become the service and host metadata, and the
+// other resource and record attributes are kept under resource and attributes.
+func OTLPEvents(req *collogs.ExportLogsServiceRequest) []ingest.Event {
+	var events []ingest.Event
+	for _, rl := range req.GetResourceLogs() {
+		resource := attributeMap(rl.GetResource().GetAttributes())
+		for _, sl := range rl.GetScopeLogs() {
+			scope := sl.GetScope().GetName()
+			for _, lr := range sl.GetLogRecords() {
+				events = append(events, otlpEvent(lr, resource, scope))
+			}
+		}
+	}
+	return events
+}
+
+func otlpEvent(lr *logspb.LogRecord, resource map[string]interface{}, scope string) ingest.Event {
+	ev := ingest.Event{
+		Text:        bodyText(lr.GetBody()),
+		ContentType: "otlp",
+		Level:       lr.GetSeverityText(),
+		Metadata:    make(map[string]interface{}),
+	}
+	if ev.Level == "" {
+		ev.Level = severityLevel(lr.GetSeverityNumber())
+	}
+
+	switch {
+	case lr.GetTimeUnixNano() > 0:
+		ev.Time = time.Unix(0, int64(lr.GetTimeUnixNano()))
+	case lr.GetObservedTimeUnixNano() > 0:
+		ev.Time = time.Unix(0, int64(lr.GetObservedTimeUnixNano()))
+	}
+	if id := lr.GetTraceId(); len(id) > 0 && !allZero(id) {
+		ev.TraceID = hex.EncodeToString(id)
+	}
+	if id := lr.GetSpanId(); len(id) > 0 && !allZero(id) {
+		ev.SpanID = hex.EncodeToString(id)
+	}
+
+	if v, ok := resource["service.name"]; ok {
+		ev.Metadata["service"] = v
+	}
+	if v, ok := resource["host.name"]; ok {
+		ev.Metadata["host"] = v
+	}
+	if len(resource) > 0 {
+		ev.Metadata["resource"] = resource
+	}
+	if attrs := attributeMap(lr.GetAttributes()); len(attrs) > 0 {
+		ev.Metadata["attributes"] = attrs
+	}
+	if scope != "" {
+		ev.Metadata["scope"] = scope
+	}
+	if name := lr.GetEventName(); name != "" {
+		ev.Metadata["event_name"] = name
+	}
+	return ev
+}
+
+// bodyText returns string bodies as they are and other bodies as JSON
+func bodyText(v *commonpb.AnyValue) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.GetValue().(*commonpb.AnyValue_StringValue); ok {
+		return s.StringValue
+	}
+	b, _ := json.Marshal(anyValue(v))
+	return string(b)
+}
+
+func anyValue(v *commonpb.AnyValue) interface{} {
+	switch t := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return t.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return t.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return t.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return t.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(t.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		out := make([]interface{}, len(t.ArrayValue.GetValues()))
+		for i, e := range t.ArrayValue.GetValues() {
+			out[i] = anyValue(e)
+		}
+		return out
+	case *commonpb.AnyValue_KvlistValue:
+		return attributeMap(t.KvlistValue.GetValues())
+	}
+	return nil
+}
+
+func attributeMap(kvs []*commonpb.KeyValue) map[string]interface{} {
+	out := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		out[kv.GetKey()] = anyValue(kv.GetValue())
+	}
+	return out
+}
+
+// severityLevel maps the OTLP severity number ranges to level names
+func severityLevel(n logspb.SeverityNumber) string {
+	switch {
+	case n <= 0:
+		return ""
+	case n <= 4:
+		return "trace"
+	case n <= 8:
+		return "debug"
+	case n <= 12:
+		return "info"
+	case n <= 16:
+		return "warn"
+	case n <= 20:
+		return "error"
+	}
+	return "fatal"
+}
+
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// UnmarshalOTLPJSON decodes an OTLP/JSON request. Unlike the protobuf JSON mapping, OTLP
+// encodes trace and span IDs as hex, so they are converted before decoding.
+func UnmarshalOTLPJSON(b []byte, req *collogs.ExportLogsServiceRequest) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	for _, rl := range jsonList(raw, "resourceLogs", "resource_logs") {
+		for _, sl := range jsonList(rl, "scopeLogs", "scope_logs") {
+			for _, lr := range jsonList(sl, "logRecords", "log_records") {
+				for _, key := range []string{"traceId", "trace_id", "spanId", "span_id"} {
+					s, ok := lr[key].(string)
+					if !ok || s == "" {
+						continue
+					}
+					id, err := hex.DecodeString(s)
+					if err != nil {
+						return fmt.Errorf("invalid %s %q", key, s)
+					}
+					lr[key] = base64.StdEncoding.EncodeToString(id)
+				}
+			}
+		}
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(b, req)
+}
+
+func jsonList(m map[string]interface{}, keys ...string) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, k := range keys {
+		items, _ := m[k].([]interface{})
+		for _, item := range items {
+			if obj, ok := item.(map[string]interface{}); ok {
+				out = append(out, obj)
+			}
+		}
+	}
+	return out
+}
+
+// OTLPServer receives OTLP/gRPC log exports
+type OTLPServer struct {
+	collogs.UnimplementedLogsServiceServer
+
+	addr     string
+	sink     Sink
+	server   *grpc.Server
+	listener net.Listener
+}
+
+// NewOTLPServer creates a gRPC server for the OTLP logs service
+func NewOTLPServer(addr string, sink Sink) *OTLPServer {
+	s := &OTLPServer{addr: addr, sink: sink, server: grpc.NewServer()}
+	collogs.RegisterLogsServiceServer(s.server, s)
+	return s
+}
+
+// Listen binds the gRPC listener
+func (s *OTLPServer) Listen() error {
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for OTLP/gRPC on %s: %w", s.addr, err)
+	}
+	s.listener = l
+	log.Printf("OTLP/gRPC listening on %s", l.Addr())
+	return nil
+}
+
+// Serve handles exports until ctx is done, then lets the running ones finish
+func (s *OTLPServer) Serve(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := s.server.Serve(s.listener); err != nil {
+			log.Printf("OTLP/gRPC server error: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		s.server.Stop()
+	}
+	<-done
+}
+
+// Export implements the OTLP logs service. It answers once the logs are stored, or with
+// UNAVAILABLE, which OTLP exporters retry with backoff, when they could not be.
+func (s *OTLPServer) Export(ctx context.Context, req *collogs.ExportLogsServiceRequest) (*collogs.ExportLogsServiceResponse, error) {
+	if err := deliver(ctx, s.sink, "otlp_grpc", OTLPEvents(req), false); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &collogs.ExportLogsServiceResponse{}, nil
+}
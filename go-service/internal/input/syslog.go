@@ -0,0 +1,300 @@
+package input
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/ingest"
+	"anomaly-detection-platform/go-service/internal/metrics"
+	"anomaly-detection-platform/go-service/internal/parsing"
+)
+
+var (
+	errFrameTooLarge = errors.New("syslog message too large")
+	errBadFraming    = errors.New("invalid syslog octet count")
+)
+
+// SyslogConfig selects the syslog listeners; an empty address disables a listener
+type SyslogConfig struct {
+	UDPAddr string
+	TCPAddr string
+	TLSAddr string
+	TLSCert string // PEM certificate and key of the TLS listener
+	TLSKey  string
+
+	MaxMessageSize int            // larger messages are dropped, default 64KiB
+	IdleTimeout    time.Duration  // TCP connections silent for longer are closed, default 5m
+	Location       *time.Location // zone of RFC 3164 timestamps, default UTC
+}
+
+// SyslogServer receives RFC 3164 and RFC 5424 messages over UDP, TCP and TLS. TCP streams
+// may use octet counting or newline framing (RFC 6587), detected per message.
+type SyslogServer struct {
+	cfg       SyslogConfig
+	sink      Sink
+	parser    *parsing.Parser
+	tlsConfig *tls.Config
+
+	udp       net.PacketConn
+	listeners []*streamListener
+	wg        sync.WaitGroup
+}
+
+// NewSyslogServer checks the configuration and loads the TLS certificate
+func NewSyslogServer(cfg SyslogConfig, sink Sink) (*SyslogServer, error) {
+	if cfg.MaxMessageSize <= 0 {
+		cfg.MaxMessageSize = 64 << 10
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 5 * time.Minute
+	}
+	parser, err := parsing.NewParser(parsing.Config{
+		Formats:  []string{parsing.FormatRFC5424, parsing.FormatRFC3164},
+		Location: cfg.Location,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SyslogServer{
+		cfg:    cfg,
+		sink:   sink,
+		parser: parser,
+	}
+	if cfg.TLSAddr != "" {
+		if cfg.TLSCert == "" || cfg.TLSKey == "" {
+			return nil, errors.New("the syslog TLS listener needs a certificate and a key")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load syslog TLS certificate: %w", err)
+		}
+		s.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	return s, nil
+}
+
+// Listen binds the configured listeners, so that a busy port fails at startup
+func (s *SyslogServer) Listen() error {
+	var err error
+	if s.cfg.UDPAddr != "" {
+		if s.udp, err = net.ListenPacket("udp", s.cfg.UDPAddr); err != nil {
+			s.close()
+			return fmt.Errorf("failed to listen for syslog on udp %s: %w", s.cfg.UDPAddr, err)
+		}
+		log.Printf("Syslog listening on udp %s", s.udp.LocalAddr())
+	}
+	if s.cfg.TCPAddr != "" {
+		l, err := net.Listen("tcp", s.cfg.TCPAddr)
+		if err != nil {
+			s.close()
+			return fmt.Errorf("failed to listen for syslog on tcp %s: %w", s.cfg.TCPAddr, err)
+		}
+		s.listeners = append(s.listeners, newStreamListener("syslog_tcp", l))
+		log.Printf("Syslog listening on tcp %s", l.Addr())
+	}
+	if s.cfg.TLSAddr != "" {
+		l, err := tls.Listen("tcp", s.cfg.TLSAddr, s.tlsConfig)
+		if err != nil {
+			s.close()
+			return fmt.Errorf("failed to listen for syslog on tls %s: %w", s.cfg.TLSAddr, err)
+		}
+		s.listeners = append(s.listeners, newStreamListener("syslog_tls", l))
+		log.Printf("Syslog listening on tls %s", l.Addr())
+	}
+	return nil
+}
+
+// Serve receives messages until ctx is done, then closes the listeners and connections
+func (s *SyslogServer) Serve(ctx context.Context) {
+	if s.udp != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveUDP(ctx)
+		}()
+	}
+	for _, l := range s.listeners {
+		s.wg.Add(1)
+		go func(l *streamListener) {
+			defer s.wg.Done()
+			l.serve(ctx, func(conn net.Conn) { s.serveConn(ctx, l.name, conn) })
+		}(l)
+	}
+
+	<-ctx.Done()
+	s.close()
+	s.wg.Wait()
+}
+
+func (s *SyslogServer) close() {
+	if s.udp != nil {
+		s.udp.Close()
+	}
+	for _, l := range s.listeners {
+		l.close()
+	}
+}
+
+func (s *SyslogServer) serveUDP(ctx context.Context) {
+	buf := make([]byte, 64<<10)
+	for {
+		n, _, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Syslog UDP read error: %v", err)
+			continue
+		}
+		if n > s.cfg.MaxMessageSize {
+			metrics.InputDroppedTotal.WithLabelValues("syslog_udp", reasonTooLarge).Inc()
+			continue
+		}
+
+		// One datagram is one message; nobody can be slowed down, so a full queue drops it
+		if ev, ok := s.event(string(buf[:n]), "udp"); ok {
+			deliver(ctx, s.sink, "syslog_udp", []ingest.Event{ev}, false)
+		}
+	}
+}
+
+func (s *SyslogServer) serveConn(ctx context.Context, name string, conn net.Conn) {
+	transport := strings.TrimPrefix(name, "syslog_")
+	r := bufio.NewReaderSize(conn, 16<<10)
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.cfg.IdleTimeout))
+		raw, err := readFrame(r, s.cfg.MaxMessageSize)
+		switch {
+		case errors.Is(err, errFrameTooLarge):
+			metrics.InputDroppedTotal.WithLabelValues(name, reasonTooLarge).Inc()
+			continue
+		case errors.Is(err, errBadFraming):
+			// The stream cannot be resynchronized
+			metrics.InputDroppedTotal.WithLabelValues(name, reasonInvalid).Inc()
+			log.Printf("Closing syslog connection from %s: %v", conn.RemoteAddr(), err)
+			return
+		case err != nil:
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) && ctx.Err() == nil {
+				log.Printf("Syslog connection from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		// A full queue stops reading from the connection, pushing back on the sender
+		if ev, ok := s.event(raw, transport); ok {
+			if err := deliver(ctx, s.sink, name, []ingest.Event{ev}, true); err != nil && ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
+
+// readFrame reads one message, octet-counted ("<len> <msg>") when it starts with a digit
+// and newline-terminated otherwise. Oversized messages are skipped with errFrameTooLarge.
+func readFrame(r *bufio.Reader, maxSize int) (string, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+
+	if b[0] >= '0' && b[0] <= '9' {
+		n := 0
+		for digits := 0; ; digits++ {
+			c, err := r.ReadByte()
+			if err != nil {
+				return "", err
+			}
+			if c == ' ' && digits > 0 {
+				break
+			}
+			if c < '0' || c > '9' || digits == 9 {
+				return "", errBadFraming
+			}
+			n = n*10 + int(c-'0')
+		}
+		if n > maxSize {
+			if _, err := r.Discard(n); err != nil {
+				return "", err
+			}
+			return "", errFrameTooLarge
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+
+	var line []byte
+	tooLarge := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxSize+2 { // allow for the \r\n terminator
+			tooLarge = true
+			line = line[:0]
+		} else if !tooLarge {
+			line = append(line, chunk...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil && (!errors.Is(err, io.EOF) || len(line) == 0 || tooLarge) {
+			return "", err
+		}
+		break
+	}
+	if tooLarge {
+		return "", errFrameTooLarge
+	}
+	return string(line), nil
+}
+
+// event maps a syslog message to an ingestion event, with the header fields in metadata.
+// Messages that are neither RFC 5424 nor RFC 3164 are kept whole.
+func (s *SyslogServer) event(raw, transport string) (ingest.Event, bool) {
+	raw = strings.TrimRight(raw, "\r\n\x00")
+	if strings.TrimSpace(raw) == "" {
+		return ingest.Event{}, false
+	}
+
+	ev := ingest.Event{
+		Text:        raw,
+		ContentType: "syslog",
+		Metadata:    map[string]interface{}{"syslog_transport": transport},
+	}
+	m, ok := s.parser.ParseSyslog(raw, time.Now())
+	if !ok {
+		return ev, true
+	}
+
+	ev.Text = m.Message
+	ev.Time = m.Time
+	ev.Level = m.Severity()
+	ev.Metadata["syslog_format"] = m.Format
+	setMetadata(ev.Metadata, "hostname", m.Hostname)
+	setMetadata(ev.Metadata, "app_name", m.AppName)
+	setMetadata(ev.Metadata, "procid", m.ProcID)
+	setMetadata(ev.Metadata, "msgid", m.MsgID)
+	setMetadata(ev.Metadata, "severity", m.Severity())
+	setMetadata(ev.Metadata, "facility", m.Facility())
+	if len(m.StructuredData) > 0 {
+		ev.Metadata["structured_data"] = m.StructuredData
+	}
+	return ev, true
+}
+
+func setMetadata(metadata map[string]interface{}, key, value string) {
+	if value != "" {
+		metadata[key] = value
+	}
+}
@@ -0,0 +1,238 @@
+package input
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"anomaly-detection-platform/go-service/internal/ingest"
+)
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		maxSize int
+		want    []string
+		wantErr error // returned after the frames of want
+	}{
+		{
+			name:   "octet counting",
+			stream: "11 hello world5 again",
+			want:   []string{"hello world", "again"},
+		},
+		{
+			name:   "octet counted frames may hold line breaks",
+			stream: "6 a\nb\r\nc",
+			want:   []string{"a\nb\r\nc"},
+		},
+		{
+			name:   "newline framing",
+			stream: "<13>first\n<13>second\r\n",
+			want:   []string{"<13>first\n", "<13>second\r\n"},
+		},
+		{
+			name:   "last line without a newline",
+			stream: "<13>first\nno newline",
+			want:   []string{"<13>first\n", "no newline"},
+		},
+		{
+			name:   "framing detected per message",
+			stream: "3 one<13>two\n3 six",
+			want:   []string{"one", "<13>two\n", "six"},
+		},
+		{
+			name:    "oversized octet counted frame is skipped",
+			stream:  "10 0123456789",
+			maxSize: 5,
+			wantErr: errFrameTooLarge,
+		},
+		{
+			name:    "oversized line is skipped",
+			stream:  strings.Repeat("x", 40) + "\n",
+			maxSize: 20,
+			wantErr: errFrameTooLarge,
+		},
+		{
+			name:    "non digit in the count",
+			stream:  "12x hello",
+			wantErr: errBadFraming,
+		},
+		{
+			name:    "count too long",
+			stream:  "1234567890 hello",
+			wantErr: errBadFraming,
+		},
+		{
+			name:    "truncated octet counted frame",
+			stream:  "20 short",
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "empty stream",
+			wantErr: io.EOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxSize := tt.maxSize
+			if maxSize == 0 {
+				maxSize = 1024
+			}
+			// A small buffer exercises lines longer than the buffer
+			r := bufio.NewReaderSize(strings.NewReader(tt.stream), 16)
+
+			var got []string
+			var err error
+			for {
+				var frame string
+				if frame, err = readFrame(r, maxSize); err != nil {
+					break
+				}
+				got = append(got, frame)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("frames = %q, want %q", got, tt.want)
+			}
+			wantErr := tt.wantErr
+			if wantErr == nil {
+				wantErr = io.EOF
+			}
+			if !errors.Is(err, wantErr) {
+				t.Errorf("err = %v, want %v", err, wantErr)
+			}
+		})
+	}
+}
+
+func TestReadFrameResumesAfterOversizedFrame(t *testing.T) {
+	r := bufio.NewReaderSize(strings.NewReader("10 01234567892 ok"+strings.Repeat("y", 30)+"\nlast\n"), 16)
+	want := []struct {
+		frame string
+		err   error
+	}{
+		{"", errFrameTooLarge},
+		{"ok", nil},
+		{"", errFrameTooLarge},
+		{"last\n", nil},
+	}
+	for i, w := range want {
+		frame, err := readFrame(r, 8)
+		if frame != w.frame || !errors.Is(err, w.err) {
+			t.Errorf("frame %d = %q, %v, want %q, %v", i, frame, err, w.frame, w.err)
+		}
+	}
+}
+
+func TestSyslogEvent(t *testing.T) {
+	s, err := NewSyslogServer(SyslogConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		raw      string
+		wantText string
+		wantMeta map[string]interface{}
+	}{
+		{
+			name:     "RFC 5424",
+			raw:      `<165>1 2024-03-01T10:00:00Z web01 nginx 42 ID7 [req@1 id="a\"b"] upstream timed out` + "\n",
+			wantText: "upstream timed out",
+			wantMeta: map[string]interface{}{
+				"syslog_transport": "tcp",
+				"syslog_format":    "rfc5424",
+				"hostname":         "web01",
+				"app_name":         "nginx",
+				"procid":           "42",
+				"msgid":            "ID7",
+				"severity":         "notice",
+				"facility":         "local4",
+				"structured_data":  map[string]string{"req@1.id": `a"b`},
+			},
+		},
+		{
+			name:     "RFC 3164",
+			raw:      "<11>Mar  1 10:00:00 db01 postgres[77]: connection reset\r\n",
+			wantText: "connection reset",
+			wantMeta: map[string]interface{}{
+				"syslog_transport": "tcp",
+				"syslog_format":    "rfc3164",
+				"hostname":         "db01",
+				"app_name":         "postgres",
+				"procid":           "77",
+				"severity":         "err",
+				"facility":         "user",
+			},
+		},
+		{
+			name:     "not syslog",
+			raw:      "plain text line\n",
+			wantText: "plain text line",
+			wantMeta: map[string]interface{}{"syslog_transport": "tcp"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev, ok := s.event(tt.raw, "tcp")
+			if !ok {
+				t.Fatal("event dropped")
+			}
+			if ev.Text != tt.wantText {
+				t.Errorf("text = %q, want %q", ev.Text, tt.wantText)
+			}
+			if !reflect.DeepEqual(ev.Metadata, tt.wantMeta) {
+				t.Errorf("metadata = %v, want %v", ev.Metadata, tt.wantMeta)
+			}
+		})
+	}
+
+	if _, ok := s.event(" \r\n", "udp"); ok {
+		t.Error("blank message was not dropped")
+	}
+}
+
+func TestSyslogServeConn(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	sink := func(_ context.Context, events []ingest.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, ev := range events {
+			got = append(got, ev.Text)
+		}
+		return nil
+	}
+	s, err := NewSyslogServer(SyslogConfig{}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		s.serveConn(context.Background(), "syslog_tcp", server)
+		close(done)
+	}()
+
+	frames := "<14>Mar  1 10:00:00 h app: one\n" + "30 <14>Mar  1 10:00:00 h app: two"
+	if _, err := io.WriteString(client, frames); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"one", "two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
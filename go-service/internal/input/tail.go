@@ -0,0 +1,365 @@
+package input
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/ingest"
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// TailConfig selects the files to follow and where their read offsets are kept
+type TailConfig struct {
+	// Paths are glob patterns (filepath.Match syntax, no **), matched again at every poll
+	Paths []string
+	// CheckpointFile keeps the read offset of every file across restarts; empty keeps them in memory only
+	CheckpointFile string
+	// ReadFromHead reads the files found at the first start from the beginning rather than
+	// from their end; files appearing later, e.g. after a rotation, are always read whole
+	ReadFromHead bool
+
+	PollInterval time.Duration // default 1s
+	MaxLineSize  int           // longer lines are dropped, default 64KiB
+}
+
+// fileID identifies a file across renames; it is zero where the platform has no inodes
+type fileID struct {
+	Dev   uint64 `json:"dev,omitempty"`
+	Inode uint64 `json:"inode,omitempty"`
+}
+
+type checkpoint struct {
+	Path string `json:"path"`
+	fileID
+	Offset int64 `json:"offset"`
+}
+
+type tailedFile struct {
+	path   string
+	id     fileID
+	f      *os.File
+	offset int64 // end of the last line handed to the sink
+	moved  bool  // the path now names another file or none; the file is closed once read to its end
+	skip   bool  // inside a line longer than MaxLineSize
+}
+
+// FileTailer follows local files, handing every line to the pipeline with its path in
+// the file_path metadata. Files are polled rather than watched. A rotation by rename is
+// detected by the new file behind the path, the old one being read to its end first; a
+// copytruncate rotation by the file shrinking below the read offset.
+type FileTailer struct {
+	cfg  TailConfig
+	sink Sink
+
+	files  []*tailedFile
+	saved  map[string]checkpoint // loaded checkpoints, by identity or path
+	loaded bool                  // a checkpoint file existed at startup
+	first  bool
+	dirty  bool
+}
+
+// NewFileTailer checks the patterns and loads the checkpoint file
+func NewFileTailer(cfg TailConfig, sink Sink) (*FileTailer, error) {
+	if len(cfg.Paths) == 0 {
+		return nil, errors.New("no file patterns to tail")
+	}
+	for _, p := range cfg.Paths {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid tail pattern %q: %w", p, err)
+		}
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.MaxLineSize <= 0 {
+		cfg.MaxLineSize = 64 << 10
+	}
+
+	t := &FileTailer{cfg: cfg, sink: sink, saved: make(map[string]checkpoint), first: true}
+	if cfg.CheckpointFile != "" {
+		b, err := os.ReadFile(cfg.CheckpointFile)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read tail checkpoints: %w", err)
+		default:
+			var cps []checkpoint
+			if err := json.Unmarshal(b, &cps); err != nil {
+				return nil, fmt.Errorf("invalid tail checkpoint file %s: %w", cfg.CheckpointFile, err)
+			}
+			for _, cp := range cps {
+				t.saved[checkpointKey(cp.Path, cp.fileID)] = cp
+			}
+			t.loaded = true
+		}
+	}
+	return t, nil
+}
+
+// checkpointKey matches files by identity where there is one, so that a file rotated
+// while the service was down resumes under its new name
+func checkpointKey(path string, id fileID) string {
+	if id != (fileID{}) {
+		return fmt.Sprintf("%d:%d", id.Dev, id.Inode)
+	}
+	return path
+}
+
+// Serve polls the files until ctx is done, then saves the checkpoints and closes them
+func (t *FileTailer) Serve(ctx context.Context) {
+	log.Printf("Tailing files matching %v", t.cfg.Paths)
+	ticker := time.NewTicker(t.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		t.poll(ctx)
+		select {
+		case <-ctx.Done():
+			t.saveCheckpoints()
+			for _, tf := range t.files {
+				tf.f.Close()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *FileTailer) poll(ctx context.Context) {
+	t.scan()
+	for _, tf := range t.files {
+		if ctx.Err() != nil {
+			break
+		}
+		t.read(ctx, tf)
+	}
+
+	// Moved files are dropped once read to their end
+	kept := t.files[:0]
+	for _, tf := range t.files {
+		if tf.moved && ctx.Err() == nil {
+			if fi, err := tf.f.Stat(); err == nil && tf.offset >= fi.Size() {
+				tf.f.Close()
+				t.dirty = true
+				continue
+			}
+		}
+		kept = append(kept, tf)
+	}
+	t.files = kept
+	t.first = false
+
+	if t.dirty {
+		t.saveCheckpoints()
+	}
+}
+
+// scan matches the patterns, opening new files and noticing rotations
+func (t *FileTailer) scan() {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, pattern := range t.cfg.Paths {
+		matches, _ := filepath.Glob(pattern)
+		for _, p := range matches {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	current := make(map[string]fileID, len(paths))
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		id := identity(fi)
+		current[p] = id
+		if t.tracked(p, id) {
+			continue
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			log.Printf("Failed to open %s for tailing: %v", p, err)
+			continue
+		}
+		tf := &tailedFile{path: p, id: id, f: f, offset: t.startOffset(p, id, fi.Size())}
+		t.files = append(t.files, tf)
+		t.dirty = true
+	}
+
+	for _, tf := range t.files {
+		if id, ok := current[tf.path]; !ok || (id != tf.id && tf.id != (fileID{})) {
+			tf.moved = true
+		}
+	}
+}
+
+// tracked reports whether the file at path is already followed, updating the path of a
+// file that was renamed to it
+func (t *FileTailer) tracked(path string, id fileID) bool {
+	for _, tf := range t.files {
+		if id != (fileID{}) && tf.id == id {
+			tf.path = path
+			tf.moved = false
+			return true
+		}
+		if id == (fileID{}) && tf.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *FileTailer) startOffset(path string, id fileID, size int64) int64 {
+	if cp, ok := t.saved[checkpointKey(path, id)]; ok {
+		delete(t.saved, checkpointKey(path, id))
+		if cp.Offset <= size {
+			return cp.Offset
+		}
+		return 0 // truncated while the service was down
+	}
+	// Without a checkpoint, only the files present at the very first start may be skipped
+	if t.first && !t.loaded && !t.cfg.ReadFromHead {
+		return size
+	}
+	return 0
+}
+
+// read hands the complete lines past the offset of tf to the sink, in batches
+func (t *FileTailer) read(ctx context.Context, tf *tailedFile) {
+	fi, err := tf.f.Stat()
+	if err != nil {
+		return
+	}
+	if fi.Size() < tf.offset {
+		// copytruncate: the file was emptied in place
+		tf.offset = 0
+		tf.skip = false
+		t.dirty = true
+	}
+	if fi.Size() == tf.offset {
+		return
+	}
+
+	r := io.NewSectionReader(tf.f, tf.offset, fi.Size()-tf.offset)
+	buf := make([]byte, 0, 64<<10)
+	chunk := make([]byte, 64<<10)
+	var events []ingest.Event
+	pos := tf.offset     // start of buf in the file
+	consumed := int64(0) // bytes of buf turned into events or skipped
+
+	flush := func() bool {
+		if len(events) > 0 {
+			if err := deliver(ctx, t.sink, "file", events, true); err != nil {
+				return false
+			}
+			events = nil
+		}
+		tf.offset = pos + consumed
+		t.dirty = true
+		return true
+	}
+
+	for {
+		n, err := r.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+
+		for {
+			i := bytes.IndexByte(buf[consumed:], '\n')
+			if i < 0 {
+				break
+			}
+			line := buf[consumed : consumed+int64(i)]
+			consumed += int64(i) + 1
+			if tf.skip {
+				tf.skip = false
+				continue
+			}
+			if len(line) > t.cfg.MaxLineSize {
+				metrics.InputDroppedTotal.WithLabelValues("file", reasonTooLarge).Inc()
+				continue
+			}
+			if ev, ok := t.event(tf.path, line); ok {
+				events = append(events, ev)
+			}
+			if len(events) >= 500 && !flush() {
+				return
+			}
+		}
+
+		// A partial line longer than the limit is dropped up to its newline
+		if rest := int64(len(buf)) - consumed; rest > int64(t.cfg.MaxLineSize) {
+			if !tf.skip {
+				metrics.InputDroppedTotal.WithLabelValues("file", reasonTooLarge).Inc()
+			}
+			tf.skip = true
+			consumed += rest
+		}
+		if !flush() {
+			return
+		}
+		pos += consumed
+		buf = append(buf[:0], buf[consumed:]...)
+		consumed = 0
+
+		if err != nil {
+			break
+		}
+	}
+
+	// The last line of a file that was rotated away will not be completed
+	if tf.moved && len(buf) > 0 && !tf.skip {
+		if ev, ok := t.event(tf.path, buf); ok {
+			events = append(events, ev)
+		}
+		consumed = int64(len(buf))
+		flush()
+	}
+}
+
+func (t *FileTailer) event(path string, line []byte) (ingest.Event, bool) {
+	text := strings.TrimRight(string(line), "\r")
+	if strings.TrimSpace(text) == "" {
+		return ingest.Event{}, false
+	}
+	return ingest.Event{
+		Text:        text,
+		ContentType: "file",
+		Metadata:    map[string]interface{}{"file_path": path},
+	}, true
+}
+
+// saveCheckpoints writes the offsets of the followed files, replacing the file atomically
+func (t *FileTailer) saveCheckpoints() {
+	t.dirty = false
+	if t.cfg.CheckpointFile == "" {
+		return
+	}
+	cps := make([]checkpoint, 0, len(t.files))
+	for _, tf := range t.files {
+		cps = append(cps, checkpoint{Path: tf.path, fileID: tf.id, Offset: tf.offset})
+	}
+	b, _ := json.MarshalIndent(cps, "", "  ")
+
+	tmp := t.cfg.CheckpointFile + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		log.Printf("Failed to save tail checkpoints: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, t.cfg.CheckpointFile); err != nil {
+		log.Printf("Failed to save tail checkpoints: %v", err)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package input
+
+import "os"
+
+// identity is unknown without inodes; files are then followed by path only, and only
+// copytruncate rotation is detected
+func identity(fi os.FileInfo) fileID {
+	return fileID{}
+}
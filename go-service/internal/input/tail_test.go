@@ -0,0 +1,145 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"anomaly-detection-platform/go-service/internal/ingest"
+)
+
+// lineSink collects the text of the events it receives and fails while err is set
+type lineSink struct {
+	lines []string
+	err   error
+}
+
+func (s *lineSink) sink(_ context.Context, events []ingest.Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, ev := range events {
+		s.lines = append(s.lines, ev.Text)
+	}
+	return nil
+}
+
+// take returns the lines received since the last call
+func (s *lineSink) take() []string {
+	out := s.lines
+	s.lines = nil
+	return out
+}
+
+func newTailer(t *testing.T, cfg TailConfig, s *lineSink) *FileTailer {
+	t.Helper()
+	tl, err := NewFileTailer(cfg, s.sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, tf := range tl.files {
+			tf.f.Close()
+		}
+	})
+	return tl
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantLines(t *testing.T, step string, got []string, want ...string) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s: read %q, want %q", step, got, want)
+	}
+}
+
+func TestTailRenameRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "one\n")
+	if fi, _ := os.Stat(path); identity(fi) == (fileID{}) {
+		t.Skip("files have no identity on this platform")
+	}
+
+	s := &lineSink{}
+	tl := newTailer(t, TailConfig{Paths: []string{filepath.Join(dir, "*.log")}, ReadFromHead: true}, s)
+	tl.poll(context.Background())
+	wantLines(t, "first poll", s.take(), "one")
+
+	// The writer still appends to the renamed file before reopening the path
+	if err := os.Rename(path, filepath.Join(dir, "app.log.1")); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, filepath.Join(dir, "app.log.1"), "two\nthree without newline")
+	appendFile(t, path, "four\n")
+	tl.poll(context.Background())
+	wantLines(t, "after the rotation", s.take(), "two", "three without newline", "four")
+	if len(tl.files) != 1 || tl.files[0].path != path {
+		t.Errorf("still following %d files after the rotation", len(tl.files))
+	}
+
+	appendFile(t, path, "five\n")
+	tl.poll(context.Background())
+	wantLines(t, "new file", s.take(), "five")
+}
+
+func TestTailCopyTruncate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "a fairly long first line\nand a second one\n")
+
+	s := &lineSink{}
+	tl := newTailer(t, TailConfig{Paths: []string{path}, ReadFromHead: true}, s)
+	tl.poll(context.Background())
+	wantLines(t, "first poll", s.take(), "a fairly long first line", "and a second one")
+
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "new\n")
+	tl.poll(context.Background())
+	wantLines(t, "after the truncation", s.take(), "new")
+}
+
+func TestTailCheckpointResume(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	cfg := TailConfig{Paths: []string{path}, CheckpointFile: filepath.Join(dir, "tail.json")}
+	appendFile(t, path, "before the first start\n")
+
+	// Without a checkpoint, existing content is skipped
+	s := &lineSink{}
+	tl := newTailer(t, cfg, s)
+	tl.poll(context.Background())
+	wantLines(t, "first start", s.take())
+
+	appendFile(t, path, "one\n")
+	tl.poll(context.Background())
+	wantLines(t, "first run", s.take(), "one")
+
+	// Lines that could not be stored are read again rather than skipped
+	appendFile(t, path, "two\n")
+	s.err = errors.New("elasticsearch unavailable")
+	tl.poll(context.Background())
+	tl.saveCheckpoints()
+	appendFile(t, path, "three\n")
+
+	s = &lineSink{}
+	tl = newTailer(t, cfg, s)
+	tl.poll(context.Background())
+	wantLines(t, "after the restart", s.take(), "two", "three")
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package input
+
+import (
+	"os"
+	"syscall"
+)
+
+func identity(fi os.FileInfo) fileID {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return fileID{Dev: uint64(st.Dev), Inode: uint64(st.Ino)}
+	}
+	return fileID{}
+}
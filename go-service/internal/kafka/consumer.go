@@ -0,0 +1,198 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"anomaly-detection-platform/go-service/internal/ingest"
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// Value formats of consumed records
+const (
+	FormatJSON = "json" // {"text": "...", "metadata": {...}}, as accepted by POST /v1/logs
+	FormatRaw  = "raw"  // the whole value is the log text
+)
+
+// Processor runs events through the pipeline and returns the function storing them, which
+// returns once they are stored; an error means some were not, and only the storing is
+// tried again, so that alerts, incidents and published results are not repeated.
+// api.ProcessBatch implements it.
+type Processor func(ctx context.Context, events []ingest.Event) (store func(context.Context) error)
+
+// ConsumerConfig selects the topics to consume and how record values are decoded
+type ConsumerConfig struct {
+	Config
+
+	Topics         []string
+	Group          string // consumer group, default go-service
+	ValueFormat    string // FormatJSON (default) or FormatRaw
+	MaxPollRecords int    // records processed per batch, default 500
+}
+
+// Consumer reads logs from Kafka as a member of a consumer group. Offsets are committed
+// once the records of a poll have been processed and stored, so a crash or a rebalance
+// redelivers them (at-least-once) and they are processed again. Log IDs derive from the
+// record offset: a redelivered log replaces its earlier copy when it is written to the same
+// index, but both are kept when the log series rolled over in between or the log was
+// classified differently, as anomalies and normal logs go to different series.
+type Consumer struct {
+	cfg     ConsumerConfig
+	process Processor
+	client  *kgo.Client
+}
+
+// NewConsumer checks the configuration and creates the group client; brokers are only
+// contacted once Serve runs
+func NewConsumer(cfg ConsumerConfig, process Processor) (*Consumer, error) {
+	if len(cfg.Topics) == 0 {
+		return nil, errors.New("no kafka topics configured")
+	}
+	if cfg.Group == "" {
+		cfg.Group = "go-service"
+	}
+	switch cfg.ValueFormat {
+	case "":
+		cfg.ValueFormat = FormatJSON
+	case FormatJSON, FormatRaw:
+	default:
+		return nil, fmt.Errorf("unknown kafka value format %q", cfg.ValueFormat)
+	}
+	if cfg.MaxPollRecords <= 0 {
+		cfg.MaxPollRecords = 500
+	}
+
+	opts, err := cfg.opts()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts,
+		kgo.ConsumerGroup(cfg.Group),
+		kgo.ConsumeTopics(cfg.Topics...),
+		kgo.DisableAutoCommit(),
+		// Partitions are not revoked while a batch is in flight, so its offsets can still be committed
+		kgo.BlockRebalanceOnPoll(),
+	)
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka consumer: %w", err)
+	}
+	return &Consumer{cfg: cfg, process: process, client: client}, nil
+}
+
+// Serve consumes until ctx is done, then leaves the group. A batch interrupted by the
+// shutdown is not committed and is consumed again by the next group member.
+func (c *Consumer) Serve(ctx context.Context) {
+	defer c.client.CloseAllowingRebalance()
+	log.Printf("Kafka consumer joining group %s for topics %v", c.cfg.Group, c.cfg.Topics)
+
+	for {
+		fetches := c.client.PollRecords(ctx, c.cfg.MaxPollRecords)
+		if fetches.IsClientClosed() || ctx.Err() != nil {
+			return
+		}
+		fetches.EachError(func(topic string, partition int32, err error) {
+			log.Printf("Kafka fetch error on %s/%d: %v", topic, partition, err)
+		})
+
+		records := fetches.Records()
+		if len(records) == 0 {
+			c.client.AllowRebalance()
+			if len(fetches.Errors()) > 0 {
+				sleep(ctx, time.Second)
+			}
+			continue
+		}
+
+		events := make([]ingest.Event, 0, len(records))
+		for _, r := range records {
+			ev, ok := c.event(r)
+			if !ok {
+				metrics.InputDroppedTotal.WithLabelValues("kafka", "invalid").Inc()
+				continue
+			}
+			events = append(events, ev)
+		}
+		if len(events) > 0 && !c.storeWithRetry(ctx, len(events), c.process(ctx, events)) {
+			return
+		}
+		metrics.InputEventsTotal.WithLabelValues("kafka").Add(float64(len(events)))
+
+		if err := c.client.CommitRecords(ctx, records...); err != nil && ctx.Err() == nil {
+			// The records are consumed again after the next rebalance
+			log.Printf("Kafka offset commit failed: %v", err)
+		}
+		c.client.AllowRebalance()
+	}
+}
+
+// storeWithRetry calls store until the processed events are stored, backing off between
+// attempts; it returns false when ctx is done first
+func (c *Consumer) storeWithRetry(ctx context.Context, n int, store func(context.Context) error) bool {
+	backoff := 200 * time.Millisecond
+	for {
+		err := store(ctx)
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		log.Printf("Storing a Kafka batch of %d logs failed, retrying in %s: %v", n, backoff, err)
+		if !sleep(ctx, backoff) {
+			return false
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// event decodes a record value; JSON values without text are skipped
+func (c *Consumer) event(r *kgo.Record) (ingest.Event, bool) {
+	ev := ingest.Event{
+		ID:          fmt.Sprintf("kafka-%s-%d-%d", r.Topic, r.Partition, r.Offset),
+		ContentType: "kafka",
+	}
+
+	if c.cfg.ValueFormat == FormatRaw {
+		ev.Text = string(r.Value)
+	} else {
+		var req struct {
+			Text     string                 `json:"text"`
+			Metadata map[string]interface{} `json:"metadata"`
+		}
+		if err := json.Unmarshal(r.Value, &req); err != nil || req.Text == "" {
+			return ingest.Event{}, false
+		}
+		ev.Text = req.Text
+		ev.Metadata = req.Metadata
+	}
+	if ev.Text == "" {
+		return ingest.Event{}, false
+	}
+
+	if ev.Metadata == nil {
+		ev.Metadata = make(map[string]interface{})
+	}
+	ev.Metadata["kafka_topic"] = r.Topic
+	ev.Metadata["kafka_partition"] = r.Partition
+	ev.Metadata["kafka_offset"] = r.Offset
+	if len(r.Key) > 0 {
+		ev.Metadata["kafka_key"] = string(r.Key)
+	}
+	return ev, true
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
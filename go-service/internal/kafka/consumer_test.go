@@ -0,0 +1,272 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"github.com/twmb/franz-go/pkg/kversion"
+
+	"anomaly-detection-platform/go-service/internal/ingest"
+)
+
+const testTopic = "logs"
+
+// newCluster starts an in-process Kafka cluster holding a one partition topic
+func newCluster(t *testing.T) []string {
+	t.Helper()
+	c, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, testTopic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c.ListenAddrs()
+}
+
+func newClient(t *testing.T, brokers []string, opts ...kgo.Opt) *kgo.Client {
+	t.Helper()
+	cl, err := kgo.NewClient(append([]kgo.Opt{kgo.SeedBrokers(brokers...)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cl.Close)
+	return cl
+}
+
+func produce(t *testing.T, brokers []string, values ...string) {
+	t.Helper()
+	cl := newClient(t, brokers, kgo.DefaultProduceTopic(testTopic))
+	for _, v := range values {
+		if err := cl.ProduceSync(context.Background(), &kgo.Record{Value: []byte(v)}).FirstErr(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// committedOffset returns the offset the group committed for the partition, -1 if none
+func committedOffset(t *testing.T, brokers []string, group string) int64 {
+	t.Helper()
+	// Up to 2.4, offset fetches name a single group
+	cl := newClient(t, brokers, kgo.MaxVersions(kversion.V2_4_0()))
+	req := kmsg.NewPtrOffsetFetchRequest()
+	req.Group = group
+	rt := kmsg.NewOffsetFetchRequestTopic()
+	rt.Topic = testTopic
+	rt.Partitions = []int32{0}
+	req.Topics = append(req.Topics, rt)
+
+	resp, err := req.RequestWith(context.Background(), cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, topic := range resp.Topics {
+		for _, p := range topic.Partitions {
+			return p.Offset
+		}
+	}
+	return -1
+}
+
+// waitCommitted waits until the group committed offset want
+func waitCommitted(t *testing.T, brokers []string, group string, want int64) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		got := committedOffset(t, brokers, group)
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("committed offset = %d, want %d", got, want)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// recorder is a Processor counting its calls; failStores store attempts fail before storing
+// succeeds, and stored receives the texts of every stored batch
+type recorder struct {
+	mu         sync.Mutex
+	processed  int
+	stores     int
+	failStores int
+	stored     chan []string
+}
+
+func newRecorder(failStores int) *recorder {
+	return &recorder{failStores: failStores, stored: make(chan []string, 16)}
+}
+
+func (r *recorder) process(_ context.Context, events []ingest.Event) func(context.Context) error {
+	r.mu.Lock()
+	r.processed++
+	r.mu.Unlock()
+	return func(context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.stores++
+		if r.stores <= r.failStores {
+			return errors.New("store unavailable")
+		}
+		var texts []string
+		for _, ev := range events {
+			texts = append(texts, ev.Text)
+		}
+		r.stored <- texts
+		return nil
+	}
+}
+
+// serve runs a consumer of the test topic until the returned function is called
+func serve(t *testing.T, brokers []string, group string, process Processor) (stop func()) {
+	t.Helper()
+	c, err := NewConsumer(ConsumerConfig{
+		Config:      Config{Brokers: brokers},
+		Topics:      []string{testTopic},
+		Group:       group,
+		ValueFormat: FormatRaw,
+	}, process)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Serve(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("consumer did not stop")
+		}
+	}
+}
+
+func receive(t *testing.T, ch <-chan []string) []string {
+	t.Helper()
+	var got []string
+	deadline := time.After(10 * time.Second)
+	for len(got) < 2 {
+		select {
+		case texts := <-ch:
+			got = append(got, texts...)
+		case <-deadline:
+			t.Fatalf("stored %q before the timeout", got)
+		}
+	}
+	return got
+}
+
+func TestConsumerCommitsAfterStoring(t *testing.T) {
+	brokers := newCluster(t)
+	produce(t, brokers, "one", "two")
+
+	r := newRecorder(1)
+	stop := serve(t, brokers, "commit", r.process)
+	defer stop()
+
+	if got, want := receive(t, r.stored), []string{"one", "two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stored %q, want %q", got, want)
+	}
+	waitCommitted(t, brokers, "commit", 2)
+
+	// The failed store was retried without processing the batch again
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.processed != 1 || r.stores != 2 {
+		t.Errorf("processed %d times and stored %d times, want 1 and 2", r.processed, r.stores)
+	}
+}
+
+func TestConsumerRedeliversUnstoredRecords(t *testing.T) {
+	brokers := newCluster(t)
+	produce(t, brokers, "one", "two")
+
+	// The first member never manages to store the batch
+	failing := newRecorder(1 << 30)
+	stop := serve(t, brokers, "redeliver", failing.process)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		failing.mu.Lock()
+		stores := failing.stores
+		failing.mu.Unlock()
+		if stores > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("batch was not processed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	stop()
+	if off := committedOffset(t, brokers, "redeliver"); off != -1 {
+		t.Fatalf("committed offset %d for an unstored batch", off)
+	}
+
+	// The next member consumes the same records again
+	r := newRecorder(0)
+	stop = serve(t, brokers, "redeliver", r.process)
+	defer stop()
+	if got, want := receive(t, r.stored), []string{"one", "two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stored %q, want %q", got, want)
+	}
+	waitCommitted(t, brokers, "redeliver", 2)
+}
+
+func TestConsumerEvent(t *testing.T) {
+	rec := &kgo.Record{Topic: "app", Partition: 3, Offset: 42, Key: []byte("k")}
+	tests := []struct {
+		name     string
+		format   string
+		value    string
+		wantText string
+		wantMeta map[string]interface{}
+		ok       bool
+	}{
+		{
+			name:     "json",
+			format:   FormatJSON,
+			value:    `{"text": "disk full", "metadata": {"host": "a"}}`,
+			wantText: "disk full",
+			wantMeta: map[string]interface{}{"host": "a"},
+			ok:       true,
+		},
+		{name: "raw", format: FormatRaw, value: `{"text": "kept as is"}`, wantText: `{"text": "kept as is"}`, ok: true},
+		{name: "json without text", format: FormatJSON, value: `{"metadata": {}}`},
+		{name: "invalid json", format: FormatJSON, value: "disk full"},
+		{name: "empty raw value", format: FormatRaw},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Consumer{cfg: ConsumerConfig{ValueFormat: tt.format}}
+			r := *rec
+			r.Value = []byte(tt.value)
+			ev, ok := c.event(&r)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if ev.ID != "kafka-app-3-42" || ev.Text != tt.wantText {
+				t.Errorf("id, text = %q, %q", ev.ID, ev.Text)
+			}
+			want := map[string]interface{}{"kafka_topic": "app", "kafka_partition": int32(3), "kafka_offset": int64(42), "kafka_key": "k"}
+			for k, v := range tt.wantMeta {
+				want[k] = v
+			}
+			if !reflect.DeepEqual(ev.Metadata, want) {
+				t.Errorf("metadata = %v, want %v", ev.Metadata, want)
+			}
+		})
+	}
+}
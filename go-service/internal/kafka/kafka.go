@@ -0,0 +1,34 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"errors"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Config holds the connection settings shared by the consumer and the producer
+type Config struct {
+	Brokers  []string
+	ClientID string // default go-service
+	TLS      bool   // connect with TLS, verifying brokers against the system roots
+}
+
+func (c Config) opts() ([]kgo.Opt, error) {
+	if len(c.Brokers) == 0 {
+		return nil, errors.New("no kafka brokers configured")
+	}
+	clientID := c.ClientID
+	if clientID == "" {
+		clientID = "go-service"
+	}
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(c.Brokers...),
+		kgo.ClientID(clientID),
+	}
+	if c.TLS {
+		opts = append(opts, kgo.DialTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}))
+	}
+	return opts, nil
+}
@@ -0,0 +1,81 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// ProducerConfig selects the topic detection results are published to
+type ProducerConfig struct {
+	Config
+
+	Topic       string
+	MaxBuffered int // results waiting to be sent before new ones are dropped, default 10000
+}
+
+// Producer publishes detection results as JSON. Publishing never blocks the pipeline:
+// results that cannot be buffered while Kafka is unreachable are dropped and counted.
+type Producer struct {
+	client *kgo.Client
+	topic  string
+}
+
+// NewProducer creates the producer client
+func NewProducer(cfg ProducerConfig) (*Producer, error) {
+	if cfg.Topic == "" {
+		return nil, errors.New("no kafka results topic configured")
+	}
+	if cfg.MaxBuffered <= 0 {
+		cfg.MaxBuffered = 10000
+	}
+
+	opts, err := cfg.opts()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts,
+		kgo.DefaultProduceTopic(cfg.Topic),
+		kgo.MaxBufferedRecords(cfg.MaxBuffered),
+	)
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka producer: %w", err)
+	}
+	return &Producer{client: client, topic: cfg.Topic}, nil
+}
+
+// Publish sends v as JSON with the given key
+func (p *Producer) Publish(key string, v interface{}) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		metrics.KafkaResultsTotal.WithLabelValues("error").Inc()
+		return
+	}
+
+	rec := &kgo.Record{Key: []byte(key), Value: value}
+	p.client.TryProduce(context.Background(), rec, func(_ *kgo.Record, err error) {
+		switch {
+		case err == nil:
+			metrics.KafkaResultsTotal.WithLabelValues("sent").Inc()
+		case errors.Is(err, kgo.ErrMaxBuffered):
+			metrics.KafkaResultsTotal.WithLabelValues("dropped").Inc()
+		default:
+			metrics.KafkaResultsTotal.WithLabelValues("error").Inc()
+			log.Printf("Failed to publish detection result to %s: %v", p.topic, err)
+		}
+	})
+}
+
+// Close sends the buffered results, waiting until ctx is done at most
+func (p *Producer) Close(ctx context.Context) error {
+	err := p.client.Flush(ctx)
+	p.client.Close()
+	return err
+}
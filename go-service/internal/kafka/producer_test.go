@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestProducerPublishes(t *testing.T) {
+	brokers := newCluster(t)
+	p, err := NewProducer(ProducerConfig{Config: Config{Brokers: brokers}, Topic: testTopic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Publish("log-1", map[string]interface{}{"id": "log-1", "is_anomaly": true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := p.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	cl := newClient(t, brokers, kgo.ConsumeTopics(testTopic))
+	fetches := cl.PollFetches(ctx)
+	if err := fetches.Err(); err != nil {
+		t.Fatal(err)
+	}
+	records := fetches.Records()
+	if len(records) != 1 {
+		t.Fatalf("%d records published, want 1", len(records))
+	}
+	if key := string(records[0].Key); key != "log-1" {
+		t.Errorf("key = %q, want log-1", key)
+	}
+	var got struct {
+		ID        string `json:"id"`
+		IsAnomaly bool   `json:"is_anomaly"`
+	}
+	if err := json.Unmarshal(records[0].Value, &got); err != nil || got.ID != "log-1" || !got.IsAnomaly {
+		t.Errorf("value = %s, %v", records[0].Value, err)
+	}
+}
@@ -13,6 +13,30 @@ var (
 		[]string{"content_type"},
 	)
 
+	LogsDroppedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_logs_dropped_total",
+			Help: "Total number of logs dropped by a preprocessing stage",
+		},
+		[]string{"pipeline", "stage"},
+	)
+
+	MultilineEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_multiline_events_total",
+			Help: "Total number of events assembled from several lines",
+		},
+		[]string{"rule"},
+	)
+
+	RedactionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_redactions_total",
+			Help: "Total number of values redacted from log text, by type",
+		},
+		[]string{"type"},
+	)
+
 	AnomaliesTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "app_anomalies_total",
@@ -21,6 +45,14 @@ var (
 		[]string{"content_type"},
 	)
 
+	AnomaliesSuppressedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_anomalies_suppressed_total",
+			Help: "Total number of anomalies silenced by a suppression rule",
+		},
+		[]string{"rule"},
+	)
+
 	ProcessingLatency = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "app_processing_latency_seconds",
@@ -29,10 +61,223 @@ var (
 		},
 		[]string{"content_type"},
 	)
+
+	DetectorVerdictsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_detector_verdicts_total",
+			Help: "Total number of verdicts per detector, split by whether the log was flagged",
+		},
+		[]string{"detector", "anomaly"},
+	)
+
+	DetectorErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_detector_errors_total",
+			Help: "Total number of logs a detector failed to classify",
+		},
+		[]string{"detector"},
+	)
+
+	VolumeAnomaliesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_volume_anomalies_total",
+			Help: "Total number of log volume spikes and drops detected",
+		},
+		[]string{"kind"},
+	)
+
+	AlertsFiredTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_alerts_fired_total",
+			Help: "Total number of times an alert rule matched, before deduplication",
+		},
+		[]string{"rule"},
+	)
+
+	AlertNotificationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_alert_notifications_total",
+			Help: "Total number of alert notifications by channel and outcome",
+		},
+		[]string{"channel", "status"},
+	)
+
+	IncidentsOpenedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "app_incidents_opened_total",
+			Help: "Total number of incidents opened",
+		},
+	)
+
+	FeedbackTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_feedback_total",
+			Help: "Total number of analyst verdicts received",
+		},
+		[]string{"verdict"},
+	)
+
+	FeedbackOutcomes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "app_feedback_outcomes",
+			Help: "Logs with analyst feedback per detector and outcome (true_positive, false_positive, false_negative, true_negative)",
+		},
+		[]string{"detector", "outcome"},
+	)
+
+	DetectorPrecision = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "app_detector_precision",
+			Help: "Share of the logs flagged by a detector that analysts confirmed as anomalies",
+		},
+		[]string{"detector"},
+	)
+
+	DetectorRecall = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "app_detector_recall",
+			Help: "Share of the anomalies confirmed by analysts that a detector flagged",
+		},
+		[]string{"detector"},
+	)
+
+	IngestQueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "app_ingest_queue_depth",
+			Help: "Number of logs waiting in the ingestion queue",
+		},
+	)
+
+	IngestRejectedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "app_ingest_rejected_total",
+			Help: "Total number of logs rejected because the ingestion queue was full",
+		},
+	)
+
+	InputEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_input_events_total",
+			Help: "Total number of logs received by a network input",
+		},
+		[]string{"input"},
+	)
+
+	InputDroppedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_input_dropped_total",
+			Help: "Total number of logs a network input could not accept",
+		},
+		[]string{"input", "reason"},
+	)
+
+	KafkaResultsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_kafka_results_total",
+			Help: "Total number of detection results published to Kafka, by outcome (sent, dropped, error)",
+		},
+		[]string{"result"},
+	)
+
+	ESBulkQueueDepth = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "app_es_bulk_queue_depth",
+			Help: "Number of documents queued or being retried by the Elasticsearch bulk indexer",
+		},
+	)
+
+	ESBulkFlushLatency = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "app_es_bulk_flush_latency_seconds",
+			Help:    "Latency of Elasticsearch _bulk requests in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+
+	ESBulkDocsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_es_bulk_docs_total",
+			Help: "Total number of documents handled by the bulk indexer, by outcome (indexed, retried, spilled, dead_lettered, dropped)",
+		},
+		[]string{"result"},
+	)
+
+	ESBulkFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_es_bulk_failures_total",
+			Help: "Total number of documents a _bulk request failed to index, by Elasticsearch error type",
+		},
+		[]string{"type"},
+	)
+
+	ESIndexLifecycleTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_es_index_lifecycle_total",
+			Help: "Total number of lifecycle actions taken on the log indices, by action (rollover, forcemerge, delete)",
+		},
+		[]string{"action"},
+	)
+
+	ElasticsearchUp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "app_elasticsearch_up",
+			Help: "Whether the last health check reached Elasticsearch (1) or not (0)",
+		},
+	)
+
+	WALBacklogRecords = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "app_wal_backlog_records",
+			Help: "Number of documents in the write-ahead log waiting to be replayed",
+		},
+	)
+
+	WALBacklogBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "app_wal_backlog_bytes",
+			Help: "Size in bytes of the documents in the write-ahead log waiting to be replayed",
+		},
+	)
+
+	WALRecordsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "app_wal_records_total",
+			Help: "Total number of documents handled by the write-ahead log, by outcome (appended, replayed, dropped)",
+		},
+		[]string{"result"},
+	)
 )
 
 func Init() {
 	prometheus.MustRegister(LogsProcessedTotal)
+	prometheus.MustRegister(LogsDroppedTotal)
+	prometheus.MustRegister(MultilineEventsTotal)
+	prometheus.MustRegister(RedactionsTotal)
 	prometheus.MustRegister(AnomaliesTotal)
+	prometheus.MustRegister(AnomaliesSuppressedTotal)
 	prometheus.MustRegister(ProcessingLatency)
+	prometheus.MustRegister(DetectorVerdictsTotal)
+	prometheus.MustRegister(DetectorErrorsTotal)
+	prometheus.MustRegister(VolumeAnomaliesTotal)
+	prometheus.MustRegister(AlertsFiredTotal)
+	prometheus.MustRegister(AlertNotificationsTotal)
+	prometheus.MustRegister(IncidentsOpenedTotal)
+	prometheus.MustRegister(FeedbackTotal)
+	prometheus.MustRegister(FeedbackOutcomes)
+	prometheus.MustRegister(DetectorPrecision)
+	prometheus.MustRegister(DetectorRecall)
+	prometheus.MustRegister(IngestQueueDepth)
+	prometheus.MustRegister(IngestRejectedTotal)
+	prometheus.MustRegister(InputEventsTotal)
+	prometheus.MustRegister(InputDroppedTotal)
+	prometheus.MustRegister(KafkaResultsTotal)
+	prometheus.MustRegister(ESBulkQueueDepth)
+	prometheus.MustRegister(ESBulkFlushLatency)
+	prometheus.MustRegister(ESBulkDocsTotal)
+	prometheus.MustRegister(ESBulkFailuresTotal)
+	prometheus.MustRegister(ESIndexLifecycleTotal)
+	prometheus.MustRegister(ElasticsearchUp)
+	prometheus.MustRegister(WALBacklogRecords)
+	prometheus.MustRegister(WALBacklogBytes)
+	prometheus.MustRegister(WALRecordsTotal)
 }
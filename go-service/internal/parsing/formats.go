@@ -0,0 +1,364 @@
+package parsing
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keys holding the event time, level, logger and message in JSON and logfmt lines
+var (
+	timeKeys    = []string{"@timestamp", "timestamp", "time", "ts", "datetime", "date", "t"}
+	levelKeys   = []string{"level", "lvl", "severity", "loglevel", "levelname", "log.level"}
+	loggerKeys  = []string{"logger", "logger_name", "log.logger", "name", "component"}
+	messageKeys = []string{"message", "msg"}
+)
+
+// timeLayouts are tried on the timestamps of JSON and logfmt lines
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 Z0700",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"02/Jan/2006:15:04:05 -0700",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+func parseJSON(p *Parser, line string, now time.Time) (Record, bool) {
+	if !strings.HasPrefix(line, "{") || !strings.HasSuffix(line, "}") {
+		return Record{}, false
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return Record{}, false
+	}
+
+	var rec Record
+	if v, ok := take(fields, timeKeys); ok {
+		if t, ok := p.valueTime(v); ok {
+			rec.Time = t
+		} else {
+			fields["time"] = v // keep what could not be parsed
+		}
+	}
+	if v, ok := take(fields, levelKeys); ok {
+		rec.Level = jsonLevel(v)
+	}
+	if v, ok := take(fields, loggerKeys); ok {
+		rec.Logger = toString(v)
+	}
+	if v, ok := take(fields, messageKeys); ok {
+		rec.Message = toString(v)
+	}
+	if len(fields) > 0 {
+		rec.Fields = fields
+	}
+	return rec, true
+}
+
+func parseLogfmt(p *Parser, line string, now time.Time) (Record, bool) {
+	pairs, ok := splitLogfmt(line)
+	if !ok || len(pairs) < 2 {
+		return Record{}, false
+	}
+
+	fields := make(map[string]interface{}, len(pairs))
+	for k, v := range pairs {
+		fields[k] = v
+	}
+
+	var rec Record
+	if v, ok := take(fields, timeKeys); ok {
+		if t, ok := p.valueTime(v); ok {
+			rec.Time = t
+		} else {
+			fields["time"] = v
+		}
+	}
+	if v, ok := take(fields, levelKeys); ok {
+		rec.Level = toString(v)
+	}
+	if v, ok := take(fields, loggerKeys); ok {
+		rec.Logger = toString(v)
+	}
+	if v, ok := take(fields, messageKeys); ok {
+		rec.Message = toString(v)
+	}
+	if len(fields) > 0 {
+		rec.Fields = fields
+	}
+	return rec, true
+}
+
+// splitLogfmt reads a line made only of key=value pairs, values optionally double-quoted
+func splitLogfmt(line string) (map[string]string, bool) {
+	pairs := make(map[string]string)
+	i := 0
+	for i < len(line) {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '"' {
+			i++
+		}
+		if i == start || i >= len(line) || line[i] != '=' {
+			return nil, false
+		}
+		key := line[start:i]
+		i++
+
+		if i < len(line) && line[i] == '"' {
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, false
+			}
+			v, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, false
+			}
+			pairs[key] = v
+			i = end + 1
+			continue
+		}
+
+		start = i
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		pairs[key] = line[start:i]
+	}
+	return pairs, true
+}
+
+var accessLine = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "([^"\\]*(?:\\.[^"\\]*)*)" (\d{3}) (\d+|-)(?: "([^"]*)" "([^"]*)")?`)
+
+func parseAccess(p *Parser, line string, now time.Time) (Record, bool) {
+	m := accessLine.FindStringSubmatch(line)
+	if m == nil {
+		return Record{}, false
+	}
+	t, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[4])
+	if err != nil {
+		return Record{}, false
+	}
+	status, _ := strconv.Atoi(m[6])
+
+	rec := Record{
+		Time:    t,
+		Level:   LevelInfo,
+		Message: m[5],
+		Fields: map[string]interface{}{
+			"client": m[1],
+			"status": status,
+		},
+	}
+	switch {
+	case status >= 500:
+		rec.Level = LevelError
+	case status >= 400:
+		rec.Level = LevelWarn
+	}
+
+	if parts := strings.SplitN(m[5], " ", 3); len(parts) == 3 {
+		rec.Fields["method"] = parts[0]
+		rec.Fields["path"] = parts[1]
+		rec.Fields["protocol"] = parts[2]
+	}
+	setUnlessNil(rec.Fields, "user", m[3])
+	if m[7] != "-" {
+		bytes, _ := strconv.Atoi(m[7])
+		rec.Fields["bytes"] = bytes
+	}
+	setUnlessNil(rec.Fields, "referer", m[8])
+	setUnlessNil(rec.Fields, "user_agent", m[9])
+	return rec, true
+}
+
+// javaLine matches the Logback/Log4j "date [thread] LEVEL logger - msg" layouts and the Spring
+// Boot "date LEVEL pid --- [thread] logger : msg" one
+var javaLine = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?)\s+(?:\[([^\]]*)\]\s+)?(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|FATAL|SEVERE)\s+(?:(\d+)\s+---\s+)?(?:\[([^\]]*)\]\s+)*([\w$.]+)\s+[-:]\s?(.*)$`)
+
+var javaLayouts = []string{
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05Z0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+}
+
+func parseJava(p *Parser, line string, now time.Time) (Record, bool) {
+	m := javaLine.FindStringSubmatch(line)
+	if m == nil {
+		return Record{}, false
+	}
+	t, ok := p.parseTime(m[1], javaLayouts...)
+	if !ok {
+		return Record{}, false
+	}
+
+	rec := Record{Time: t, Level: m[3], Logger: m[6], Message: m[7], Fields: map[string]interface{}{}}
+	thread := m[2]
+	if thread == "" {
+		thread = m[5]
+	}
+	setUnlessNil(rec.Fields, "thread", strings.TrimSpace(thread))
+	setUnlessNil(rec.Fields, "pid", m[4])
+	if len(rec.Fields) == 0 {
+		rec.Fields = nil
+	}
+	return rec, true
+}
+
+var (
+	// %(asctime)s - %(name)s - %(levelname)s - %(message)s
+	pythonLine = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2},\d{3}) - (\S+) - (DEBUG|INFO|WARNING|ERROR|CRITICAL) - (.*)$`)
+	// logging.basicConfig() default, %(levelname)s:%(name)s:%(message)s
+	pythonBasicLine = regexp.MustCompile(`^(DEBUG|INFO|WARNING|ERROR|CRITICAL):([^:\s]+):(.*)$`)
+)
+
+func parsePython(p *Parser, line string, now time.Time) (Record, bool) {
+	if m := pythonLine.FindStringSubmatch(line); m != nil {
+		t, ok := p.parseTime(m[1], "2006-01-02 15:04:05")
+		if !ok {
+			return Record{}, false
+		}
+		return Record{Time: t, Logger: m[2], Level: m[3], Message: m[4]}, true
+	}
+	if m := pythonBasicLine.FindStringSubmatch(line); m != nil {
+		return Record{Level: m[1], Logger: m[2], Message: m[3]}, true
+	}
+	return Record{}, false
+}
+
+var (
+	// log.LstdFlags with optional Lmicroseconds and Lshortfile/Llongfile
+	goLine = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?) (?:(\S+\.go:\d+): )?(.*)$`)
+	// glog/klog: Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg
+	glogLine = regexp.MustCompile(`^([IWEF])(\d{4} \d{2}:\d{2}:\d{2}\.\d+)\s+(\d+) ([^\s\]]+:\d+)\] (.*)$`)
+	// A level at the start of a standard log message, e.g. "[ERROR] ..." or "WARN: ..."
+	messageLevel = regexp.MustCompile(`^\[?(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|FATAL|PANIC)\]?:?\s+(.*)$`)
+)
+
+func parseGo(p *Parser, line string, now time.Time) (Record, bool) {
+	if m := goLine.FindStringSubmatch(line); m != nil {
+		t, ok := p.parseTime(m[1], "2006/01/02 15:04:05")
+		if !ok {
+			return Record{}, false
+		}
+		rec := Record{Time: t, Message: m[3]}
+		if m[2] != "" {
+			rec.Fields = map[string]interface{}{"caller": m[2]}
+		}
+		if lm := messageLevel.FindStringSubmatch(rec.Message); lm != nil {
+			rec.Level = lm[1]
+			rec.Message = lm[2]
+		}
+		return rec, true
+	}
+	if m := glogLine.FindStringSubmatch(line); m != nil {
+		t, ok := p.parseTime(m[2], "0102 15:04:05")
+		if !ok {
+			return Record{}, false
+		}
+		return Record{
+			Time:    p.withYear(t, now),
+			Level:   m[1],
+			Message: m[5],
+			Fields:  map[string]interface{}{"thread": m[3], "caller": m[4]},
+		}, true
+	}
+	return Record{}, false
+}
+
+// valueTime reads a JSON or logfmt timestamp: a date string or a Unix epoch in seconds,
+// milliseconds, microseconds or nanoseconds
+func (p *Parser) valueTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case string:
+		if parsed, ok := p.parseTime(t, timeLayouts...); ok {
+			return parsed, true
+		}
+		if f, err := strconv.ParseFloat(t, 64); err == nil {
+			return epoch(f), true
+		}
+	case float64:
+		return epoch(t), true
+	}
+	return time.Time{}, false
+}
+
+func epoch(f float64) time.Time {
+	switch {
+	case f > 1e17:
+		return time.Unix(0, int64(f))
+	case f > 1e14:
+		return time.UnixMicro(int64(f))
+	case f > 1e11:
+		return time.UnixMilli(int64(f))
+	}
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9))
+}
+
+// jsonLevel reads a level name, or a Bunyan/Pino numeric level
+func jsonLevel(v interface{}) string {
+	n, ok := v.(float64)
+	if !ok {
+		return toString(v)
+	}
+	switch {
+	case n >= 60:
+		return LevelFatal
+	case n >= 50:
+		return LevelError
+	case n >= 40:
+		return LevelWarn
+	case n >= 30:
+		return LevelInfo
+	case n >= 20:
+		return LevelDebug
+	}
+	return LevelTrace
+}
+
+// take removes and returns the value of the first key present
+func take(fields map[string]interface{}, keys []string) (interface{}, bool) {
+	for _, k := range keys {
+		if v, ok := fields[k]; ok {
+			delete(fields, k)
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func toString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// setUnlessNil sets a field unless the value is empty or the syslog/access log nil value "-"
+func setUnlessNil(fields map[string]interface{}, key, value string) {
+	if value != "" && value != "-" {
+		fields[key] = value
+	}
+}
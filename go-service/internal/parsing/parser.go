@@ -0,0 +1,174 @@
+package parsing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Formats
+const (
+	FormatJSON    = "json"    // one JSON object per line
+	FormatRFC5424 = "rfc5424" // <165>1 2024-01-02T15:04:05Z host app 123 ID47 [sd] msg
+	FormatRFC3164 = "rfc3164" // <34>Jan  2 15:04:05 host app[123]: msg
+	FormatAccess  = "access"  // Apache/Nginx common and combined access logs
+	FormatJava    = "java"    // Logback, Log4j and Spring Boot default layouts
+	FormatPython  = "python"  // logging default and basicConfig layouts
+	FormatGo      = "go"      // standard library log prefix and glog/klog headers
+	FormatLogfmt  = "logfmt"  // key=value pairs
+)
+
+// AllFormats lists every format in the order they are tried. The prefix formats come
+// before logfmt because their messages often contain key=value pairs.
+var AllFormats = []string{
+	FormatJSON,
+	FormatRFC5424,
+	FormatRFC3164,
+	FormatAccess,
+	FormatJava,
+	FormatPython,
+	FormatGo,
+	FormatLogfmt,
+}
+
+// Normalized levels
+const (
+	LevelTrace = "trace"
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+// Record is what a parser extracted from a log line; every part is optional
+type Record struct {
+	Format  string                 `json:"format"`
+	Time    time.Time              `json:"time,omitempty"` // zero when the line carries no timestamp
+	Level   string                 `json:"level,omitempty"`
+	Logger  string                 `json:"logger,omitempty"`
+	Message string                 `json:"message,omitempty"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Config selects the formats to detect
+type Config struct {
+	Formats []string // empty means AllFormats
+	// Location is used for timestamps without a zone; nil means UTC
+	Location *time.Location
+}
+
+type parseFunc func(p *Parser, line string, now time.Time) (Record, bool)
+
+var parsers = map[string]parseFunc{
+	FormatJSON:    parseJSON,
+	FormatRFC5424: parseRFC5424,
+	FormatRFC3164: parseRFC3164,
+	FormatAccess:  parseAccess,
+	FormatJava:    parseJava,
+	FormatPython:  parsePython,
+	FormatGo:      parseGo,
+	FormatLogfmt:  parseLogfmt,
+}
+
+// Parser detects the format of log lines and extracts their event time, level, logger and fields
+type Parser struct {
+	formats []string
+	loc     *time.Location
+}
+
+// NewParser creates a parser for the configured formats
+func NewParser(cfg Config) (*Parser, error) {
+	formats := cfg.Formats
+	if len(formats) == 0 {
+		formats = AllFormats
+	}
+
+	enabled := make(map[string]bool, len(formats))
+	for _, f := range formats {
+		if _, ok := parsers[f]; !ok {
+			return nil, fmt.Errorf("unknown log format %q", f)
+		}
+		enabled[f] = true
+	}
+
+	p := &Parser{loc: cfg.Location}
+	if p.loc == nil {
+		p.loc = time.UTC
+	}
+	for _, f := range AllFormats {
+		if enabled[f] {
+			p.formats = append(p.formats, f)
+		}
+	}
+	return p, nil
+}
+
+// Parse tries the formats in order on the first line of text, so that stack traces and
+// other continuation lines do not prevent detection. now is used to complete timestamps
+// that lack a year.
+func (p *Parser) Parse(text string, now time.Time) (Record, bool) {
+	line := strings.TrimSpace(text)
+	if i := strings.IndexByte(line, '\n'); i >= 0 && !strings.HasPrefix(line, "{") {
+		line = strings.TrimRight(line[:i], "\r")
+	}
+	if line == "" {
+		return Record{}, false
+	}
+
+	for _, f := range p.formats {
+		if rec, ok := parsers[f](p, line, now); ok {
+			rec.Format = f
+			rec.Level = NormalizeLevel(rec.Level)
+			if !rec.Time.IsZero() {
+				rec.Time = rec.Time.UTC()
+			}
+			return rec, true
+		}
+	}
+	return Record{}, false
+}
+
+// NormalizeLevel maps the level names of the supported formats to trace, debug, info,
+// warn, error and fatal; unknown names are returned lowercased
+func NormalizeLevel(level string) string {
+	l := strings.ToLower(strings.TrimSpace(level))
+	switch l {
+	case "":
+		return ""
+	case "trace", "finest", "finer":
+		return LevelTrace
+	case "debug", "dbug", "fine", "d":
+		return LevelDebug
+	case "info", "information", "informational", "notice", "config", "i":
+		return LevelInfo
+	case "warn", "warning", "w":
+		return LevelWarn
+	case "error", "err", "eror", "severe", "e":
+		return LevelError
+	case "fatal", "critical", "crit", "panic", "alert", "emerg", "emergency", "f":
+		return LevelFatal
+	}
+	return l
+}
+
+// parseTime tries layouts in order; layouts without a zone are read in the parser's location
+func (p *Parser) parseTime(value string, layouts ...string) (time.Time, bool) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, p.loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// withYear completes a timestamp parsed without a year: the current year is assumed,
+// unless that puts it more than a day in the future, as happens around New Year
+func (p *Parser) withYear(t, now time.Time) time.Time {
+	year := now.In(p.loc).Year()
+	t = time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), p.loc)
+	if t.Sub(now) > 24*time.Hour {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
@@ -0,0 +1,226 @@
+package parsing
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SyslogMessage is a syslog message split into its header fields. Fields holding the
+// RFC 5424 nil value "-" are left empty.
+type SyslogMessage struct {
+	Format         string // FormatRFC5424 or FormatRFC3164
+	Priority       int    // -1 for RFC 3164 lines without a priority
+	Time           time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData map[string]string // "sd-id.name" keys
+	Message        string
+}
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// Facility returns the facility name, or "" without a priority
+func (m SyslogMessage) Facility() string {
+	if m.Priority < 0 {
+		return ""
+	}
+	if f := m.Priority / 8; f < len(facilities) {
+		return facilities[f]
+	}
+	return strconv.Itoa(m.Priority / 8)
+}
+
+// Severity returns the severity name (emerg to debug), or "" without a priority
+func (m SyslogMessage) Severity() string {
+	if m.Priority < 0 {
+		return ""
+	}
+	return severities[m.Priority%8]
+}
+
+// ParseSyslog reads an RFC 5424 or RFC 3164 message; RFC 3164 timestamps get their year from now
+func (p *Parser) ParseSyslog(line string, now time.Time) (SyslogMessage, bool) {
+	if m, ok := parseSyslog5424(line); ok {
+		return m, true
+	}
+	return p.parseSyslog3164(line, now)
+}
+
+var rfc5424Header = regexp.MustCompile(`^<(\d{1,3})>1 (\S+) (\S+) (\S+) (\S+) (\S+) `)
+
+func parseSyslog5424(line string) (SyslogMessage, bool) {
+	h := rfc5424Header.FindStringSubmatch(line)
+	if h == nil {
+		return SyslogMessage{}, false
+	}
+	pri, ok := parsePriority(h[1])
+	if !ok {
+		return SyslogMessage{}, false
+	}
+	sd, msg, ok := parseStructuredData(line[len(h[0]):])
+	if !ok {
+		return SyslogMessage{}, false
+	}
+
+	m := SyslogMessage{
+		Format:         FormatRFC5424,
+		Priority:       pri,
+		Hostname:       nilValue(h[3]),
+		AppName:        nilValue(h[4]),
+		ProcID:         nilValue(h[5]),
+		MsgID:          nilValue(h[6]),
+		StructuredData: sd,
+		Message:        strings.TrimPrefix(msg, "\ufeff"),
+	}
+	if h[2] != "-" {
+		t, err := time.Parse(time.RFC3339Nano, h[2])
+		if err != nil {
+			return SyslogMessage{}, false
+		}
+		m.Time = t
+	}
+	return m, true
+}
+
+// parseStructuredData reads the STRUCTURED-DATA part of an RFC 5424 message, returning its
+// parameters as "sd-id.name" keys and the message that follows
+func parseStructuredData(s string) (map[string]string, string, bool) {
+	if strings.HasPrefix(s, "-") {
+		return nil, strings.TrimPrefix(s[1:], " "), true
+	}
+
+	params := make(map[string]string)
+	for strings.HasPrefix(s, "[") {
+		end := strings.IndexAny(s, " ]")
+		if end < 0 {
+			return nil, "", false
+		}
+		id := s[1:end]
+		s = s[end:]
+
+		for strings.HasPrefix(s, " ") {
+			s = s[1:]
+			eq := strings.Index(s, `="`)
+			if eq <= 0 {
+				return nil, "", false
+			}
+			name := s[:eq]
+			s = s[eq+2:]
+
+			var value strings.Builder
+			for {
+				if s == "" {
+					return nil, "", false
+				}
+				c := s[0]
+				s = s[1:]
+				if c == '"' {
+					break
+				}
+				if c == '\\' && s != "" && (s[0] == '"' || s[0] == '\\' || s[0] == ']') {
+					c = s[0]
+					s = s[1:]
+				}
+				value.WriteByte(c)
+			}
+			params[id+"."+name] = value.String()
+		}
+		if !strings.HasPrefix(s, "]") {
+			return nil, "", false
+		}
+		s = s[1:]
+	}
+	if s != "" && s[0] != ' ' {
+		return nil, "", false
+	}
+	return params, strings.TrimPrefix(s, " "), true
+}
+
+var rfc3164Line = regexp.MustCompile(`^(?:<(\d{1,3})>)?([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) (\S+) ([^:\[\s]+)(?:\[(\d+)\])?: ?(.*)$`)
+
+func (p *Parser) parseSyslog3164(line string, now time.Time) (SyslogMessage, bool) {
+	h := rfc3164Line.FindStringSubmatch(line)
+	if h == nil {
+		return SyslogMessage{}, false
+	}
+	t, ok := p.parseTime(h[2], time.Stamp)
+	if !ok {
+		return SyslogMessage{}, false
+	}
+
+	m := SyslogMessage{
+		Format:   FormatRFC3164,
+		Priority: -1,
+		Time:     p.withYear(t, now),
+		Hostname: h[3],
+		AppName:  h[4],
+		ProcID:   h[5],
+		Message:  h[6],
+	}
+	if h[1] != "" {
+		pri, ok := parsePriority(h[1])
+		if !ok {
+			return SyslogMessage{}, false
+		}
+		m.Priority = pri
+	}
+	return m, true
+}
+
+func parseRFC5424(p *Parser, line string, now time.Time) (Record, bool) {
+	m, ok := parseSyslog5424(line)
+	if !ok {
+		return Record{}, false
+	}
+	return m.record(), true
+}
+
+func parseRFC3164(p *Parser, line string, now time.Time) (Record, bool) {
+	m, ok := p.parseSyslog3164(line, now)
+	if !ok {
+		return Record{}, false
+	}
+	return m.record(), true
+}
+
+// record maps the header fields: the severity is the level, the app name the logger,
+// and the rest go to fields
+func (m SyslogMessage) record() Record {
+	rec := Record{
+		Time:    m.Time,
+		Level:   m.Severity(),
+		Logger:  m.AppName,
+		Message: m.Message,
+		Fields:  make(map[string]interface{}),
+	}
+	setUnlessNil(rec.Fields, "host", m.Hostname)
+	setUnlessNil(rec.Fields, "facility", m.Facility())
+	setUnlessNil(rec.Fields, "pid", m.ProcID)
+	setUnlessNil(rec.Fields, "msgid", m.MsgID)
+	for k, v := range m.StructuredData {
+		rec.Fields[k] = v
+	}
+	return rec
+}
+
+func parsePriority(s string) (int, bool) {
+	pri, err := strconv.Atoi(s)
+	return pri, err == nil && pri <= 191
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
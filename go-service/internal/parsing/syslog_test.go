@@ -0,0 +1,153 @@
+package parsing
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSyslog(t *testing.T) {
+	p, err := NewParser(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		line string
+		want SyslogMessage
+		ok   bool
+	}{
+		{
+			name: "RFC 5424 with nil values",
+			line: "<34>1 2024-06-15T10:00:00.5+02:00 - su - - - 'su root' failed",
+			want: SyslogMessage{
+				Format:   FormatRFC5424,
+				Priority: 34,
+				Time:     time.Date(2024, 6, 15, 8, 0, 0, 500000000, time.UTC),
+				AppName:  "su",
+				Message:  "'su root' failed",
+			},
+			ok: true,
+		},
+		{
+			name: "RFC 5424 structured data with escapes",
+			line: `<165>1 - host app 1 ID [a@1 x="1" y="q\"\]\\"][b@2 z=""] msg`,
+			want: SyslogMessage{
+				Format:         FormatRFC5424,
+				Priority:       165,
+				Hostname:       "host",
+				AppName:        "app",
+				ProcID:         "1",
+				MsgID:          "ID",
+				StructuredData: map[string]string{"a@1.x": "1", "a@1.y": `q"]\`, "b@2.z": ""},
+				Message:        "msg",
+			},
+			ok: true,
+		},
+		{
+			name: "RFC 5424 without a message",
+			line: "<13>1 - h a - - [id]",
+			want: SyslogMessage{
+				Format:         FormatRFC5424,
+				Priority:       13,
+				Hostname:       "h",
+				AppName:        "a",
+				StructuredData: map[string]string{},
+			},
+			ok: true,
+		},
+		{
+			name: "RFC 5424 BOM is stripped",
+			line: "<13>1 - h a - - - \ufeffhello",
+			want: SyslogMessage{Format: FormatRFC5424, Priority: 13, Hostname: "h", AppName: "a", Message: "hello"},
+			ok:   true,
+		},
+		{
+			name: "RFC 3164",
+			line: "<4>Jun  5 08:09:10 gw kernel: link down",
+			want: SyslogMessage{
+				Format:   FormatRFC3164,
+				Priority: 4,
+				Time:     time.Date(2024, 6, 5, 8, 9, 10, 0, time.UTC),
+				Hostname: "gw",
+				AppName:  "kernel",
+				Message:  "link down",
+			},
+			ok: true,
+		},
+		{
+			name: "RFC 3164 without a priority",
+			line: "Jun 15 11:00:00 web sshd[99]: accepted",
+			want: SyslogMessage{
+				Format:   FormatRFC3164,
+				Priority: -1,
+				Time:     time.Date(2024, 6, 15, 11, 0, 0, 0, time.UTC),
+				Hostname: "web",
+				AppName:  "sshd",
+				ProcID:   "99",
+				Message:  "accepted",
+			},
+			ok: true,
+		},
+		{
+			name: "RFC 3164 from late last year",
+			line: "<13>Dec 31 23:59:59 h a: x",
+			want: SyslogMessage{
+				Format:   FormatRFC3164,
+				Priority: 13,
+				Time:     time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC),
+				Hostname: "h",
+				AppName:  "a",
+				Message:  "x",
+			},
+			ok: true,
+		},
+		{name: "priority out of range", line: "<192>1 - h a - - - msg"},
+		{name: "unterminated structured data", line: `<13>1 - h a - - [id x="1" msg`},
+		{name: "structured data without a value", line: `<13>1 - h a - - [id x] msg`},
+		{name: "text after structured data", line: `<13>1 - h a - - [id]msg`},
+		{name: "bad RFC 5424 timestamp", line: "<13>1 yesterday h a - - - msg"},
+		{name: "plain text", line: "something happened"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := p.ParseSyslog(tt.line, now)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v (%+v)", ok, tt.ok, got)
+			}
+			if !ok {
+				return
+			}
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time, tt.want.Time = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslogPriority(t *testing.T) {
+	tests := []struct {
+		priority int
+		facility string
+		severity string
+	}{
+		{-1, "", ""},
+		{0, "kern", "emerg"},
+		{14, "user", "info"},
+		{87, "authpriv", "debug"},
+		{191, "local7", "debug"},
+	}
+	for _, tt := range tests {
+		m := SyslogMessage{Priority: tt.priority}
+		if m.Facility() != tt.facility || m.Severity() != tt.severity {
+			t.Errorf("priority %d = %s.%s, want %s.%s", tt.priority, m.Facility(), m.Severity(), tt.facility, tt.severity)
+		}
+	}
+}
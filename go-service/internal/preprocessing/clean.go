@@ -1,22 +1,18 @@
 package preprocessing
 
-import (
-	"regexp"
-	"strings"
-)
-
-func PreprocessLogText(input string) string {
-	reTime := regexp.MustCompile(`\[\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\]`)
-	cleaned := reTime.ReplaceAllString(input, "")
-
-	reIP := regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}\b`)
-	cleaned = reIP.ReplaceAllString(cleaned, "[REDACTED_IP]")
-
-	reSpace := regexp.MustCompile(`\s+`)
-	cleaned = reSpace.ReplaceAllString(cleaned, " ")
+// defaultStages strip a bracketed timestamp, redact IPv4 addresses, collapse
+// whitespace and lowercase the text
+var defaultStages = []StageConfig{
+	{Name: "strip-timestamp", Type: StageReplace, Pattern: `\[\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\]`},
+	{Name: "redact-ip", Type: StageMask, Pattern: `\b\d{1,3}(\.\d{1,3}){3}\b`, Mask: "[REDACTED_IP]"},
+	{Name: "collapse-whitespace", Type: StageReplace, Pattern: `\s+`, Replacement: " "},
+	{Name: "trim", Type: StageTrim},
+	{Name: "lowercase", Type: StageLowercase},
+}
 
-	cleaned = strings.TrimSpace(cleaned)
-	cleaned = strings.ToLower(cleaned)
+var defaultPipeline = mustCompilePipeline(PipelineConfig{Name: DefaultPipeline, Stages: defaultStages})
 
-	return cleaned
+// PreprocessLogText cleans a log line with the default pipeline
+func PreprocessLogText(input string) string {
+	return defaultPipeline.Run(input).Text
 }
@@ -0,0 +1,353 @@
+package preprocessing
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Wildcard marks a variable position in a template
+const Wildcard = "<*>"
+
+// Template is a group of log lines that share the same constant tokens
+type Template struct {
+	ID        string    `json:"template_id"`
+	Template  string    `json:"template"`
+	Tokens    int       `json:"tokens"`
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	version int64
+}
+
+// Match is the result of adding a log line to the miner
+type Match struct {
+	TemplateID string
+	Template   string
+	Params     []string
+	New        bool
+	Count      int64     // occurrences of the template, including this line
+	PrevSeen   time.Time // previous occurrence, zero for a new template
+}
+
+type cluster struct {
+	id        string
+	tokens    []string
+	count     int64
+	firstSeen time.Time
+	lastSeen  time.Time
+	version   int64
+	persisted int64
+}
+
+type drainNode struct {
+	children map[string]*drainNode
+	clusters []*cluster
+}
+
+// Miner is an online Drain template miner. Lines are routed through a fixed-depth
+// prefix tree keyed by token count and leading tokens, then merged into the most
+// similar template of the leaf, or start a new template when none is similar enough.
+type Miner struct {
+	mu           sync.Mutex
+	depth        int
+	simThreshold float64
+	maxChildren  int
+	root         *drainNode
+	clusters     map[string]*cluster
+}
+
+// NewMiner creates a miner. depth is the number of tree levels counting the root, the
+// length level and the leaves, so depth 4 routes on the first token. simThreshold is
+// the share of equal tokens needed to join a template and maxChildren caps the
+// fan-out of each tree node.
+func NewMiner(depth int, simThreshold float64, maxChildren int) *Miner {
+	if depth < 3 {
+		depth = 3
+	}
+	if maxChildren < 2 {
+		maxChildren = 2
+	}
+	return &Miner{
+		depth:        depth,
+		simThreshold: simThreshold,
+		maxChildren:  maxChildren,
+		root:         newDrainNode(),
+		clusters:     map[string]*cluster{},
+	}
+}
+
+// Add assigns line to a template, creating or generalizing one as needed
+func (m *Miner) Add(line string, at time.Time) Match {
+	tokens := strings.Fields(line)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var best *cluster
+	if leaf := m.search(tokens); leaf != nil {
+		best = m.bestMatch(leaf, tokens)
+	}
+
+	isNew := best == nil
+	if isNew {
+		tmpl := make([]string, len(tokens))
+		for i, t := range tokens {
+			if isVariable(t) {
+				tmpl[i] = Wildcard
+			} else {
+				tmpl[i] = t
+			}
+		}
+		// A line that only differs from an existing template in its variable
+		// tokens can miss it on similarity alone; it still belongs to that template
+		if existing, ok := m.clusters[templateID(tmpl)]; ok {
+			best, isNew = existing, false
+		} else {
+			best = &cluster{id: templateID(tmpl), tokens: tmpl, firstSeen: at}
+			leaf := m.insert(tokens)
+			leaf.clusters = append(leaf.clusters, best)
+			m.clusters[best.id] = best
+		}
+	} else {
+		for i, t := range tokens {
+			if best.tokens[i] != t {
+				best.tokens[i] = Wildcard
+			}
+		}
+	}
+
+	prevSeen := best.lastSeen
+	best.count++
+	if at.After(best.lastSeen) {
+		best.lastSeen = at
+	}
+	best.version++
+
+	var params []string
+	for i, t := range best.tokens {
+		if t == Wildcard {
+			params = append(params, tokens[i])
+		}
+	}
+
+	return Match{
+		TemplateID: best.id,
+		Template:   strings.Join(best.tokens, " "),
+		Params:     params,
+		New:        isNew,
+		Count:      best.count,
+		PrevSeen:   prevSeen,
+	}
+}
+
+// Load restores previously persisted templates, e.g. at startup
+func (m *Miner) Load(templates []Template) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range templates {
+		if _, ok := m.clusters[t.ID]; ok {
+			continue
+		}
+		tokens := strings.Fields(t.Template)
+		c := &cluster{
+			id:        t.ID,
+			tokens:    tokens,
+			count:     t.Count,
+			firstSeen: t.FirstSeen,
+			lastSeen:  t.LastSeen,
+		}
+		leaf := m.insert(tokens)
+		leaf.clusters = append(leaf.clusters, c)
+		m.clusters[c.id] = c
+	}
+}
+
+// Get returns the template with the given ID
+func (m *Miner) Get(id string) (Template, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.clusters[id]
+	if !ok {
+		return Template{}, false
+	}
+	return c.snapshot(), true
+}
+
+// Templates returns all templates, most frequent first
+func (m *Miner) Templates() []Template {
+	m.mu.Lock()
+	out := make([]Template, 0, len(m.clusters))
+	for _, c := range m.clusters {
+		out = append(out, c.snapshot())
+	}
+	m.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Dirty returns the templates changed since they were last passed to MarkPersisted
+func (m *Miner) Dirty() []Template {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []Template
+	for _, c := range m.clusters {
+		if c.version != c.persisted {
+			out = append(out, c.snapshot())
+		}
+	}
+	return out
+}
+
+// MarkPersisted records that templates (as returned by Dirty) have been stored.
+// Templates updated in the meantime stay dirty.
+func (m *Miner) MarkPersisted(templates []Template) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range templates {
+		if c, ok := m.clusters[t.ID]; ok {
+			c.persisted = t.version
+		}
+	}
+}
+
+// search walks the prefix tree for tokens without modifying it. Unknown tokens
+// fall back to the wildcard branch; nil means there is no candidate leaf.
+func (m *Miner) search(tokens []string) *drainNode {
+	node := m.root.children[strconv.Itoa(len(tokens))]
+	for i := 0; node != nil && i < m.depth-3 && i < len(tokens); i++ {
+		child := node.children[treeKey(tokens[i])]
+		if child == nil {
+			child = node.children[Wildcard]
+		}
+		node = child
+	}
+	return node
+}
+
+// insert walks the prefix tree for tokens, growing it where needed. Once a node
+// reaches maxChildren, further distinct tokens share its wildcard branch.
+func (m *Miner) insert(tokens []string) *drainNode {
+	lengthKey := strconv.Itoa(len(tokens))
+	node := m.root.children[lengthKey]
+	if node == nil {
+		node = newDrainNode()
+		m.root.children[lengthKey] = node
+	}
+
+	// The root, length and leaf levels account for three of the tree levels
+	for i := 0; i < m.depth-3 && i < len(tokens); i++ {
+		key := treeKey(tokens[i])
+		if child := node.children[key]; child != nil {
+			node = child
+			continue
+		}
+
+		_, hasWildcard := node.children[Wildcard]
+		switch {
+		case key == Wildcard:
+		case hasWildcard && len(node.children) >= m.maxChildren:
+			key = Wildcard
+		case !hasWildcard && len(node.children)+1 >= m.maxChildren:
+			key = Wildcard
+		}
+
+		child := node.children[key]
+		if child == nil {
+			child = newDrainNode()
+			node.children[key] = child
+		}
+		node = child
+	}
+	return node
+}
+
+func (m *Miner) bestMatch(leaf *drainNode, tokens []string) *cluster {
+	var best *cluster
+	bestSim, bestParams := -1.0, -1
+
+	for _, c := range leaf.clusters {
+		if len(c.tokens) != len(tokens) {
+			continue
+		}
+		equal, params := 0, 0
+		for i, t := range c.tokens {
+			switch {
+			case t == Wildcard:
+				params++
+			case t == tokens[i]:
+				equal++
+			}
+		}
+
+		sim := 1.0
+		if len(tokens) > 0 {
+			sim = float64(equal) / float64(len(tokens))
+		}
+		if sim > bestSim || (sim == bestSim && params > bestParams) {
+			best, bestSim, bestParams = c, sim, params
+		}
+	}
+
+	if best == nil || bestSim < m.simThreshold {
+		return nil
+	}
+	return best
+}
+
+func newDrainNode() *drainNode {
+	return &drainNode{children: map[string]*drainNode{}}
+}
+
+func (c *cluster) snapshot() Template {
+	return Template{
+		ID:        c.id,
+		Template:  strings.Join(c.tokens, " "),
+		Tokens:    len(c.tokens),
+		Count:     c.count,
+		FirstSeen: c.firstSeen,
+		LastSeen:  c.lastSeen,
+		version:   c.version,
+	}
+}
+
+func treeKey(token string) string {
+	if isVariable(token) {
+		return Wildcard
+	}
+	return token
+}
+
+// isVariable reports whether a token is likely a parameter (numbers, hex IDs, ...)
+func isVariable(token string) bool {
+	if token == Wildcard {
+		return true
+	}
+	for _, r := range token {
+		if unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func templateID(tokens []string) string {
+	sum := sha1.Sum([]byte(strconv.Itoa(len(tokens)) + ":" + strings.Join(tokens, " ")))
+	return hex.EncodeToString(sum[:8])
+}
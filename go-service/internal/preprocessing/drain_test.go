@@ -0,0 +1,140 @@
+package preprocessing
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMinerTemplates(t *testing.T) {
+	type step struct {
+		line         string
+		wantTemplate string
+		wantParams   []string
+		wantNew      bool
+		wantCount    int64
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "variable tokens become wildcards",
+			steps: []step{
+				{"user 42 logged in", "user <*> logged in", []string{"42"}, true, 1},
+				{"user 7 logged in", "user <*> logged in", []string{"7"}, false, 2},
+			},
+		},
+		{
+			name: "similar lines generalize a template",
+			steps: []step{
+				{"connection from alpha closed", "connection from alpha closed", nil, true, 1},
+				{"connection from beta closed", "connection from <*> closed", []string{"beta"}, false, 2},
+				{"connection from gamma closed", "connection from <*> closed", []string{"gamma"}, false, 3},
+			},
+		},
+		{
+			name: "different lengths never share a template",
+			steps: []step{
+				{"disk full", "disk full", nil, true, 1},
+				{"disk full again", "disk full again", nil, true, 1},
+			},
+		},
+		{
+			name: "dissimilar lines start a new template",
+			steps: []step{
+				{"cache miss for key", "cache miss for key", nil, true, 1},
+				{"cache rebuilt by worker", "cache rebuilt by worker", nil, true, 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMiner(4, 0.5, 100)
+			at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i, s := range tt.steps {
+				got := m.Add(s.line, at.Add(time.Duration(i)*time.Second))
+				if got.Template != s.wantTemplate || got.New != s.wantNew || got.Count != s.wantCount {
+					t.Errorf("step %d: got %q new=%v count=%d, want %q new=%v count=%d",
+						i, got.Template, got.New, got.Count, s.wantTemplate, s.wantNew, s.wantCount)
+				}
+				if !reflect.DeepEqual(got.Params, s.wantParams) {
+					t.Errorf("step %d: params = %v, want %v", i, got.Params, s.wantParams)
+				}
+			}
+		})
+	}
+}
+
+func TestMinerPrevSeen(t *testing.T) {
+	m := NewMiner(4, 0.5, 100)
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := m.Add("job 1 done", first); !got.PrevSeen.IsZero() {
+		t.Errorf("new template has PrevSeen %v", got.PrevSeen)
+	}
+	if got := m.Add("job 2 done", first.Add(time.Minute)); !got.PrevSeen.Equal(first) {
+		t.Errorf("PrevSeen = %v, want %v", got.PrevSeen, first)
+	}
+}
+
+func TestMinerDirtyTracking(t *testing.T) {
+	m := NewMiner(4, 0.5, 100)
+	now := time.Now()
+	m.Add("worker 1 started", now)
+
+	dirty := m.Dirty()
+	if len(dirty) != 1 {
+		t.Fatalf("Dirty() = %d templates, want 1", len(dirty))
+	}
+
+	// An update between Dirty and MarkPersisted keeps the template dirty
+	m.Add("worker 2 started", now)
+	m.MarkPersisted(dirty)
+	if got := len(m.Dirty()); got != 1 {
+		t.Fatalf("Dirty() after a concurrent update = %d templates, want 1", got)
+	}
+
+	m.MarkPersisted(m.Dirty())
+	if got := len(m.Dirty()); got != 0 {
+		t.Fatalf("Dirty() after MarkPersisted = %d templates, want 0", got)
+	}
+}
+
+func TestMinerLoad(t *testing.T) {
+	src := NewMiner(4, 0.5, 100)
+	match := src.Add("request 17 failed", time.Now())
+
+	m := NewMiner(4, 0.5, 100)
+	m.Load(src.Templates())
+	got := m.Add("request 18 failed", time.Now())
+	if got.TemplateID != match.TemplateID || got.New {
+		t.Fatalf("loaded miner matched %q (new=%v), want %q", got.TemplateID, got.New, match.TemplateID)
+	}
+	if got.Count != 2 {
+		t.Errorf("count = %d, want the persisted count plus one", got.Count)
+	}
+	if tmpl, ok := m.Get(match.TemplateID); !ok || tmpl.Template != "request <*> failed" {
+		t.Errorf("Get = %+v, %v", tmpl, ok)
+	}
+}
+
+func TestIsVariable(t *testing.T) {
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{"42", true},
+		{"0x1f", true},
+		{"user-7", true},
+		{Wildcard, true},
+		{"error", false},
+		{"GET", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isVariable(tt.token); got != tt.want {
+			t.Errorf("isVariable(%q) = %v, want %v", tt.token, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package preprocessing
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Stage types
+const (
+	StageReplace   = "replace"   // replace regex matches, $1 style references expand to groups
+	StageMask      = "mask"      // replace regex matches with a fixed mask
+	StageDrop      = "drop"      // drop the log when the regex matches
+	StageLowercase = "lowercase" // lowercase the text
+	StageTruncate  = "truncate"  // keep the first max_length characters
+	StageTrim      = "trim"      // strip leading and trailing whitespace
+	StageKV        = "kv"        // extract key=value pairs into fields
+)
+
+// DefaultPipeline is the name of the built-in pipeline used when no configured one applies
+const DefaultPipeline = "default"
+
+// StageConfig describes one step of a pipeline; the fields used depend on Type
+type StageConfig struct {
+	Name        string   `yaml:"name,omitempty" json:"name,omitempty"`
+	Type        string   `yaml:"type" json:"type"`
+	Pattern     string   `yaml:"pattern,omitempty" json:"pattern,omitempty"`         // replace, mask, drop
+	Replacement string   `yaml:"replacement,omitempty" json:"replacement,omitempty"` // replace
+	Mask        string   `yaml:"mask,omitempty" json:"mask,omitempty"`               // mask, defaults to [MASKED]
+	MaxLength   int      `yaml:"max_length,omitempty" json:"max_length,omitempty"`   // truncate
+	Separator   string   `yaml:"separator,omitempty" json:"separator,omitempty"`     // kv, defaults to "="
+	Keys        []string `yaml:"keys,omitempty" json:"keys,omitempty"`               // kv, only extract these keys
+	Prefix      string   `yaml:"prefix,omitempty" json:"prefix,omitempty"`           // kv, prepended to field names
+	Remove      bool     `yaml:"remove,omitempty" json:"remove,omitempty"`           // kv, remove the pairs from the text
+}
+
+// PipelineConfig is an ordered list of stages applied to the logs whose metadata
+// carries all the Match values. A pipeline without Match applies to every log.
+type PipelineConfig struct {
+	Name   string            `yaml:"name" json:"name"`
+	Match  map[string]string `yaml:"match,omitempty" json:"match,omitempty"`
+	Stages []StageConfig     `yaml:"stages" json:"stages"`
+}
+
+// PipelineFile is the layout of the preprocessing config file. The first pipeline
+// matching a log is used.
+type PipelineFile struct {
+	Pipelines []PipelineConfig `yaml:"pipelines" json:"pipelines"`
+}
+
+// Result is the outcome of running a log through a pipeline
+type Result struct {
+	Pipeline  string            `json:"pipeline"`
+	Text      string            `json:"text"`
+	Dropped   bool              `json:"dropped,omitempty"`
+	DroppedBy string            `json:"dropped_by,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+// StageTrace is the state of a log after one stage
+type StageTrace struct {
+	Stage   string            `json:"stage"`
+	Type    string            `json:"type"`
+	Output  string            `json:"output"`
+	Dropped bool              `json:"dropped,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+type state struct {
+	text    string
+	fields  map[string]string
+	dropped bool
+}
+
+type stage struct {
+	name  string
+	kind  string
+	apply func(s *state)
+}
+
+// Pipeline is a compiled PipelineConfig
+type Pipeline struct {
+	name   string
+	match  map[string]string
+	stages []stage
+}
+
+// Name returns the pipeline name
+func (p *Pipeline) Name() string { return p.name }
+
+// Run applies the stages in order, stopping at a drop
+func (p *Pipeline) Run(text string) Result {
+	s := &state{text: text}
+	res := Result{Pipeline: p.name}
+	for _, st := range p.stages {
+		st.apply(s)
+		if s.dropped {
+			res.Dropped = true
+			res.DroppedBy = st.name
+			break
+		}
+	}
+	res.Text = s.text
+	res.Fields = s.fields
+	return res
+}
+
+// Trace is Run, recording the output of every stage
+func (p *Pipeline) Trace(text string) (Result, []StageTrace) {
+	s := &state{text: text}
+	res := Result{Pipeline: p.name}
+	trace := make([]StageTrace, 0, len(p.stages))
+	for _, st := range p.stages {
+		st.apply(s)
+		trace = append(trace, StageTrace{
+			Stage:   st.name,
+			Type:    st.kind,
+			Output:  s.text,
+			Dropped: s.dropped,
+			Fields:  copyFields(s.fields),
+		})
+		if s.dropped {
+			res.Dropped = true
+			res.DroppedBy = st.name
+			break
+		}
+	}
+	res.Text = s.text
+	res.Fields = s.fields
+	return res, trace
+}
+
+func (p *Pipeline) matches(metadata map[string]interface{}) bool {
+	for k, want := range p.match {
+		got, ok := metadata[k]
+		if !ok || fmt.Sprint(got) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// CompilePipeline validates a pipeline config and compiles its regular expressions
+func CompilePipeline(cfg PipelineConfig) (*Pipeline, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("pipeline name is required")
+	}
+	p := &Pipeline{name: cfg.Name, match: cfg.Match}
+	for i, sc := range cfg.Stages {
+		st, err := compileStage(sc)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline %q stage %d: %w", cfg.Name, i+1, err)
+		}
+		p.stages = append(p.stages, st)
+	}
+	return p, nil
+}
+
+func mustCompilePipeline(cfg PipelineConfig) *Pipeline {
+	p, err := CompilePipeline(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func compileStage(sc StageConfig) (stage, error) {
+	st := stage{name: sc.Name, kind: sc.Type}
+	if st.name == "" {
+		st.name = sc.Type
+	}
+
+	var re *regexp.Regexp
+	switch sc.Type {
+	case StageReplace, StageMask, StageDrop:
+		if sc.Pattern == "" {
+			return st, fmt.Errorf("%s stage needs a pattern", sc.Type)
+		}
+		var err error
+		if re, err = regexp.Compile(sc.Pattern); err != nil {
+			return st, fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+
+	switch sc.Type {
+	case StageReplace:
+		st.apply = func(s *state) { s.text = re.ReplaceAllString(s.text, sc.Replacement) }
+	case StageMask:
+		mask := sc.Mask
+		if mask == "" {
+			mask = "[MASKED]"
+		}
+		st.apply = func(s *state) { s.text = re.ReplaceAllLiteralString(s.text, mask) }
+	case StageDrop:
+		st.apply = func(s *state) { s.dropped = re.MatchString(s.text) }
+	case StageLowercase:
+		st.apply = func(s *state) { s.text = strings.ToLower(s.text) }
+	case StageTrim:
+		st.apply = func(s *state) { s.text = strings.TrimSpace(s.text) }
+	case StageTruncate:
+		if sc.MaxLength <= 0 {
+			return st, errors.New("truncate stage needs a positive max_length")
+		}
+		st.apply = func(s *state) {
+			if r := []rune(s.text); len(r) > sc.MaxLength {
+				s.text = string(r[:sc.MaxLength])
+			}
+		}
+	case StageKV:
+		apply, err := compileKV(sc)
+		if err != nil {
+			return st, err
+		}
+		st.apply = apply
+	default:
+		return st, fmt.Errorf("unknown stage type %q", sc.Type)
+	}
+	return st, nil
+}
+
+// compileKV builds a stage extracting key<sep>value pairs; values may be double or single quoted
+func compileKV(sc StageConfig) (func(s *state), error) {
+	sep := sc.Separator
+	if sep == "" {
+		sep = "="
+	}
+	re, err := regexp.Compile(`([A-Za-z_][\w.\-]*)` + regexp.QuoteMeta(sep) + `("(?:[^"\\]|\\.)*"|'[^']*'|[^\s,;]+)`)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys map[string]bool
+	if len(sc.Keys) > 0 {
+		keys = make(map[string]bool, len(sc.Keys))
+		for _, k := range sc.Keys {
+			keys[k] = true
+		}
+	}
+
+	return func(s *state) {
+		s.text = re.ReplaceAllStringFunc(s.text, func(pair string) string {
+			m := re.FindStringSubmatch(pair)
+			if keys != nil && !keys[m[1]] {
+				return pair
+			}
+			if s.fields == nil {
+				s.fields = make(map[string]string)
+			}
+			s.fields[sc.Prefix+m[1]] = unquote(m[2])
+			if sc.Remove {
+				return ""
+			}
+			return pair
+		})
+		if sc.Remove {
+			s.text = strings.Join(strings.Fields(s.text), " ")
+		}
+	}, nil
+}
+
+func unquote(v string) string {
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		if u, err := strconv.Unquote(v); err == nil {
+			return u
+		}
+		return v[1 : len(v)-1]
+	}
+	if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+func copyFields(fields map[string]string) map[string]string {
+	if fields == nil {
+		return nil
+	}
+	out := make(map[string]string, len(fields))
+	for k, v := range fields {
+		out[k] = v
+	}
+	return out
+}
+
+// Preprocessor selects and runs the pipeline of every log. Pipelines are loaded from
+// a YAML file and can be reloaded while running; a config that fails to compile is
+// rejected and the previous one stays in use.
+type Preprocessor struct {
+	path string
+
+	mu        sync.RWMutex
+	pipelines []*Pipeline
+	modTime   time.Time
+}
+
+// NewPreprocessor loads the pipelines from path. Without a path, or when the file does
+// not exist, only the default pipeline is used.
+func NewPreprocessor(path string) (*Preprocessor, error) {
+	p := &Preprocessor{path: path}
+	if _, err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload re-reads the config file if it changed since the last load and reports whether it did
+func (p *Preprocessor) Reload() (bool, error) {
+	if p.path == "" {
+		return false, nil
+	}
+
+	info, err := os.Stat(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read preprocessing config: %w", err)
+	}
+
+	p.mu.RLock()
+	unchanged := info.ModTime().Equal(p.modTime)
+	p.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	b, err := os.ReadFile(p.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read preprocessing config: %w", err)
+	}
+	pipelines, err := ParsePipelines(b)
+	if err != nil {
+		// Remember the broken version so it is reported once, not on every poll
+		p.mu.Lock()
+		p.modTime = info.ModTime()
+		p.mu.Unlock()
+		return false, err
+	}
+
+	p.mu.Lock()
+	p.pipelines = pipelines
+	p.modTime = info.ModTime()
+	p.mu.Unlock()
+	return true, nil
+}
+
+// ParsePipelines compiles the pipelines of a YAML config
+func ParsePipelines(b []byte) ([]*Pipeline, error) {
+	var file PipelineFile
+	if err := yaml.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse preprocessing config: %w", err)
+	}
+
+	seen := make(map[string]bool, len(file.Pipelines))
+	pipelines := make([]*Pipeline, 0, len(file.Pipelines))
+	for _, cfg := range file.Pipelines {
+		if seen[cfg.Name] {
+			return nil, fmt.Errorf("duplicate pipeline %q", cfg.Name)
+		}
+		seen[cfg.Name] = true
+
+		pl, err := CompilePipeline(cfg)
+		if err != nil {
+			return nil, err
+		}
+		pipelines = append(pipelines, pl)
+	}
+	return pipelines, nil
+}
+
+// Select returns the first configured pipeline matching the metadata, or the default one
+func (p *Preprocessor) Select(metadata map[string]interface{}) *Pipeline {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, pl := range p.pipelines {
+		if pl.matches(metadata) {
+			return pl
+		}
+	}
+	return defaultPipeline
+}
+
+// Pipeline returns a pipeline by name; a configured pipeline may take over the default name
+func (p *Preprocessor) Pipeline(name string) (*Pipeline, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, pl := range p.pipelines {
+		if pl.name == name {
+			return pl, true
+		}
+	}
+	if name == DefaultPipeline {
+		return defaultPipeline, true
+	}
+	return nil, false
+}
+
+// Process runs the log through the pipeline selected by its metadata
+func (p *Preprocessor) Process(text string, metadata map[string]interface{}) Result {
+	return p.Select(metadata).Run(text)
+}
@@ -0,0 +1,203 @@
+package preprocessing
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipelineStages(t *testing.T) {
+	tests := []struct {
+		name        string
+		stages      []StageConfig
+		in          string
+		wantText    string
+		wantFields  map[string]string
+		wantDropped string
+	}{
+		{
+			name:     "replace expands groups",
+			stages:   []StageConfig{{Type: StageReplace, Pattern: `user=(\w+)`, Replacement: "user:$1"}},
+			in:       "login user=alice ok",
+			wantText: "login user:alice ok",
+		},
+		{
+			name:     "mask defaults to [MASKED]",
+			stages:   []StageConfig{{Type: StageMask, Pattern: `\d{4}-\d{4}`}},
+			in:       "card 1234-5678 charged",
+			wantText: "card [MASKED] charged",
+		},
+		{
+			name:     "mask is literal",
+			stages:   []StageConfig{{Type: StageMask, Pattern: `secret`, Mask: "$1"}},
+			in:       "a secret",
+			wantText: "a $1",
+		},
+		{
+			name: "drop stops the pipeline",
+			stages: []StageConfig{
+				{Name: "healthchecks", Type: StageDrop, Pattern: `GET /health`},
+				{Type: StageLowercase},
+			},
+			in:          "GET /health 200",
+			wantText:    "GET /health 200",
+			wantDropped: "healthchecks",
+		},
+		{
+			name:     "drop without match keeps the log",
+			stages:   []StageConfig{{Type: StageDrop, Pattern: `GET /health`}, {Type: StageLowercase}},
+			in:       "POST /Login",
+			wantText: "post /login",
+		},
+		{
+			name:     "trim",
+			stages:   []StageConfig{{Type: StageTrim}},
+			in:       "  padded \t",
+			wantText: "padded",
+		},
+		{
+			name:     "truncate counts runes",
+			stages:   []StageConfig{{Type: StageTruncate, MaxLength: 3}},
+			in:       "héllo",
+			wantText: "hél",
+		},
+		{
+			name:       "kv extracts quoted values",
+			stages:     []StageConfig{{Type: StageKV}},
+			in:         `status=200 msg="not found" path='/a b'`,
+			wantText:   `status=200 msg="not found" path='/a b'`,
+			wantFields: map[string]string{"status": "200", "msg": "not found", "path": "/a b"},
+		},
+		{
+			name:       "kv with keys, prefix and removal",
+			stages:     []StageConfig{{Type: StageKV, Separator: ":", Keys: []string{"user"}, Prefix: "kv.", Remove: true}},
+			in:         "request user:bob ip:10.0.0.1 done",
+			wantText:   "request ip:10.0.0.1 done",
+			wantFields: map[string]string{"kv.user": "bob"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := CompilePipeline(PipelineConfig{Name: "test", Stages: tt.stages})
+			if err != nil {
+				t.Fatalf("CompilePipeline: %v", err)
+			}
+			res := p.Run(tt.in)
+			if res.Text != tt.wantText {
+				t.Errorf("text = %q, want %q", res.Text, tt.wantText)
+			}
+			if !reflect.DeepEqual(res.Fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", res.Fields, tt.wantFields)
+			}
+			if res.Dropped != (tt.wantDropped != "") || res.DroppedBy != tt.wantDropped {
+				t.Errorf("dropped = %v by %q, want by %q", res.Dropped, res.DroppedBy, tt.wantDropped)
+			}
+
+			// Trace must agree with Run
+			traced, trace := p.Trace(tt.in)
+			if !reflect.DeepEqual(traced, res) {
+				t.Errorf("Trace result = %+v, Run result = %+v", traced, res)
+			}
+			if len(trace) == 0 || trace[len(trace)-1].Output != res.Text {
+				t.Errorf("last trace step does not match the result: %+v", trace)
+			}
+		})
+	}
+}
+
+func TestCompilePipelineErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  PipelineConfig
+		want string
+	}{
+		{"missing name", PipelineConfig{}, "name is required"},
+		{"missing pattern", PipelineConfig{Name: "p", Stages: []StageConfig{{Type: StageMask}}}, "needs a pattern"},
+		{"bad pattern", PipelineConfig{Name: "p", Stages: []StageConfig{{Type: StageDrop, Pattern: "("}}}, "invalid pattern"},
+		{"bad truncate", PipelineConfig{Name: "p", Stages: []StageConfig{{Type: StageTruncate}}}, "max_length"},
+		{"unknown type", PipelineConfig{Name: "p", Stages: []StageConfig{{Type: "upcase"}}}, "unknown stage type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompilePipeline(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePipelinesRejectsDuplicates(t *testing.T) {
+	_, err := ParsePipelines([]byte(`
+pipelines:
+  - name: a
+    stages: [{type: trim}]
+  - name: a
+    stages: [{type: lowercase}]
+`))
+	if err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Fatalf("err = %v, want a duplicate pipeline error", err)
+	}
+}
+
+func TestPreprocessorSelectAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipelines.yaml")
+	write := func(content string, mod time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write(`
+pipelines:
+  - name: nginx
+    match: {service: nginx}
+    stages: [{type: lowercase}]
+`, start)
+
+	p, err := NewPreprocessor(path)
+	if err != nil {
+		t.Fatalf("NewPreprocessor: %v", err)
+	}
+	if got := p.Select(map[string]interface{}{"service": "nginx"}).Name(); got != "nginx" {
+		t.Errorf("nginx log selected %q", got)
+	}
+	if got := p.Select(map[string]interface{}{"service": "db"}).Name(); got != DefaultPipeline {
+		t.Errorf("db log selected %q, want the default pipeline", got)
+	}
+	if res := p.Process("GET /Index", map[string]interface{}{"service": "nginx"}); res.Text != "get /index" {
+		t.Errorf("processed text = %q", res.Text)
+	}
+
+	// A broken config is rejected and the previous pipelines stay in use
+	write("pipelines: [{name: nginx, stages: [{type: drop}]}]", start.Add(time.Minute))
+	if changed, err := p.Reload(); err == nil || changed {
+		t.Fatalf("Reload of a broken config = %v, %v", changed, err)
+	}
+	if _, ok := p.Pipeline("nginx"); !ok {
+		t.Fatal("previous pipeline was dropped after a failed reload")
+	}
+
+	write("pipelines: [{name: db, match: {service: db}, stages: [{type: trim}]}]", start.Add(2*time.Minute))
+	if changed, err := p.Reload(); err != nil || !changed {
+		t.Fatalf("Reload = %v, %v", changed, err)
+	}
+	if changed, err := p.Reload(); err != nil || changed {
+		t.Fatalf("Reload of an unchanged file = %v, %v", changed, err)
+	}
+	if _, ok := p.Pipeline("nginx"); ok {
+		t.Error("nginx pipeline still present after reload")
+	}
+	if got := p.Select(map[string]interface{}{"service": "db"}).Name(); got != "db" {
+		t.Errorf("db log selected %q after reload", got)
+	}
+}
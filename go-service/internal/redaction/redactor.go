@@ -0,0 +1,297 @@
+package redaction
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// Redaction types
+const (
+	TypeURLPassword = "url_password"
+	TypeJWT         = "jwt"
+	TypeBearer      = "bearer_token"
+	TypeAWSKey      = "aws_key"
+	TypeEmail       = "email"
+	TypeUUID        = "uuid"
+	TypeCard        = "credit_card"
+	TypeIPv6        = "ipv6"
+	TypeIPv4        = "ipv4"
+)
+
+// AllTypes lists every redaction type in the order they are applied. Credentials come
+// first so that, e.g., the user:password@host part of a URL is not taken for an email.
+var AllTypes = []string{
+	TypeURLPassword,
+	TypeJWT,
+	TypeBearer,
+	TypeAWSKey,
+	TypeEmail,
+	TypeUUID,
+	TypeCard,
+	TypeIPv6,
+	TypeIPv4,
+}
+
+type detector struct {
+	typ   string
+	label string         // placeholder name, e.g. EMAIL for [REDACTED_EMAIL]
+	re    *regexp.Regexp // the value to redact is the last capture group, or the whole match
+	valid func(value string) bool
+	bound bool           // the match must not touch word characters
+	after *regexp.Regexp // when set, matches left alone are preceded by text matching it
+}
+
+var detectors = map[string]detector{
+	TypeURLPassword: {
+		typ:   TypeURLPassword,
+		label: "PASSWORD",
+		re:    regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.\-]*://[^\s:/@]+:([^\s@/]+)@`),
+	},
+	TypeJWT: {
+		typ:   TypeJWT,
+		label: "JWT",
+		re:    regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`),
+	},
+	TypeBearer: {
+		typ:   TypeBearer,
+		label: "TOKEN",
+		re:    regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`),
+	},
+	TypeAWSKey: {
+		typ:   TypeAWSKey,
+		label: "AWS_KEY",
+		re:    regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA)[A-Z0-9]{16}\b|(?i:aws_secret_access_key|secret_access_key)\s*[=:]\s*"?([A-Za-z0-9/+=]{40})`),
+	},
+	TypeEmail: {
+		typ:   TypeEmail,
+		label: "EMAIL",
+		re:    regexp.MustCompile(`\b[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}\b`),
+	},
+	TypeUUID: {
+		typ:   TypeUUID,
+		label: "UUID",
+		re:    regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`),
+	},
+	TypeCard: {
+		typ:   TypeCard,
+		label: "CARD",
+		re:    regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+		valid: isCard,
+		// JSON numbers and timestamps are numeric fields, not card numbers typed into a log
+		after: regexp.MustCompile(`(?i)(?:"\s*:\s*|\b(?:ts|time|timestamp|epoch)\s*[=:]\s*)$`),
+	},
+	TypeIPv6: {
+		typ:   TypeIPv6,
+		label: "IPV6",
+		re:    regexp.MustCompile(`[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}(?:(?:\.\d{1,3}){3})?`),
+		valid: isIPv6,
+		bound: true,
+	},
+	TypeIPv4: {
+		typ:   TypeIPv4,
+		label: "IP",
+		re:    regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`),
+	},
+}
+
+// Config selects the redaction types and whether values are pseudonymized
+type Config struct {
+	Types []string // empty means AllTypes
+	// HashKey, when set, turns placeholders into [REDACTED_EMAIL:<hmac>] so the same
+	// value always maps to the same token without revealing it
+	HashKey string
+}
+
+// Redactor replaces personal data and secrets in log text with placeholder tokens
+type Redactor struct {
+	detectors []detector
+	key       []byte
+}
+
+// New creates a redactor for the configured types
+func New(cfg Config) (*Redactor, error) {
+	types := cfg.Types
+	if len(types) == 0 {
+		types = AllTypes
+	}
+
+	enabled := make(map[string]bool, len(types))
+	for _, t := range types {
+		if _, ok := detectors[t]; !ok {
+			return nil, fmt.Errorf("unknown redaction type %q", t)
+		}
+		enabled[t] = true
+	}
+
+	r := &Redactor{}
+	if cfg.HashKey != "" {
+		r.key = []byte(cfg.HashKey)
+	}
+	for _, t := range AllTypes {
+		if enabled[t] {
+			r.detectors = append(r.detectors, detectors[t])
+		}
+	}
+	return r, nil
+}
+
+// Redact returns text with every detected value replaced by its placeholder
+func (r *Redactor) Redact(text string) string {
+	for _, d := range r.detectors {
+		text = r.apply(d, text)
+	}
+	return text
+}
+
+func (r *Redactor) apply(d detector, text string) string {
+	matches := d.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last, redacted := 0, 0
+	for _, m := range matches {
+		// Redact the last participating capture group, or the whole match
+		start, end := m[0], m[1]
+		for g := len(m)/2 - 1; g > 0; g-- {
+			if m[2*g] >= 0 {
+				start, end = m[2*g], m[2*g+1]
+				break
+			}
+		}
+
+		value := text[start:end]
+		if d.valid != nil && !d.valid(value) {
+			continue
+		}
+		if d.bound && touchesWord(text, m[0], m[1]) {
+			continue
+		}
+		if d.after != nil && d.after.MatchString(text[max(0, m[0]-32):m[0]]) {
+			continue
+		}
+
+		b.WriteString(text[last:start])
+		b.WriteString(r.placeholder(d, value))
+		last = end
+		redacted++
+	}
+	if redacted == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+
+	metrics.RedactionsTotal.WithLabelValues(d.typ).Add(float64(redacted))
+	return b.String()
+}
+
+func (r *Redactor) placeholder(d detector, value string) string {
+	if r.key == nil {
+		return "[REDACTED_" + d.label + "]"
+	}
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(d.typ + ":" + value))
+	return "[REDACTED_" + d.label + ":" + hex.EncodeToString(mac.Sum(nil))[:12] + "]"
+}
+
+// touchesWord reports whether text[start:end] is glued to a letter, digit or underscore
+func touchesWord(text string, start, end int) bool {
+	if start > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(text[:start]); isWord(r) {
+			return true
+		}
+	}
+	if end < len(text) {
+		if r, _ := utf8.DecodeRuneInString(text[end:]); isWord(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWord(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isIPv6 accepts IPv6 addresses with at least one decimal digit: "::" and words such as
+// Abc::Def are more often C++ or Ruby scoping
+func isIPv6(s string) bool {
+	return strings.Contains(s, ":") && strings.ContainsAny(s, "0123456789") && net.ParseIP(s) != nil
+}
+
+// isCard accepts numbers passing the Luhn check that are either written in groups, like
+// 4111 1111 1111 1111, or start with the issuer prefix of a card network. Plain digit
+// runs without one, such as millisecond timestamps, are left alone.
+func isCard(s string) bool {
+	if !luhn(s) {
+		return false
+	}
+	if strings.ContainsAny(s, " -") {
+		return true
+	}
+	return knownIssuer(s)
+}
+
+// knownIssuer reports whether digits start with the issuer identification number of
+// Visa, Mastercard, American Express, Discover, JCB or Diners Club
+func knownIssuer(digits string) bool {
+	prefix := func(n int) int {
+		v := 0
+		for _, c := range digits[:n] {
+			v = v*10 + int(c-'0')
+		}
+		return v
+	}
+	n := len(digits)
+	switch p1, p2, p3, p4 := prefix(1), prefix(2), prefix(3), prefix(4); {
+	case p1 == 4:
+		return n == 13 || n == 16 || n == 19
+	case p2 >= 51 && p2 <= 55, p4 >= 2221 && p4 <= 2720:
+		return n == 16
+	case p2 == 34, p2 == 37:
+		return n == 15
+	case p4 == 6011, p2 == 65, p3 >= 644 && p3 <= 649:
+		return n >= 16
+	case p4 >= 3528 && p4 <= 3589:
+		return n >= 16
+	case p2 == 36, p2 == 38, p2 == 39, p3 >= 300 && p3 <= 305:
+		return n >= 14
+	}
+	return false
+}
+
+// luhn reports whether the digits of s form a 13 to 19 digit number passing the Luhn check
+func luhn(s string) bool {
+	var digits []int
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if (len(digits)-1-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
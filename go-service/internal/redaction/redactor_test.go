@@ -0,0 +1,105 @@
+package redaction
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func newRedactor(t *testing.T, cfg Config) *Redactor {
+	t.Helper()
+	r, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRedact(t *testing.T) {
+	r := newRedactor(t, Config{})
+	tests := []struct {
+		name, in, want string
+	}{
+		{"card in groups", "paid with 4111 1111 1111 1111 today", "paid with [REDACTED_CARD] today"},
+		{"card with dashes", "card=5500-0000-0000-0004", "card=[REDACTED_CARD]"},
+		{"card of a known issuer", "pan 378282246310005 declined", "pan [REDACTED_CARD] declined"},
+		{"card in a JSON string", `{"card":"4111111111111111"}`, `{"card":"[REDACTED_CARD]"}`},
+		{"failing the Luhn check", "paid with 4111 1111 1111 1112", "paid with 4111 1111 1111 1112"},
+		{"millisecond timestamp", "ts=1760000000074 done", "ts=1760000000074 done"},
+		{"timestamp key", "time: 4111111111111111", "time: 4111111111111111"},
+		{"JSON number", `{"id": 4111111111111111}`, `{"id": 4111111111111111}`},
+		{"digit run without an issuer", "order 9999999999999995 shipped", "order 9999999999999995 shipped"},
+		{"IPv6", "from fe80::1ff:fe23:4567:890a port 22", "from [REDACTED_IPV6] port 22"},
+		{"IPv6 loopback", "bound to ::1", "bound to [REDACTED_IPV6]"},
+		{"scoping without digits", "raised Abc::Def and Foo::Bar", "raised Abc::Def and Foo::Bar"},
+		{"bare scope", "call ::main", "call ::main"},
+		{"IPv4", "client 10.0.0.12 connected", "client [REDACTED_IP] connected"},
+		{"email", "mail ana@example.com now", "mail [REDACTED_EMAIL] now"},
+		{"URL password", "dsn postgres://app:s3cret@db:5432/x", "dsn postgres://app:[REDACTED_PASSWORD]@db:5432/x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactKeepsJSONValid(t *testing.T) {
+	r := newRedactor(t, Config{})
+	in := `{"ts":1760000000074,"level":"info","user":"ana@example.com","card":"4111-1111-1111-1111","ms":4111111111111111}`
+	out := r.Redact(in)
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &v); err != nil {
+		t.Fatalf("redacted %s is not valid JSON: %v", out, err)
+	}
+	if v["user"] != "[REDACTED_EMAIL]" || v["card"] != "[REDACTED_CARD]" {
+		t.Errorf("redacted %s", out)
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"4111111111111111", true},
+		{"4111-1111-1111-1111", true},
+		{"378282246310005", true},
+		{"6011111111111117", true},
+		{"4111111111111112", false},
+		{"123456789012", false},         // too short
+		{"41111111111111111111", false}, // too long
+	}
+	for _, tt := range tests {
+		if got := luhn(tt.in); got != tt.want {
+			t.Errorf("luhn(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHashedPlaceholders(t *testing.T) {
+	a := newRedactor(t, Config{Types: []string{TypeEmail}, HashKey: "key-a"})
+	b := newRedactor(t, Config{Types: []string{TypeEmail}, HashKey: "key-b"})
+
+	first := a.Redact("from ana@example.com")
+	if first != a.Redact("from ana@example.com") {
+		t.Error("the same value got different tokens")
+	}
+	if !strings.HasPrefix(first, "from [REDACTED_EMAIL:") || strings.Contains(first, "ana") {
+		t.Errorf("Redact = %q", first)
+	}
+	if first == a.Redact("from bob@example.com") {
+		t.Error("different values got the same token")
+	}
+	if first == b.Redact("from ana@example.com") {
+		t.Error("different keys gave the same token")
+	}
+}
+
+func TestNewRejectsUnknownTypes(t *testing.T) {
+	if _, err := New(Config{Types: []string{"phone"}}); err == nil {
+		t.Error("New accepted an unknown type")
+	}
+}
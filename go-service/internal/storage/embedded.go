@@ -0,0 +1,467 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	bolt "go.etcd.io/bbolt"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+)
+
+var (
+	logsBucket     = []byte("logs")         // log ID -> JSON document
+	byTimeBucket   = []byte("logs_by_time") // event time + log ID -> nothing, for time ordered scans
+	feedbackBucket = []byte("feedback")     // log ID -> JSON verdict
+)
+
+// bucketFormat matches the key_as_string of the Elasticsearch date histograms
+const bucketFormat = "2006-01-02T15:04:05.000Z"
+
+// EmbeddedStore keeps logs in a single local file, for single-node deployments and tests
+// that run without Elasticsearch. Queries scan the logs newest first, so it suits
+// volumes of up to a few million logs; text search matches whole words, ignoring case.
+type EmbeddedStore struct {
+	db *bolt.DB
+}
+
+// NewEmbeddedStore opens the store at path, creating it and its directory when needed
+func NewEmbeddedStore(path string) (*EmbeddedStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create embedded store directory: %w", err)
+		}
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded store %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{logsBucket, byTimeBucket, feedbackBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize embedded store: %w", err)
+	}
+	return &EmbeddedStore{db: db}, nil
+}
+
+// Close releases the store file
+func (s *EmbeddedStore) Close() error {
+	return s.db.Close()
+}
+
+// IndexLog stores a log, replacing any earlier log with the same ID
+func (s *EmbeddedStore) IndexLog(ctx context.Context, doc *elastic.LogDocument) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putLog(tx, doc)
+	})
+}
+
+// BulkIndexLogs stores several logs in a single transaction
+func (s *EmbeddedStore) BulkIndexLogs(ctx context.Context, docs []elastic.LogDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for i := range docs {
+			if err := putLog(tx, &docs[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func putLog(tx *bolt.Tx, doc *elastic.LogDocument) error {
+	logs, byTime := tx.Bucket(logsBucket), tx.Bucket(byTimeBucket)
+	id := []byte(doc.ID)
+	if len(id) == 0 {
+		return fmt.Errorf("log has no ID")
+	}
+
+	// A re-indexed log may have moved in time
+	if old := logs.Get(id); old != nil {
+		var prev elastic.LogDocument
+		if err := json.Unmarshal(old, &prev); err == nil {
+			if err := byTime.Delete(timeKey(prev.Timestamp, doc.ID)); err != nil {
+				return err
+			}
+		}
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal document: %w", err)
+	}
+	if err := logs.Put(id, b); err != nil {
+		return err
+	}
+	return byTime.Put(timeKey(doc.Timestamp, doc.ID), nil)
+}
+
+// timeKey orders logs by event time; flipping the sign bit makes times before 1970
+// sort before later ones
+func timeKey(t time.Time, id string) []byte {
+	key := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano())^(1<<63))
+	return append(key, id...)
+}
+
+// GetLog retrieves a stored log by ID; it returns nil when the log does not exist
+func (s *EmbeddedStore) GetLog(ctx context.Context, id string) (*elastic.LogDocument, error) {
+	var doc *elastic.LogDocument
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(logsBucket).Get([]byte(id))
+		if b == nil {
+			return nil
+		}
+		doc = &elastic.LogDocument{}
+		return json.Unmarshal(b, doc)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log %s: %w", id, err)
+	}
+	return doc, nil
+}
+
+// SetHumanLabel records the analyst label on a stored log and makes it the log's effective label
+func (s *EmbeddedStore) SetHumanLabel(ctx context.Context, logID, label string, at time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		logs := tx.Bucket(logsBucket)
+		b := logs.Get([]byte(logID))
+		if b == nil {
+			return fmt.Errorf("log %s not found", logID)
+		}
+		var doc elastic.LogDocument
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return err
+		}
+		doc.HumanLabel = label
+		doc.EffectiveLabel = label
+		doc.FeedbackAt = &at
+		out, err := json.Marshal(&doc)
+		if err != nil {
+			return err
+		}
+		return logs.Put([]byte(logID), out)
+	})
+}
+
+// SaveFeedback stores a verdict, replacing any earlier verdict on the same log
+func (s *EmbeddedStore) SaveFeedback(ctx context.Context, doc *elastic.FeedbackDocument) error {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal feedback: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(feedbackBucket).Put([]byte(doc.LogID), b)
+	})
+}
+
+// GetFeedback retrieves the verdict on a log; it returns nil when there is none
+func (s *EmbeddedStore) GetFeedback(ctx context.Context, logID string) (*elastic.FeedbackDocument, error) {
+	var doc *elastic.FeedbackDocument
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(feedbackBucket).Get([]byte(logID))
+		if b == nil {
+			return nil
+		}
+		doc = &elastic.FeedbackDocument{}
+		return json.Unmarshal(b, doc)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feedback on log %s: %w", logID, err)
+	}
+	return doc, nil
+}
+
+// ScanFeedback calls fn for every stored verdict, oldest first. Verdicts are keyed by log,
+// so they are read and sorted in memory; there is one per log an analyst judged.
+func (s *EmbeddedStore) ScanFeedback(ctx context.Context, fn func(elastic.FeedbackDocument) error) error {
+	var docs []elastic.FeedbackDocument
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(feedbackBucket).ForEach(func(_, b []byte) error {
+			var doc elastic.FeedbackDocument
+			if err := json.Unmarshal(b, &doc); err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read feedback: %w", err)
+	}
+
+	sort.Slice(docs, func(i, j int) bool {
+		if !docs[i].Timestamp.Equal(docs[j].Timestamp) {
+			return docs[i].Timestamp.Before(docs[j].Timestamp)
+		}
+		return docs[i].LogID < docs[j].LogID
+	})
+	for _, doc := range docs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PageLogs retrieves a page of the logs matching q, newest first, with their total count.
+// Cursors hold the event time and ID of the last log of the page; the total is counted
+// over all the stored logs, so every page scans them all.
+func (s *EmbeddedStore) PageLogs(ctx context.Context, q elastic.LogQuery) (*elastic.LogPage, error) {
+	cur := elastic.LogCursor{Query: q}
+	var after []byte
+	if q.Cursor != "" {
+		var err error
+		if cur, err = elastic.DecodeLogCursor(q.Cursor); err != nil {
+			return nil, err
+		}
+		if after, err = cursorKey(cur.After); err != nil {
+			return nil, err
+		}
+	} else {
+		cur.Query.From = q.From
+	}
+
+	words := tokenize(cur.Query.Text)
+	skip := cur.Query.From
+	page := &elastic.LogPage{Logs: []elastic.LogDocument{}}
+	more := false
+	err := s.scan(ctx, cur.Query.Start, cur.Query.End, func(doc *elastic.LogDocument) bool {
+		if !matches(doc, cur.Query, words) {
+			return true
+		}
+		page.Total++
+		switch {
+		case after != nil && bytes.Compare(timeKey(doc.Timestamp, doc.ID), after) >= 0:
+		case skip > 0:
+			skip--
+		case len(page.Logs) < q.Size:
+			page.Logs = append(page.Logs, *doc)
+		case q.Size > 0:
+			more = true
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if more {
+		last := page.Logs[len(page.Logs)-1]
+		cur.After = []interface{}{last.Timestamp.Format(time.RFC3339Nano), last.ID}
+		if page.NextCursor, err = elastic.EncodeLogCursor(cur); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// cursorKey returns the time key of the log a cursor stopped at
+func cursorKey(after []interface{}) ([]byte, error) {
+	if len(after) != 2 {
+		return nil, elastic.ErrInvalidCursor
+	}
+	ts, ok := after[0].(string)
+	id, ok2 := after[1].(string)
+	if !ok || !ok2 {
+		return nil, elastic.ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, elastic.ErrInvalidCursor
+	}
+	return timeKey(t, id), nil
+}
+
+func matches(doc *elastic.LogDocument, q elastic.LogQuery, words []string) bool {
+	if q.AnomaliesOnly && (!doc.IsAnomaly || (doc.Suppressed && !q.IncludeSuppressed)) {
+		return false
+	}
+	if q.TemplateID != "" && doc.TemplateID != q.TemplateID {
+		return false
+	}
+	if q.IncidentID != "" && doc.IncidentID != q.IncidentID {
+		return false
+	}
+	if len(words) == 0 {
+		return q.Text == ""
+	}
+	for _, w := range tokenize(doc.LogText) {
+		for _, qw := range words {
+			if w == qw {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tokenize splits text into lowercase words, roughly like the standard analyzer of Elasticsearch
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// scan calls fn for the logs between start and end, newest first, until fn returns false;
+// zero times leave the range open
+func (s *EmbeddedStore) scan(ctx context.Context, start, end time.Time, fn func(*elastic.LogDocument) bool) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		logs := tx.Bucket(logsBucket)
+		c := tx.Bucket(byTimeBucket).Cursor()
+
+		var k []byte
+		if end.IsZero() {
+			k, _ = c.Last()
+		} else if k, _ = c.Seek(timeKey(end.Add(time.Nanosecond), "")); k == nil {
+			k, _ = c.Last()
+		} else {
+			k, _ = c.Prev()
+		}
+		var lower []byte
+		if !start.IsZero() {
+			lower = timeKey(start, "")
+		}
+
+		for n := 0; k != nil; k, _ = c.Prev() {
+			if lower != nil && bytes.Compare(k, lower) < 0 {
+				return nil
+			}
+			if n++; n%1000 == 0 && ctx.Err() != nil {
+				return ctx.Err()
+			}
+			b := logs.Get(k[8:])
+			if b == nil {
+				continue
+			}
+			var doc elastic.LogDocument
+			if err := json.Unmarshal(b, &doc); err != nil {
+				return fmt.Errorf("failed to decode log %s: %w", k[8:], err)
+			}
+			if !fn(&doc) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// GetAnomalyStats counts the anomalies between startTime and endTime per hour, leaving out
+// suppressed ones unless includeSuppressed is set
+func (s *EmbeddedStore) GetAnomalyStats(ctx context.Context, startTime, endTime time.Time, includeSuppressed bool) (map[string]interface{}, error) {
+	total := 0
+	hist := newHistogram()
+	err := s.scan(ctx, startTime, endTime, func(doc *elastic.LogDocument) bool {
+		if doc.IsAnomaly && (includeSuppressed || !doc.Suppressed) {
+			total++
+			hist.add(doc.Timestamp)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"total_anomalies":     total,
+		"anomalies_over_time": hist.buckets(),
+		"time_range": map[string]string{
+			"start": startTime.Format(time.RFC3339),
+			"end":   endTime.Format(time.RFC3339),
+		},
+	}, nil
+}
+
+// GetLogStats counts the logs between startTime and endTime, in total, by outcome and per hour
+func (s *EmbeddedStore) GetLogStats(ctx context.Context, startTime, endTime time.Time) (map[string]interface{}, error) {
+	total, anomalies, suppressed := 0, 0, 0
+	hist := newHistogram()
+	err := s.scan(ctx, startTime, endTime, func(doc *elastic.LogDocument) bool {
+		total++
+		hist.add(doc.Timestamp)
+		switch {
+		case doc.Suppressed:
+			suppressed++
+		case doc.IsAnomaly:
+			anomalies++
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rate := 0.0
+	if total > 0 {
+		rate = float64(anomalies) / float64(total)
+	}
+	return map[string]interface{}{
+		"total_logs":       total,
+		"anomaly_count":    anomalies,
+		"suppressed_count": suppressed,
+		"normal_count":     total - anomalies - suppressed,
+		"logs_over_time":   hist.buckets(),
+		"anomaly_rate":     rate,
+		"time_range": map[string]string{
+			"start": startTime.Format(time.RFC3339),
+			"end":   endTime.Format(time.RFC3339),
+		},
+	}, nil
+}
+
+// histogram counts logs per hour; like a date histogram, empty hours between the first
+// and the last counted one are reported too
+type histogram struct {
+	counts     map[int64]int
+	first, end int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make(map[int64]int)}
+}
+
+func (h *histogram) add(t time.Time) {
+	hour := t.UTC().Truncate(time.Hour).Unix()
+	if len(h.counts) == 0 || hour < h.first {
+		h.first = hour
+	}
+	if len(h.counts) == 0 || hour > h.end {
+		h.end = hour
+	}
+	h.counts[hour]++
+}
+
+func (h *histogram) buckets() []elastic.HistogramBucket {
+	buckets := []elastic.HistogramBucket{}
+	if len(h.counts) == 0 {
+		return buckets
+	}
+	for hour := h.first; hour <= h.end; hour += 3600 {
+		buckets = append(buckets, elastic.HistogramBucket{
+			KeyAsString: time.Unix(hour, 0).UTC().Format(bucketFormat),
+			DocCount:    h.counts[hour],
+		})
+	}
+	return buckets
+}
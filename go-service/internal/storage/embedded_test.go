@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+)
+
+func TestEmbeddedFeedback(t *testing.T) {
+	s, err := NewEmbeddedStore(filepath.Join(t.TempDir(), "logs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if err := s.SetHumanLabel(ctx, "missing", "anomaly", time.Now()); err == nil {
+		t.Error("labelled a log that does not exist")
+	}
+	if prev, err := s.GetFeedback(ctx, "log-1"); err != nil || prev != nil {
+		t.Fatalf("GetFeedback before any verdict = %v, %v", prev, err)
+	}
+
+	t0 := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := s.IndexLog(ctx, &elastic.LogDocument{ID: "log-1", Timestamp: t0, LogText: "disk full"}); err != nil {
+		t.Fatal(err)
+	}
+	verdicts := []elastic.FeedbackDocument{
+		{LogID: "log-2", Verdict: "normal", Timestamp: t0.Add(time.Minute)},
+		{LogID: "log-1", Verdict: "normal", Timestamp: t0},
+		{LogID: "log-1", Verdict: "anomaly", Timestamp: t0.Add(2 * time.Minute)}, // replaces the first verdict on log-1
+		{LogID: "log-3", Verdict: "anomaly", Timestamp: t0.Add(time.Minute)},
+	}
+	for i := range verdicts {
+		if err := s.SaveFeedback(ctx, &verdicts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetHumanLabel(ctx, "log-1", "anomaly", t0); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetFeedback(ctx, "log-1")
+	if err != nil || got == nil || got.Verdict != "anomaly" {
+		t.Errorf("GetFeedback = %+v, %v", got, err)
+	}
+	doc, err := s.GetLog(ctx, "log-1")
+	if err != nil || doc.HumanLabel != "anomaly" || doc.EffectiveLabel != "anomaly" {
+		t.Errorf("GetLog = %+v, %v", doc, err)
+	}
+
+	var order []string
+	err = s.ScanFeedback(ctx, func(doc elastic.FeedbackDocument) error {
+		order = append(order, doc.LogID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"log-2", "log-3", "log-1"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("scanned %q, want %q", order, want)
+	}
+}
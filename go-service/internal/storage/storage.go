@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+)
+
+// Storage backends
+const (
+	BackendElasticsearch = "elasticsearch"
+	BackendEmbedded      = "embedded"
+)
+
+// LogStore persists processed logs and answers the queries of the log API;
+// *elastic.Client and *EmbeddedStore implement it
+type LogStore interface {
+	IndexLog(ctx context.Context, doc *elastic.LogDocument) error
+	BulkIndexLogs(ctx context.Context, docs []elastic.LogDocument) error
+	// GetLog returns nil when the log does not exist
+	GetLog(ctx context.Context, id string) (*elastic.LogDocument, error)
+	PageLogs(ctx context.Context, q elastic.LogQuery) (*elastic.LogPage, error)
+	GetAnomalyStats(ctx context.Context, startTime, endTime time.Time, includeSuppressed bool) (map[string]interface{}, error)
+	GetLogStats(ctx context.Context, startTime, endTime time.Time) (map[string]interface{}, error)
+	SetHumanLabel(ctx context.Context, logID, label string, at time.Time) error
+
+	// SaveFeedback stores an analyst verdict, replacing any earlier verdict on the same log
+	SaveFeedback(ctx context.Context, doc *elastic.FeedbackDocument) error
+	// GetFeedback returns nil when the log has no verdict
+	GetFeedback(ctx context.Context, logID string) (*elastic.FeedbackDocument, error)
+	// ScanFeedback calls fn for every verdict, oldest first
+	ScanFeedback(ctx context.Context, fn func(elastic.FeedbackDocument) error) error
+}
@@ -0,0 +1,258 @@
+package suppression
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+)
+
+var (
+	// ErrNotFound is returned when a rule ID does not exist
+	ErrNotFound = errors.New("suppression rule not found")
+	// ErrExists is returned when creating a rule with an ID that is already used
+	ErrExists = errors.New("suppression rule already exists")
+	// ErrInvalid wraps the errors of rules that fail validation
+	ErrInvalid = errors.New("invalid suppression rule")
+)
+
+// Rule silences anomalies that are known to be noise
+type Rule = elastic.SuppressionRule
+
+// Store persists suppression rules; *elastic.Client implements it
+type Store interface {
+	SaveSuppression(ctx context.Context, rule *elastic.SuppressionRule) error
+	DeleteSuppression(ctx context.Context, id string) error
+	GetSuppressions(ctx context.Context) ([]elastic.SuppressionRule, error)
+}
+
+type compiled struct {
+	rule    Rule
+	pattern *regexp.Regexp
+}
+
+// Set holds the suppression rules applied in the ingestion path
+type Set struct {
+	store Store
+
+	// writeMu serializes changes, which are stored before they take effect; mu is only
+	// held to swap them in, so matching never waits for the store
+	writeMu sync.Mutex
+
+	mu    sync.RWMutex
+	rules map[string]*compiled
+}
+
+// NewSet creates an empty set; store may be nil, in which case rules only live in memory
+func NewSet(store Store) *Set {
+	return &Set{store: store, rules: make(map[string]*compiled)}
+}
+
+// Validate checks that a rule has at least one condition and that its pattern compiles
+func Validate(r *Rule) error {
+	if r.Pattern == "" && r.TemplateID == "" && len(r.Match) == 0 {
+		return fmt.Errorf("%w: it needs at least one of pattern, template_id or match", ErrInvalid)
+	}
+	if r.Pattern != "" {
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("%w: bad pattern: %v", ErrInvalid, err)
+		}
+	}
+	return nil
+}
+
+// Load replaces the rules with the stored ones, e.g. at startup
+func (s *Set) Load(ctx context.Context) error {
+	if s.store == nil {
+		return nil
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	rules, err := s.store.GetSuppressions(ctx)
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]*compiled, len(rules))
+	for _, r := range rules {
+		c, err := compile(r)
+		if err != nil {
+			return fmt.Errorf("rule %q: %w", r.ID, err)
+		}
+		loaded[r.ID] = c
+	}
+
+	s.mu.Lock()
+	s.rules = loaded
+	s.mu.Unlock()
+	return nil
+}
+
+// Match returns the ID of the first unexpired rule matching the log, by rule ID order
+func (s *Set) Match(text, templateID string, metadata map[string]interface{}, at time.Time) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matched []string
+	for id, c := range s.rules {
+		if c.matches(text, templateID, metadata, at) {
+			matched = append(matched, id)
+		}
+	}
+	if len(matched) == 0 {
+		return "", false
+	}
+	sort.Strings(matched)
+	return matched[0], true
+}
+
+// Rules returns all rules, including expired ones, by ID
+func (s *Set) Rules() []Rule {
+	s.mu.RLock()
+	out := make([]Rule, 0, len(s.rules))
+	for _, c := range s.rules {
+		out = append(out, c.rule)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Rule returns the rule with the given ID
+func (s *Set) Rule(id string) (Rule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.rules[id]
+	if !ok {
+		return Rule{}, ErrNotFound
+	}
+	return c.rule, nil
+}
+
+// Add creates a rule, generating its ID when empty
+func (s *Set) Add(ctx context.Context, r Rule) (Rule, error) {
+	if r.ID == "" {
+		r.ID = newID()
+	}
+	r.CreatedAt = time.Now().UTC()
+	r.UpdatedAt = r.CreatedAt
+
+	c, err := compile(r)
+	if err != nil {
+		return Rule{}, err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.Rule(r.ID); err == nil {
+		return Rule{}, ErrExists
+	}
+	if err := s.save(ctx, &r); err != nil {
+		return Rule{}, err
+	}
+	s.mu.Lock()
+	s.rules[r.ID] = c
+	s.mu.Unlock()
+	return r, nil
+}
+
+// Update replaces an existing rule, keeping its creation time
+func (s *Set) Update(ctx context.Context, r Rule) (Rule, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	cur, err := s.Rule(r.ID)
+	if err != nil {
+		return Rule{}, err
+	}
+	r.CreatedAt = cur.CreatedAt
+	if r.CreatedBy == "" {
+		r.CreatedBy = cur.CreatedBy
+	}
+	r.UpdatedAt = time.Now().UTC()
+
+	c, err := compile(r)
+	if err != nil {
+		return Rule{}, err
+	}
+	if err := s.save(ctx, &r); err != nil {
+		return Rule{}, err
+	}
+	s.mu.Lock()
+	s.rules[r.ID] = c
+	s.mu.Unlock()
+	return r, nil
+}
+
+// Delete removes a rule
+func (s *Set) Delete(ctx context.Context, id string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if _, err := s.Rule(id); err != nil {
+		return err
+	}
+	if s.store != nil {
+		if err := s.store.DeleteSuppression(ctx, id); err != nil {
+			return fmt.Errorf("failed to delete suppression rule: %w", err)
+		}
+	}
+	s.mu.Lock()
+	delete(s.rules, id)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Set) save(ctx context.Context, r *Rule) error {
+	if s.store == nil {
+		return nil
+	}
+	if err := s.store.SaveSuppression(ctx, r); err != nil {
+		return fmt.Errorf("failed to store suppression rule: %w", err)
+	}
+	return nil
+}
+
+func compile(r Rule) (*compiled, error) {
+	if err := Validate(&r); err != nil {
+		return nil, err
+	}
+	c := &compiled{rule: r}
+	if r.Pattern != "" {
+		c.pattern = regexp.MustCompile(r.Pattern)
+	}
+	return c, nil
+}
+
+func (c *compiled) matches(text, templateID string, metadata map[string]interface{}, at time.Time) bool {
+	if c.rule.ExpiresAt != nil && !at.Before(*c.rule.ExpiresAt) {
+		return false
+	}
+	if c.rule.TemplateID != "" && c.rule.TemplateID != templateID {
+		return false
+	}
+	for k, want := range c.rule.Match {
+		got, ok := metadata[k]
+		if !ok || fmt.Sprint(got) != want {
+			return false
+		}
+	}
+	return c.pattern == nil || c.pattern.MatchString(text)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("sup-%d", time.Now().UnixNano())
+	}
+	return "sup-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,105 @@
+package suppression
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+)
+
+var now = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func add(t *testing.T, s *Set, r Rule) {
+	t.Helper()
+	if _, err := s.Add(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	expiry := now.Add(time.Hour)
+	s := NewSet(nil)
+	add(t, s, Rule{ID: "b-timeout", Pattern: `timeout after \d+ms`})
+	add(t, s, Rule{ID: "a-timeout-db", Pattern: `timeout`, Match: map[string]string{"service": "db", "port": "5432"}})
+	add(t, s, Rule{ID: "c-template", TemplateID: "tpl-7", ExpiresAt: &expiry})
+
+	db := map[string]interface{}{"service": "db", "port": 5432}
+	tests := []struct {
+		name       string
+		text       string
+		templateID string
+		metadata   map[string]interface{}
+		at         time.Time
+		want       string // empty when nothing matches
+	}{
+		{name: "pattern", text: "timeout after 30ms", at: now, want: "b-timeout"},
+		{name: "no rule", text: "connection refused", at: now},
+		{name: "first rule by ID", text: "timeout after 30ms", metadata: db, at: now, want: "a-timeout-db"},
+		{name: "metadata must all match", text: "timeout", metadata: map[string]interface{}{"service": "db"}, at: now},
+		{name: "metadata values are compared as text", text: "timeout", metadata: db, at: now, want: "a-timeout-db"},
+		{name: "template", text: "anything", templateID: "tpl-7", at: now, want: "c-template"},
+		{name: "other template", text: "anything", templateID: "tpl-8", at: now},
+		{name: "expired at its expiry time", text: "anything", templateID: "tpl-7", at: expiry},
+		{name: "expired after it", text: "anything", templateID: "tpl-7", at: expiry.Add(time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.Match(tt.text, tt.templateID, tt.metadata, tt.at)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("Match = %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+// blockingStore holds every save until release is closed and fails it with err
+type blockingStore struct {
+	saving  chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (b *blockingStore) SaveSuppression(context.Context, *elastic.SuppressionRule) error {
+	b.saving <- struct{}{}
+	<-b.release
+	return b.err
+}
+
+func (b *blockingStore) DeleteSuppression(context.Context, string) error { return nil }
+
+func (b *blockingStore) GetSuppressions(context.Context) ([]elastic.SuppressionRule, error) {
+	return nil, nil
+}
+
+func TestChangesTakeEffectOnceStored(t *testing.T) {
+	store := &blockingStore{saving: make(chan struct{}), release: make(chan struct{}), err: errors.New("elasticsearch unavailable")}
+	s := NewSet(store)
+
+	done := make(chan error)
+	go func() {
+		_, err := s.Add(context.Background(), Rule{ID: "noise", Pattern: "noise"})
+		done <- err
+	}()
+	<-store.saving
+
+	// Matching goes on while the rule is being stored, without it
+	if id, ok := s.Match("noise", "", nil, now); ok {
+		t.Errorf("matched %s before the rule was stored", id)
+	}
+	close(store.release)
+	if err := <-done; !errors.Is(err, store.err) {
+		t.Fatalf("Add = %v, want %v", err, store.err)
+	}
+	if rules := s.Rules(); len(rules) != 0 {
+		t.Errorf("rules after a failed save = %+v, want none", rules)
+	}
+
+	store.err = nil
+	go func() { <-store.saving }()
+	add(t, s, Rule{ID: "noise", Pattern: "noise"})
+	if id, ok := s.Match("noise", "", nil, now); !ok || id != "noise" {
+		t.Errorf("Match = %q, %v after the rule was stored", id, ok)
+	}
+}
@@ -0,0 +1,299 @@
+package volume
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Methods used to compute the expected count of a window
+const (
+	MethodEWMA     = "ewma"     // exponentially weighted mean and variance of recent windows
+	MethodSeasonal = "seasonal" // EWMA kept per slot of the season, e.g. per minute of the day
+	MethodMAD      = "mad"      // median and median absolute deviation of the last History windows
+)
+
+// Kinds of volume anomalies
+const (
+	KindSpike = "spike"
+	KindDrop  = "drop"
+)
+
+// Config controls how sources are identified and when their volume is anomalous
+type Config struct {
+	SourceKeys []string      // metadata keys that together identify a source, e.g. ["service", "host"]
+	Window     time.Duration // length of the counting window
+	Method     string        // MethodEWMA, MethodSeasonal or MethodMAD
+	Threshold  float64       // deviation, in standard deviations, that makes a window anomalous
+	Alpha      float64       // EWMA smoothing factor
+	Season     time.Duration // MethodSeasonal period
+	History    int           // MethodMAD number of windows kept
+	MinHistory int           // windows observed before a source can be flagged
+	MinCount   float64       // spikes need at least this count, drops at least this expected count
+	SourceTTL  time.Duration // sources silent for longer are forgotten
+}
+
+// Event is a window whose log volume deviates from the source's baseline
+type Event struct {
+	Source      string            `json:"source"`
+	SourceKeys  map[string]string `json:"source_keys"`
+	Kind        string            `json:"kind"`
+	Method      string            `json:"method"`
+	WindowStart time.Time         `json:"window_start"`
+	WindowEnd   time.Time         `json:"window_end"`
+	Count       int64             `json:"count"`
+	Expected    float64           `json:"expected"`
+	Score       float64           `json:"score"`
+}
+
+// Monitor counts logs per source in fixed windows and compares every closed window
+// with a rolling baseline. It reports a source once when it enters the spike or drop
+// state and again only after it went back to normal.
+type Monitor struct {
+	cfg       Config
+	onAnomaly func(Event)
+
+	mu          sync.Mutex
+	windowStart time.Time
+	sources     map[string]*source
+}
+
+type source struct {
+	keys     map[string]string
+	current  int64
+	lastSeen time.Time
+	state    string
+
+	windows  int
+	baseline stats
+	seasonal map[int64]*stats
+	history  []float64
+}
+
+type stats struct {
+	n        int
+	mean     float64
+	variance float64
+}
+
+// NewMonitor creates a monitor that calls onAnomaly for every volume anomaly
+func NewMonitor(cfg Config, onAnomaly func(Event)) (*Monitor, error) {
+	switch cfg.Method {
+	case MethodEWMA, MethodSeasonal, MethodMAD:
+	default:
+		return nil, fmt.Errorf("unknown volume method %q", cfg.Method)
+	}
+	if len(cfg.SourceKeys) == 0 {
+		return nil, fmt.Errorf("at least one source key is required")
+	}
+	if cfg.Window <= 0 {
+		return nil, fmt.Errorf("window must be positive")
+	}
+	if cfg.Method == MethodSeasonal && cfg.Season < cfg.Window {
+		return nil, fmt.Errorf("season must be at least one window")
+	}
+	if cfg.History < 3 {
+		cfg.History = 3
+	}
+
+	return &Monitor{
+		cfg:         cfg,
+		onAnomaly:   onAnomaly,
+		windowStart: time.Now().UTC().Truncate(cfg.Window),
+		sources:     make(map[string]*source),
+	}, nil
+}
+
+// Observe counts one log for the source described by metadata
+func (m *Monitor) Observe(metadata map[string]interface{}) {
+	id, keys := m.sourceOf(metadata)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sources[id]
+	if !ok {
+		s = &source{keys: keys}
+		m.sources[id] = s
+	}
+	s.current++
+	s.lastSeen = time.Now().UTC()
+}
+
+// Run closes a window every cfg.Window until ctx is cancelled
+func (m *Monitor) Run(ctx context.Context) {
+	for {
+		m.mu.Lock()
+		next := m.windowStart.Add(m.cfg.Window)
+		m.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+			for _, ev := range m.closeWindow(next) {
+				m.onAnomaly(ev)
+			}
+		}
+	}
+}
+
+// closeWindow evaluates the window ending at end for every source and starts the next one
+func (m *Monitor) closeWindow(end time.Time) []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	start := m.windowStart
+	m.windowStart = end
+
+	var events []Event
+	for id, s := range m.sources {
+		if s.current == 0 && end.Sub(s.lastSeen) > m.cfg.SourceTTL {
+			delete(m.sources, id)
+			continue
+		}
+
+		count := s.current
+		s.current = 0
+
+		expected, score, ready := m.evaluate(s, start, float64(count))
+		s.windows++
+
+		kind := ""
+		if ready {
+			switch {
+			case score >= m.cfg.Threshold && float64(count) >= m.cfg.MinCount:
+				kind = KindSpike
+			case score <= -m.cfg.Threshold && expected >= m.cfg.MinCount:
+				kind = KindDrop
+			}
+		}
+
+		if kind != "" && kind != s.state {
+			events = append(events, Event{
+				Source:      id,
+				SourceKeys:  s.keys,
+				Kind:        kind,
+				Method:      m.cfg.Method,
+				WindowStart: start,
+				WindowEnd:   end,
+				Count:       count,
+				Expected:    expected,
+				Score:       score,
+			})
+		}
+		s.state = kind
+	}
+	return events
+}
+
+// evaluate returns the expected count for the window, how many standard deviations
+// count is away from it, and whether enough history exists to judge. It then folds
+// count into the baseline.
+func (m *Monitor) evaluate(s *source, windowStart time.Time, count float64) (float64, float64, bool) {
+	switch m.cfg.Method {
+	case MethodSeasonal:
+		if s.seasonal == nil {
+			s.seasonal = make(map[int64]*stats)
+		}
+		slot := windowStart.UnixNano() % int64(m.cfg.Season) / int64(m.cfg.Window)
+		st, ok := s.seasonal[slot]
+		if !ok {
+			st = &stats{}
+			s.seasonal[slot] = st
+		}
+		expected, score := st.mean, deviation(count, st.mean, math.Sqrt(st.variance))
+		ready := st.n >= m.cfg.MinHistory
+		st.update(m.clip(count, st, ready), m.cfg.Alpha)
+		return expected, score, ready
+
+	case MethodMAD:
+		expected, score := 0.0, 0.0
+		ready := len(s.history) >= m.cfg.MinHistory
+		if len(s.history) > 0 {
+			median, mad := medianAbsoluteDeviation(s.history)
+			expected, score = median, deviation(count, median, 1.4826*mad)
+		}
+		s.history = append(s.history, count)
+		if len(s.history) > m.cfg.History {
+			s.history = s.history[len(s.history)-m.cfg.History:]
+		}
+		return expected, score, ready
+
+	default:
+		expected, score := s.baseline.mean, deviation(count, s.baseline.mean, math.Sqrt(s.baseline.variance))
+		ready := s.baseline.n >= m.cfg.MinHistory
+		s.baseline.update(m.clip(count, &s.baseline, ready), m.cfg.Alpha)
+		return expected, score, ready
+	}
+}
+
+// clip limits how far a single window can move an established baseline, so one
+// storm does not inflate the variance enough to hide the next anomaly, while a
+// lasting change of level is still absorbed over time
+func (m *Monitor) clip(count float64, st *stats, ready bool) float64 {
+	if !ready {
+		return count
+	}
+	stddev := math.Max(math.Sqrt(st.variance), math.Max(math.Sqrt(st.mean), 1))
+	limit := m.cfg.Threshold * stddev
+	return math.Min(math.Max(count, st.mean-limit), st.mean+limit)
+}
+
+func (m *Monitor) sourceOf(metadata map[string]interface{}) (string, map[string]string) {
+	keys := make(map[string]string, len(m.cfg.SourceKeys))
+	parts := make([]string, len(m.cfg.SourceKeys))
+	for i, k := range m.cfg.SourceKeys {
+		v := "unknown"
+		if raw, ok := metadata[k]; ok && raw != nil {
+			v = fmt.Sprint(raw)
+		}
+		keys[k] = v
+		parts[i] = k + "=" + v
+	}
+	return strings.Join(parts, ","), keys
+}
+
+func (st *stats) update(x, alpha float64) {
+	if st.n == 0 {
+		st.mean = x
+	} else {
+		diff := x - st.mean
+		incr := alpha * diff
+		st.mean += incr
+		st.variance = (1 - alpha) * (st.variance + diff*incr)
+	}
+	st.n++
+}
+
+// deviation is the z-score of x; the standard deviation is floored at the Poisson
+// noise of the expected count so quiet, regular sources are not flagged for +-1 log
+func deviation(x, expected, stddev float64) float64 {
+	stddev = math.Max(stddev, math.Max(math.Sqrt(expected), 1))
+	return (x - expected) / stddev
+}
+
+func medianAbsoluteDeviation(values []float64) (float64, float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	median := medianOf(sorted)
+
+	deviations := make([]float64, len(sorted))
+	for i, v := range sorted {
+		deviations[i] = math.Abs(v - median)
+	}
+	sort.Float64s(deviations)
+	return median, medianOf(deviations)
+}
+
+func medianOf(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
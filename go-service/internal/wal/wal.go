@@ -0,0 +1,522 @@
+package wal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+	"anomaly-detection-platform/go-service/internal/metrics"
+)
+
+// Drop policies, applied when an append would take the log over its size cap
+const (
+	DropOldest = "oldest" // delete the oldest segment, losing the documents it still holds
+	DropNewest = "newest" // refuse the new documents
+)
+
+var (
+	// ErrFull is returned by Append when the documents do not fit under the size cap
+	ErrFull = errors.New("write-ahead log is full")
+	// ErrClosed is returned by Append once the log has been closed
+	ErrClosed = errors.New("write-ahead log is closed")
+)
+
+// replayBatch bounds the documents handed to the writer at once
+const replayBatch = 500
+
+// Config selects where the log lives and how large it may grow
+type Config struct {
+	Dir          string
+	MaxBytes     int64  // size cap of all segments, default 1GiB
+	SegmentBytes int64  // segments are rolled at this size, default 64MiB and at most a quarter of MaxBytes
+	DropPolicy   string // DropOldest (default) or DropNewest
+}
+
+type segment struct {
+	id      uint64
+	size    int64 // bytes on disk
+	records int   // complete records not replayed yet
+}
+
+// cursor is the position of the next record to replay
+type cursor struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// WAL is an on-disk queue of documents waiting for Elasticsearch. Documents are appended
+// as JSON lines to numbered segment files and replayed in order; the replay position is
+// kept in a cursor file, so a restart resumes where the previous run stopped. Appends are
+// written to the OS at once and synced to disk at every replay tick, so a process crash
+// loses nothing and a machine crash at most the last tick.
+type WAL struct {
+	cfg Config
+
+	mu       sync.Mutex
+	segments []*segment // oldest first, the last one takes the appends
+	active   *os.File
+	read     cursor
+	dirty    bool // appended since the last sync
+	closed   bool
+
+	pending atomic.Int64 // records not replayed yet, readable without the lock
+}
+
+// Open opens the log in cfg.Dir, creating it when needed, and starts a new segment
+func Open(cfg Config) (*WAL, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("no write-ahead log directory configured")
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 1 << 30
+	}
+	if cfg.SegmentBytes <= 0 {
+		cfg.SegmentBytes = 64 << 20
+	}
+	cfg.SegmentBytes = min(cfg.SegmentBytes, max(cfg.MaxBytes/4, 1))
+	switch cfg.DropPolicy {
+	case "":
+		cfg.DropPolicy = DropOldest
+	case DropOldest, DropNewest:
+	default:
+		return nil, fmt.Errorf("unknown write-ahead log drop policy %q", cfg.DropPolicy)
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create write-ahead log directory: %w", err)
+	}
+
+	w := &WAL{cfg: cfg}
+	if err := w.load(); err != nil {
+		return nil, err
+	}
+	if err := w.roll(); err != nil {
+		return nil, err
+	}
+	w.updateBacklog()
+	return w, nil
+}
+
+// load finds the segments and the cursor left by a previous run
+func (w *WAL) load() error {
+	entries, err := os.ReadDir(w.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read write-ahead log directory: %w", err)
+	}
+	var ids []uint64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".wal")
+		if !ok {
+			continue
+		}
+		if id, err := strconv.ParseUint(name, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	b, err := os.ReadFile(w.cursorPath())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to read write-ahead log cursor: %w", err)
+	default:
+		if err := json.Unmarshal(b, &w.read); err != nil {
+			return fmt.Errorf("invalid write-ahead log cursor: %w", err)
+		}
+	}
+
+	for _, id := range ids {
+		if id < w.read.Segment {
+			// Replayed before the previous run could delete it
+			os.Remove(w.segmentPath(id))
+			continue
+		}
+		fi, err := os.Stat(w.segmentPath(id))
+		if err != nil {
+			return fmt.Errorf("failed to read write-ahead log segment: %w", err)
+		}
+		var from int64
+		if id == w.read.Segment {
+			from = min(w.read.Offset, fi.Size())
+		}
+		n, err := countRecords(w.segmentPath(id), from)
+		if err != nil {
+			return err
+		}
+		w.segments = append(w.segments, &segment{id: id, size: fi.Size(), records: n})
+	}
+	if len(w.segments) > 0 && w.segments[0].id != w.read.Segment {
+		w.read = cursor{Segment: w.segments[0].id}
+	}
+	return nil
+}
+
+func countRecords(path string, from int64) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read write-ahead log segment: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(from, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	buf := make([]byte, 64<<10)
+	for {
+		k, err := f.Read(buf)
+		n += bytes.Count(buf[:k], []byte{'\n'})
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read write-ahead log segment: %w", err)
+		}
+	}
+}
+
+func (w *WAL) segmentPath(id uint64) string {
+	return filepath.Join(w.cfg.Dir, fmt.Sprintf("%020d.wal", id))
+}
+
+func (w *WAL) cursorPath() string {
+	return filepath.Join(w.cfg.Dir, "cursor.json")
+}
+
+// roll starts a new segment for the appends
+func (w *WAL) roll() error {
+	id := uint64(1)
+	if len(w.segments) > 0 {
+		id = w.segments[len(w.segments)-1].id + 1
+	}
+	f, err := os.OpenFile(w.segmentPath(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create write-ahead log segment: %w", err)
+	}
+	if w.active != nil {
+		w.active.Sync()
+		w.active.Close()
+	}
+	w.active = f
+	w.dirty = false
+	w.segments = append(w.segments, &segment{id: id})
+	if len(w.segments) == 1 {
+		w.read = cursor{Segment: id}
+	}
+	return nil
+}
+
+// Append writes docs to the end of the log, all of them or none
+func (w *WAL) Append(docs []elastic.Document) error {
+	var buf bytes.Buffer
+	for _, d := range docs {
+		b, err := json.Marshal(d)
+		if err != nil {
+			return fmt.Errorf("failed to marshal document: %w", err)
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	if err := w.makeRoom(int64(buf.Len())); err != nil {
+		metrics.WALRecordsTotal.WithLabelValues("dropped").Add(float64(len(docs)))
+		return err
+	}
+
+	seg := w.segments[len(w.segments)-1]
+	if seg.size > 0 && seg.size+int64(buf.Len()) > w.cfg.SegmentBytes {
+		if err := w.roll(); err != nil {
+			return err
+		}
+		seg = w.segments[len(w.segments)-1]
+	}
+	if n, err := w.active.Write(buf.Bytes()); err != nil {
+		w.discard(seg, n)
+		return fmt.Errorf("failed to write to write-ahead log: %w", err)
+	}
+	seg.size += int64(buf.Len())
+	seg.records += len(docs)
+	w.dirty = true
+	metrics.WALRecordsTotal.WithLabelValues("appended").Add(float64(len(docs)))
+	w.updateBacklog()
+	return nil
+}
+
+// discard removes the n bytes a failed append left at the end of seg, so that the next
+// append does not extend a torn record; when the file cannot be cut, appends move to a
+// new segment and the replay skips the torn record at the end of this one
+func (w *WAL) discard(seg *segment, n int) {
+	if n == 0 {
+		return
+	}
+	err := w.active.Truncate(seg.size)
+	if err == nil {
+		return
+	}
+	log.Printf("Failed to remove a partial write-ahead log record: %v", err)
+	seg.size += int64(n)
+	if err := w.roll(); err != nil {
+		log.Printf("Failed to start a new write-ahead log segment: %v", err)
+	}
+}
+
+// makeRoom applies the drop policy until n more bytes fit under the size cap
+func (w *WAL) makeRoom(n int64) error {
+	for w.size()+n > w.cfg.MaxBytes {
+		if w.cfg.DropPolicy == DropNewest {
+			return ErrFull
+		}
+		if len(w.segments) == 1 {
+			if w.segments[0].size == 0 {
+				return ErrFull // larger than the cap on its own
+			}
+			if err := w.roll(); err != nil {
+				return err
+			}
+		}
+
+		oldest := w.segments[0]
+		os.Remove(w.segmentPath(oldest.id))
+		w.segments = w.segments[1:]
+		if oldest.records > 0 {
+			log.Printf("Write-ahead log is full, dropped %d documents", oldest.records)
+			metrics.WALRecordsTotal.WithLabelValues("dropped").Add(float64(oldest.records))
+		}
+		if w.read.Segment == oldest.id {
+			w.read = cursor{Segment: w.segments[0].id}
+			w.saveCursor()
+		}
+	}
+	return nil
+}
+
+func (w *WAL) size() int64 {
+	var n int64
+	for _, s := range w.segments {
+		n += s.size
+	}
+	return n
+}
+
+func (w *WAL) segment(id uint64) *segment {
+	for _, s := range w.segments {
+		if s.id == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// Pending reports whether documents are waiting to be replayed
+func (w *WAL) Pending() bool {
+	return w.pending.Load() > 0
+}
+
+// Backlog returns the number and size of the documents waiting to be replayed
+func (w *WAL) Backlog() (records int, size int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.backlog()
+}
+
+func (w *WAL) backlog() (records int, size int64) {
+	for _, s := range w.segments {
+		records += s.records
+	}
+	return records, w.size() - w.read.Offset
+}
+
+func (w *WAL) updateBacklog() {
+	records, size := w.backlog()
+	w.pending.Store(int64(records))
+	metrics.WALBacklogRecords.Set(float64(records))
+	metrics.WALBacklogBytes.Set(float64(size))
+}
+
+// Replay hands the documents to write in order, in batches, whenever ready reports true,
+// checking every interval until ctx is done. write must return once the documents are
+// stored: a batch leaves the log, and the cursor moves past it, only when write returns nil;
+// otherwise it is handed over again at the next tick.
+func (w *WAL) Replay(ctx context.Context, interval time.Duration, ready func() bool, write func(context.Context, []elastic.Document) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.sync()
+		for ready() && ctx.Err() == nil {
+			n, err := w.replayOnce(ctx, write)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Failed to replay write-ahead log: %v", err)
+				}
+				break
+			}
+			if n == 0 {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// replayOnce writes the next batch and moves the cursor past it
+func (w *WAL) replayOnce(ctx context.Context, write func(context.Context, []elastic.Document) error) (int, error) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return 0, ErrClosed
+	}
+	docs, next, lines, err := w.readBatch()
+	start := w.read
+	w.mu.Unlock()
+	if err != nil || len(docs) == 0 {
+		return 0, err
+	}
+
+	if err := write(ctx, docs); err != nil {
+		return 0, err
+	}
+	metrics.WALRecordsTotal.WithLabelValues("replayed").Add(float64(len(docs)))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// The segment may have been dropped meanwhile
+	if w.read == start {
+		w.advance(next, lines)
+	}
+	return len(docs), nil
+}
+
+// readBatch reads the records at the cursor, deleting the segments read to their end;
+// lines counts the records read, including those that could not be decoded
+func (w *WAL) readBatch() (docs []elastic.Document, next cursor, lines int, err error) {
+	for {
+		seg := w.segment(w.read.Segment)
+		if seg == nil {
+			return nil, w.read, 0, nil
+		}
+		docs, next, lines, err = w.readSegment(seg)
+		if err != nil {
+			return nil, w.read, 0, err
+		}
+		if len(docs) > 0 {
+			return docs, next, lines, nil
+		}
+		if lines > 0 {
+			// Only undecodable records: skip them
+			w.advance(next, lines)
+			continue
+		}
+
+		active := seg == w.segments[len(w.segments)-1]
+		if active {
+			return nil, w.read, 0, nil
+		}
+		// Read to its end; a torn last record cannot be completed any more
+		os.Remove(w.segmentPath(seg.id))
+		w.segments = w.segments[1:]
+		w.read = cursor{Segment: w.segments[0].id}
+		w.saveCursor()
+		w.updateBacklog()
+	}
+}
+
+func (w *WAL) readSegment(seg *segment) ([]elastic.Document, cursor, int, error) {
+	f, err := os.Open(w.segmentPath(seg.id))
+	if err != nil {
+		return nil, w.read, 0, fmt.Errorf("failed to read write-ahead log segment: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(io.NewSectionReader(f, w.read.Offset, seg.size-w.read.Offset))
+	next := w.read
+	var docs []elastic.Document
+	lines := 0
+	for lines < replayBatch {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			break // end of segment, or a record still being written
+		}
+		next.Offset += int64(len(line))
+		lines++
+		var d elastic.Document
+		if err := json.Unmarshal(line, &d); err != nil {
+			log.Printf("Skipping corrupt write-ahead log record in segment %d: %v", seg.id, err)
+			metrics.WALRecordsTotal.WithLabelValues("dropped").Inc()
+			continue
+		}
+		docs = append(docs, d)
+	}
+	return docs, next, lines, nil
+}
+
+func (w *WAL) advance(next cursor, lines int) {
+	if seg := w.segment(next.Segment); seg != nil {
+		seg.records = max(seg.records-lines, 0)
+	}
+	w.read = next
+	w.saveCursor()
+	w.updateBacklog()
+}
+
+// saveCursor writes the replay position, replacing the file atomically
+func (w *WAL) saveCursor() {
+	b, _ := json.Marshal(w.read)
+	tmp := w.cursorPath() + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		log.Printf("Failed to save write-ahead log cursor: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, w.cursorPath()); err != nil {
+		log.Printf("Failed to save write-ahead log cursor: %v", err)
+	}
+}
+
+func (w *WAL) sync() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dirty && !w.closed {
+		if err := w.active.Sync(); err != nil {
+			log.Printf("Failed to sync write-ahead log: %v", err)
+		}
+		w.dirty = false
+	}
+}
+
+// Close syncs the log and releases its files; documents not replayed yet stay on disk for the next run
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.saveCursor()
+	if err := w.active.Sync(); err != nil {
+		w.active.Close()
+		return err
+	}
+	return w.active.Close()
+}
@@ -0,0 +1,230 @@
+package wal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"anomaly-detection-platform/go-service/internal/elastic"
+)
+
+func docs(t *testing.T, ids ...string) []elastic.Document {
+	t.Helper()
+	var out []elastic.Document
+	for _, id := range ids {
+		d, err := elastic.NewDocument("logs-write", id, map[string]string{"id": id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+func open(t *testing.T, cfg Config) *WAL {
+	t.Helper()
+	w, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+func appendIDs(t *testing.T, w *WAL, ids ...string) {
+	t.Helper()
+	if err := w.Append(docs(t, ids...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// replayAll replays the log until it is empty and returns the IDs written, batch by batch
+func replayAll(t *testing.T, w *WAL) [][]string {
+	t.Helper()
+	var batches [][]string
+	for {
+		var ids []string
+		n, err := w.replayOnce(context.Background(), func(_ context.Context, docs []elastic.Document) error {
+			for _, d := range docs {
+				ids = append(ids, d.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			return batches
+		}
+		batches = append(batches, ids)
+	}
+}
+
+func savedCursor(t *testing.T, dir string) cursor {
+	t.Helper()
+	var c cursor
+	b, err := os.ReadFile(filepath.Join(dir, "cursor.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return c
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func segmentFiles(t *testing.T, dir string) int {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestWALCursorMovesOnlyAfterWrite(t *testing.T) {
+	dir := t.TempDir()
+	w := open(t, Config{Dir: dir})
+	appendIDs(t, w, "a", "b")
+
+	failed := errors.New("elasticsearch unavailable")
+	if _, err := w.replayOnce(context.Background(), func(context.Context, []elastic.Document) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("replay = %v, want %v", err, failed)
+	}
+	if records, _ := w.Backlog(); records != 2 || savedCursor(t, dir).Offset != 0 {
+		t.Fatalf("after a failed write: backlog %d, cursor %+v", records, savedCursor(t, dir))
+	}
+
+	if got, want := replayAll(t, w), [][]string{{"a", "b"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("replayed %q, want %q", got, want)
+	}
+	if w.Pending() {
+		t.Error("documents still pending after the replay")
+	}
+	if c := savedCursor(t, dir); c.Offset == 0 {
+		t.Errorf("cursor %+v not saved after the replay", c)
+	}
+
+	// A restart resumes after the replayed documents
+	appendIDs(t, w, "c")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w = open(t, Config{Dir: dir})
+	if records, _ := w.Backlog(); records != 1 {
+		t.Errorf("backlog after the restart = %d, want 1", records)
+	}
+	if got, want := replayAll(t, w), [][]string{{"c"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed %q after the restart, want %q", got, want)
+	}
+}
+
+func TestWALSegments(t *testing.T) {
+	dir := t.TempDir()
+	// Two records fit in a segment
+	line, _ := json.Marshal(docs(t, "a")[0])
+	w := open(t, Config{Dir: dir, SegmentBytes: int64(2 * (len(line) + 1))})
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		appendIDs(t, w, id)
+	}
+	if n := segmentFiles(t, dir); n != 3 {
+		t.Fatalf("%d segment files, want 3", n)
+	}
+
+	got := replayAll(t, w)
+	if want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed %q, want %q", got, want)
+	}
+	// Segments read to their end are deleted, the active one is kept
+	if n := segmentFiles(t, dir); n != 1 {
+		t.Errorf("%d segment files after the replay, want 1", n)
+	}
+}
+
+func TestWALDropPolicy(t *testing.T) {
+	line, _ := json.Marshal(docs(t, "a")[0])
+	ids := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{DropNewest, []string{"a", "b", "c", "d"}},
+		{DropOldest, []string{"e", "f", "g", "h"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			// Room for four records, one per segment
+			w := open(t, Config{Dir: t.TempDir(), MaxBytes: int64(4*len(line) + 4), DropPolicy: tt.policy})
+			for i, id := range ids {
+				err := w.Append(docs(t, id))
+				switch {
+				case tt.policy == DropNewest && i >= 4:
+					if !errors.Is(err, ErrFull) {
+						t.Errorf("append %s = %v, want ErrFull", id, err)
+					}
+				case err != nil:
+					t.Fatalf("append %s: %v", id, err)
+				}
+			}
+
+			var got []string
+			for _, batch := range replayAll(t, w) {
+				got = append(got, batch...)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replayed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWALDiscardsPartialRecords(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		cannotTrim bool
+	}{
+		{name: "cut from the segment"},
+		{name: "left behind when the segment cannot be cut", cannotTrim: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w := open(t, Config{Dir: dir})
+			appendIDs(t, w, "a")
+
+			// A write that failed half way through a record
+			seg := w.segments[len(w.segments)-1]
+			torn := []byte(`{"index":"logs-write","id":"b","do`)
+			if _, err := w.active.Write(torn); err != nil {
+				t.Fatal(err)
+			}
+			if tt.cannotTrim {
+				readOnly, err := os.Open(w.segmentPath(seg.id))
+				if err != nil {
+					t.Fatal(err)
+				}
+				w.active.Close()
+				w.active = readOnly
+			}
+			w.discard(seg, len(torn))
+			appendIDs(t, w, "c")
+
+			var got []string
+			for _, batch := range replayAll(t, w) {
+				got = append(got, batch...)
+			}
+			if want := []string{"a", "c"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("replayed %q, want %q", got, want)
+			}
+			if w.Pending() {
+				t.Error("documents still pending after the replay")
+			}
+		})
+	}
+}
@@ -81,7 +81,7 @@ func main() {
 	// Test 3: Search anomalies by text
 	fmt.Println("\n🔍 Test 3: Search anomalies by text")
 
-	anomalies, err := esClient.SearchAnomaliesByText(ctx, "error", 0, 10)
+	anomalies, err := esClient.SearchAnomaliesByText(ctx, "error", 0, 10, false)
 	if err != nil {
 		log.Printf("Failed to search anomalies: %v", err)
 	} else {
@@ -110,7 +110,7 @@ func main() {
 	startTime := time.Now().Add(-1 * time.Hour)
 	endTime := time.Now()
 
-	stats, err := esClient.GetAnomalyStats(ctx, startTime, endTime)
+	stats, err := esClient.GetAnomalyStats(ctx, startTime, endTime, false)
 	if err != nil {
 		log.Printf("Failed to get anomaly stats: %v", err)
 	} else {
@@ -136,7 +136,7 @@ func main() {
 	// Test 7: Get all anomalies
 	fmt.Println("\n🔍 Test 7: Get all anomalies")
 
-	allAnomalies, err := esClient.GetAnomalies(ctx, 0, 10)
+	allAnomalies, err := esClient.GetAnomalies(ctx, 0, 10, false)
 	if err != nil {
 		log.Printf("Failed to get anomalies: %v", err)
 	} else {
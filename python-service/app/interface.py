@@ -18,6 +18,37 @@ def predict_log(text: str, threshold: float = 0.5) -> dict:
         print("DEBUG raw model output:", raw, flush=True)
         DEBUG_PRINTED = True
 
+    result = _decide(raw, threshold)
+    result["raw"] = raw  # <-- keep raw for debugging
+    return result
+
+
+def predict_logs(texts: list[str], threshold: float = 0.5, batch_size: int = 32) -> list[dict]:
+    """
+    Batched variant of `predict_log`: runs the whole list through the pipeline at once.
+    - Returns one result per input, in order.
+    - If the batch call fails, items are retried one by one so a single bad input
+      only fails its own entry (reported under "error").
+    """
+    if not texts:
+        return []
+
+    try:
+        raws = pipe(texts, return_all_scores=True, batch_size=batch_size)
+    except Exception:
+        results = []
+        for text in texts:
+            try:
+                results.append(_decide(pipe(text, return_all_scores=True)[0], threshold))
+            except Exception as exc:
+                results.append({"error": str(exc)})
+        return results
+
+    return [_decide(raw, threshold) for raw in raws]
+
+
+def _decide(raw: list, threshold: float) -> dict:
+    """Apply the anomaly decision rule to the label scores of a single input."""
     # Convert to dict of label -> score
     probs = {item["label"]: float(item["score"]) for item in raw}
 
@@ -44,5 +75,4 @@ def predict_log(text: str, threshold: float = 0.5) -> dict:
         "label": label,
         "score": score,
         "probs": probs,
-        "raw": raw  # <-- keep raw for debugging
     }
@@ -1,6 +1,6 @@
 from fastapi import FastAPI
-from app.schemas import LogRequest, LogResponse
-from .interface import predict_log
+from app.schemas import LogRequest, LogResponse, BatchLogRequest, BatchLogItem, BatchLogResponse
+from .interface import predict_log, predict_logs
 
 app = FastAPI(title="Log Anomaly Detection Service")
 
@@ -8,3 +8,8 @@ app = FastAPI(title="Log Anomaly Detection Service")
 def predict(request: LogRequest):
     result = predict_log(request.text, threshold=0.1)
     return LogResponse(label=result["label"], score=result["score"])
+
+@app.post("/predict/batch", response_model=BatchLogResponse)
+def predict_batch(request: BatchLogRequest):
+    results = predict_logs(request.texts, threshold=0.1)
+    return BatchLogResponse(results=[BatchLogItem(**r) for r in results])
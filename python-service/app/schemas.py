@@ -1,3 +1,5 @@
+from typing import Dict, List, Optional
+
 from pydantic import BaseModel
 
 class LogRequest(BaseModel):
@@ -6,3 +8,15 @@ class LogRequest(BaseModel):
 class LogResponse(BaseModel):
     label: str
     score: float
+
+class BatchLogRequest(BaseModel):
+    texts: List[str]
+
+class BatchLogItem(BaseModel):
+    label: Optional[str] = None
+    score: Optional[float] = None
+    probs: Optional[Dict[str, float]] = None
+    error: Optional[str] = None
+
+class BatchLogResponse(BaseModel):
+    results: List[BatchLogItem]